@@ -0,0 +1,290 @@
+package glox
+
+import (
+	"io"
+	"sort"
+	"strings"
+)
+
+// SymbolKind tells what a Symbol declares.
+type SymbolKind int
+
+const (
+	SymbolVariable SymbolKind = iota
+	SymbolParameter
+	SymbolFunction
+	SymbolClass
+	SymbolMethod
+	SymbolModule
+)
+
+func (sk SymbolKind) String() string {
+	switch sk {
+	case SymbolParameter:
+		return "parameter"
+	case SymbolFunction:
+		return "function"
+	case SymbolClass:
+		return "class"
+	case SymbolMethod:
+		return "method"
+	case SymbolModule:
+		return "module"
+	default:
+		return "variable"
+	}
+}
+
+// Symbol is a name declared in the analyzed source.
+type Symbol struct {
+	Name string
+	Kind SymbolKind
+
+	// Decl is the name token of the declaration.
+	Decl *Token
+
+	// Detail is the declaration as it reads in the source, such as
+	// "fun add(a, b)".
+	Detail string
+
+	// Global is set for the top-level declarations of the file.
+	Global bool
+
+	// Children are the methods of a class.
+	Children []*Symbol
+
+	// References are the tokens that use or assign the symbol, in source
+	// order. The declaration itself is not included.
+	References []*Token
+}
+
+// Analysis is what Analyze finds out about a piece of source without
+// running it.
+type Analysis struct {
+	Tokens     []Token
+	Statements []Stmt
+
+	// Errors are the static errors in the source. The analysis goes on
+	// after a syntax error, so the rest of the fields describe the
+	// statements that parsed.
+	Errors []*SyntaxError
+
+	// Warnings are what the linter finds in the statements that parsed.
+	Warnings []*Warning
+
+	// Symbols are the declarations in the order they appear. Methods are
+	// only listed as the Children of their class.
+	Symbols []*Symbol
+
+	// occurrences maps the declarations and references to their symbols.
+	occurrences []occurrence
+
+	// globals holds the first top-level declaration of every name.
+	// Globals are resolved by name once the whole file has been seen, as
+	// a function may use a global declared after it.
+	globals    map[string]*Symbol
+	globalUses []*Token
+}
+
+// occurrence is a token that declares or refers to a symbol.
+type occurrence struct {
+	token  *Token
+	symbol *Symbol
+}
+
+// Analyze scans, parses and resolves source and collects its errors and
+// declarations. It never runs the code.
+func Analyze(source string) *Analysis {
+	errorPrinter := NewErrorPrinter(io.Discard)
+	errorPrinter.SetSource(source)
+
+	tokens := NewScanner(source, errorPrinter).ScanTokens()
+	stmts := NewParser(tokens, errorPrinter).ParseAll()
+
+	analysis := &Analysis{
+		Tokens:     tokens,
+		Statements: stmts,
+		globals:    map[string]*Symbol{},
+	}
+
+	analyzer := &analyzer{analysis: analysis, locals: map[*Token]*Symbol{}}
+	resolver := NewResolver(nil, errorPrinter)
+	resolver.hooks = analyzer
+	resolver.EnableWarnings()
+	resolver.Resolve(stmts)
+
+	for _, use := range analysis.globalUses {
+		if symbol, ok := analysis.globals[use.Lexeme]; ok {
+			analysis.addReference(use, symbol)
+		}
+	}
+
+	for _, symbol := range analysis.Symbols {
+		sort.Slice(symbol.References, func(i, j int) bool {
+			return symbol.References[i].Offset < symbol.References[j].Offset
+		})
+	}
+
+	analysis.Errors = errorPrinter.Errors()
+	analysis.Warnings = errorPrinter.Warnings()
+	return analysis
+}
+
+// SymbolAt returns the symbol declared or referred to by the identifier at
+// the byte offset, or nil if there is none.
+func (a *Analysis) SymbolAt(offset uint32) *Symbol {
+	for _, occ := range a.occurrences {
+		if occ.token.Offset <= offset && offset <= occ.token.Offset+occ.token.Length {
+			return occ.symbol
+		}
+	}
+
+	return nil
+}
+
+// Names returns the names of the symbols declared in the source, sorted
+// and without duplicates.
+func (a *Analysis) Names() []string {
+	seen := map[string]bool{}
+	var collect func(symbols []*Symbol)
+	collect = func(symbols []*Symbol) {
+		for _, symbol := range symbols {
+			seen[symbol.Name] = true
+			collect(symbol.Children)
+		}
+	}
+	collect(a.Symbols)
+
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+func (a *Analysis) addReference(token *Token, symbol *Symbol) {
+	symbol.References = append(symbol.References, token)
+	a.occurrences = append(a.occurrences, occurrence{token, symbol})
+}
+
+// analyzer records the declarations and references the Resolver reports.
+type analyzer struct {
+	analysis *Analysis
+
+	// locals maps the name token of each local declaration to its symbol.
+	locals map[*Token]*Symbol
+}
+
+func (an *analyzer) declared(name *Token, kind SymbolKind, node Stmt, global bool) {
+	a := an.analysis
+
+	// redefining a global refers to the first declaration.
+	if existing, ok := a.globals[name.Lexeme]; global && ok {
+		a.addReference(name, existing)
+		return
+	}
+
+	symbol := &Symbol{
+		Name:   name.Lexeme,
+		Kind:   kind,
+		Decl:   name,
+		Detail: declarationDetail(name, kind, node),
+		Global: global,
+	}
+
+	if class, isClass := node.(*Class); isClass {
+		for idx := range class.Methods {
+			method := &class.Methods[idx]
+			child := &Symbol{
+				Name:   method.Name.Lexeme,
+				Kind:   SymbolMethod,
+				Decl:   method.Name,
+				Detail: class.Name.Lexeme + "." + method.Name.Lexeme + parameterList(&method.Function),
+			}
+			symbol.Children = append(symbol.Children, child)
+			a.occurrences = append(a.occurrences, occurrence{method.Name, child})
+		}
+	}
+
+	a.Symbols = append(a.Symbols, symbol)
+	a.occurrences = append(a.occurrences, occurrence{name, symbol})
+	if global {
+		a.globals[name.Lexeme] = symbol
+	} else {
+		an.locals[name] = symbol
+	}
+}
+
+func (an *analyzer) referenced(name *Token, decl *Token) {
+	if decl == nil {
+		an.analysis.globalUses = append(an.analysis.globalUses, name)
+		return
+	}
+
+	if symbol, ok := an.locals[decl]; ok {
+		an.analysis.addReference(name, symbol)
+	}
+}
+
+// declarationDetail renders the declaration of name the way it reads in
+// the source.
+func declarationDetail(name *Token, kind SymbolKind, node Stmt) string {
+	switch decl := node.(type) {
+	case *Function:
+		return "fun " + name.Lexeme + parameterList(&decl.Function)
+	case *Class:
+		if decl.Superclass != nil {
+			return "class " + name.Lexeme + " < " + decl.Superclass.Name.Lexeme
+		}
+		return "class " + name.Lexeme
+	case *Try:
+		return "catch (" + name.Lexeme + ")"
+	case *Import:
+		if decl.Alias != nil {
+			return "import " + decl.Path.Lexeme + " as " + name.Lexeme
+		}
+		return "from " + decl.Path.Lexeme + " import " + name.Lexeme
+	}
+
+	if kind == SymbolParameter {
+		return "(parameter) " + name.Lexeme
+	}
+
+	return "var " + name.Lexeme
+}
+
+func parameterList(function *FunctionExpr) string {
+	params := make([]string, len(function.Paramters))
+	for idx, param := range function.Paramters {
+		params[idx] = param.Lexeme
+	}
+
+	return "(" + strings.Join(params, ", ") + ")"
+}
+
+// Keywords returns the reserved words of Lox, sorted.
+func Keywords() []string {
+	words := make([]string, 0, len(keywords))
+	for word := range keywords {
+		words = append(words, word)
+	}
+	sort.Strings(words)
+
+	return words
+}
+
+// Builtins returns the names of the native functions every script can
+// use, sorted.
+func Builtins() []string {
+	interpreter := NewInterpreter(NewErrorPrinter(io.Discard), io.Discard)
+
+	names := make([]string, 0, len(interpreter.builtins.values))
+	for name := range interpreter.builtins.values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
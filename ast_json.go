@@ -0,0 +1,362 @@
+package glox
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+)
+
+// ParseAST scans and parses source without resolving or running it. Syntax
+// errors are returned as a *CompileError.
+func ParseAST(source string) ([]Stmt, error) {
+	errorPrinter := NewErrorPrinter(io.Discard)
+	errorPrinter.SetSource(source)
+
+	tokens := NewScanner(source, errorPrinter).ScanTokens()
+	stmts := NewParser(tokens, errorPrinter).Parse()
+	if errorPrinter.hadError {
+		return nil, &CompileError{Errors: errorPrinter.Errors()}
+	}
+
+	return stmts, nil
+}
+
+// MarshalAST returns the JSON encoding of a syntax tree, an array of the
+// statements. Every node is an object whose "type" is the name of its Go
+// type, followed by its fields in the order of the Go struct. Tokens are
+// objects with their lexeme and position, as in Token. Literals have a
+// "kind": nil, bool, int, float or string.
+func MarshalAST(stmts []Stmt) ([]byte, error) {
+	encoder := &astEncoder{}
+
+	compact, err := marshalJSON(encoder.stmts(stmts))
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, compact, "", "  "); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// marshalJSON is json.Marshal without the escaping of <, > and &, which
+// are operators of the language.
+func marshalJSON(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(v); err != nil {
+		return nil, err
+	}
+
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// jsonObject is a JSON object that keeps its fields in order, so that the
+// encoding of a tree is stable and reads like the source.
+type jsonObject []jsonField
+
+type jsonField struct {
+	name  string
+	value interface{}
+}
+
+func (o jsonObject) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+
+	buf.WriteByte('{')
+	for idx, field := range o {
+		if idx > 0 {
+			buf.WriteByte(',')
+		}
+
+		name, err := marshalJSON(field.name)
+		if err != nil {
+			return nil, err
+		}
+		value, err := marshalJSON(field.value)
+		if err != nil {
+			return nil, err
+		}
+
+		buf.Write(name)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+
+	return buf.Bytes(), nil
+}
+
+// astEncoder turns the nodes of a tree into jsonObjects. node holds the
+// object of the last statement visited, since the visitors of statements
+// only return an error.
+type astEncoder struct {
+	node jsonObject
+}
+
+// jsonNode returns a node of type kind with the fields given as name, value
+// pairs.
+func jsonNode(kind string, fields ...interface{}) jsonObject {
+	obj := jsonObject{{"type", kind}}
+	for idx := 0; idx < len(fields); idx += 2 {
+		obj = append(obj, jsonField{fields[idx].(string), fields[idx+1]})
+	}
+
+	return obj
+}
+
+func (ae *astEncoder) expr(expr Expr) interface{} {
+	if expr == nil {
+		return nil
+	}
+
+	val, _ := expr.Accept(ae)
+	return val
+}
+
+func (ae *astEncoder) exprs(exprs []Expr) []interface{} {
+	nodes := make([]interface{}, len(exprs))
+	for idx, expr := range exprs {
+		nodes[idx] = ae.expr(expr)
+	}
+
+	return nodes
+}
+
+func (ae *astEncoder) stmt(stmt Stmt) interface{} {
+	if stmt == nil {
+		return nil
+	}
+
+	stmt.Accept(ae)
+	return ae.node
+}
+
+func (ae *astEncoder) stmts(stmts []Stmt) []interface{} {
+	nodes := make([]interface{}, len(stmts))
+	for idx, stmt := range stmts {
+		nodes[idx] = ae.stmt(stmt)
+	}
+
+	return nodes
+}
+
+func (ae *astEncoder) function(fn *FunctionExpr) jsonObject {
+	return jsonNode("FunctionExpr", "parameters", jsonTokens(fn.Paramters), "body", ae.stmts(fn.Body))
+}
+
+func jsonToken(t *Token) interface{} {
+	if t == nil {
+		return nil
+	}
+
+	obj := jsonObject{
+		{"lexeme", t.Lexeme},
+		{"line", t.Line},
+		{"column", t.Column},
+		{"offset", t.Offset},
+		{"length", t.Length},
+	}
+	if t.File != "" {
+		obj = append(obj, jsonField{"file", t.File})
+	}
+
+	return obj
+}
+
+func jsonTokens(ts []*Token) []interface{} {
+	objs := make([]interface{}, len(ts))
+	for idx, t := range ts {
+		objs[idx] = jsonToken(t)
+	}
+
+	return objs
+}
+
+func (ae *astEncoder) VisitBinaryExpr(expr *Binary) (interface{}, error) {
+	return jsonNode("Binary", "left", ae.expr(expr.Left), "operator", jsonToken(expr.Operator), "right", ae.expr(expr.Right)), nil
+}
+
+func (ae *astEncoder) VisitGroupingExpr(expr *Grouping) (interface{}, error) {
+	return jsonNode("Grouping", "expression", ae.expr(expr.Expression)), nil
+}
+
+func (ae *astEncoder) VisitLiteralExpr(expr *Literal) (interface{}, error) {
+	return jsonNode("Literal", "kind", literalKind(expr.Value), "value", expr.Value), nil
+}
+
+// literalKind names the type of the value of a literal, so that 2 and 2.0
+// can be told apart.
+func literalKind(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "nil"
+	case bool:
+		return "bool"
+	case int64:
+		return "int"
+	case float64:
+		return "float"
+	default:
+		return "string"
+	}
+}
+
+func (ae *astEncoder) VisitUnaryExpr(expr *Unary) (interface{}, error) {
+	return jsonNode("Unary", "operator", jsonToken(expr.Operator), "right", ae.expr(expr.Right)), nil
+}
+
+func (ae *astEncoder) VisitConditionalExpr(expr *Conditional) (interface{}, error) {
+	return jsonNode("Conditional", "condition", ae.expr(expr.Cond), "consequent", ae.expr(expr.Consequent), "alternate", ae.expr(expr.Alternate)), nil
+}
+
+func (ae *astEncoder) VisitVariableExpr(expr *Variable) (interface{}, error) {
+	return jsonNode("Variable", "name", jsonToken(expr.Name)), nil
+}
+
+func (ae *astEncoder) VisitAssignExpr(expr *Assign) (interface{}, error) {
+	return jsonNode("Assign", "name", jsonToken(expr.Name), "value", ae.expr(expr.Value)), nil
+}
+
+func (ae *astEncoder) VisitLogicalExpr(expr *Logical) (interface{}, error) {
+	return jsonNode("Logical", "left", ae.expr(expr.Left), "operator", jsonToken(expr.Operator), "right", ae.expr(expr.Right)), nil
+}
+
+func (ae *astEncoder) VisitCallExpr(expr *Call) (interface{}, error) {
+	return jsonNode("Call", "callee", ae.expr(expr.Callee), "paren", jsonToken(expr.Paren), "arguments", ae.exprs(expr.Arguments)), nil
+}
+
+func (ae *astEncoder) VisitFunctionExprExpr(expr *FunctionExpr) (interface{}, error) {
+	return ae.function(expr), nil
+}
+
+func (ae *astEncoder) VisitGetExpr(expr *Get) (interface{}, error) {
+	return jsonNode("Get", "object", ae.expr(expr.Object), "name", jsonToken(expr.Name)), nil
+}
+
+func (ae *astEncoder) VisitSetExpr(expr *Set) (interface{}, error) {
+	return jsonNode("Set", "object", ae.expr(expr.Object), "name", jsonToken(expr.Name), "value", ae.expr(expr.Value)), nil
+}
+
+func (ae *astEncoder) VisitThisExpr(expr *This) (interface{}, error) {
+	return jsonNode("This", "keyword", jsonToken(expr.Keyword)), nil
+}
+
+func (ae *astEncoder) VisitSuperExpr(expr *Super) (interface{}, error) {
+	return jsonNode("Super", "keyword", jsonToken(expr.Keyword), "method", jsonToken(expr.Method)), nil
+}
+
+func (ae *astEncoder) VisitListLiteralExpr(expr *ListLiteral) (interface{}, error) {
+	return jsonNode("ListLiteral", "bracket", jsonToken(expr.Bracket), "elements", ae.exprs(expr.Elements)), nil
+}
+
+func (ae *astEncoder) VisitIndexExpr(expr *Index) (interface{}, error) {
+	return jsonNode("Index", "object", ae.expr(expr.Object), "bracket", jsonToken(expr.Bracket), "index", ae.expr(expr.Index)), nil
+}
+
+func (ae *astEncoder) VisitSetIndexExpr(expr *SetIndex) (interface{}, error) {
+	return jsonNode("SetIndex", "object", ae.expr(expr.Object), "bracket", jsonToken(expr.Bracket), "index", ae.expr(expr.Index), "value", ae.expr(expr.Value)), nil
+}
+
+func (ae *astEncoder) VisitMapLiteralExpr(expr *MapLiteral) (interface{}, error) {
+	return jsonNode("MapLiteral", "brace", jsonToken(expr.Brace), "keys", ae.exprs(expr.Keys), "values", ae.exprs(expr.Values)), nil
+}
+
+func (ae *astEncoder) VisitInterpolatedExpr(expr *Interpolated) (interface{}, error) {
+	return jsonNode("Interpolated", "expression", ae.expr(expr.Expression)), nil
+}
+
+func (ae *astEncoder) VisitExpressionStmt(stmt *Expression) error {
+	ae.node = jsonNode("Expression", "expression", ae.expr(stmt.Expression))
+	return nil
+}
+
+func (ae *astEncoder) VisitPrintStmt(stmt *Print) error {
+	ae.node = jsonNode("Print", "keyword", jsonToken(stmt.Keyword), "expression", ae.expr(stmt.Expression))
+	return nil
+}
+
+func (ae *astEncoder) VisitVarStmt(stmt *Var) error {
+	ae.node = jsonNode("Var", "name", jsonToken(stmt.Name), "initializer", ae.expr(stmt.Initializer))
+	return nil
+}
+
+func (ae *astEncoder) VisitBlockStmt(stmt *Block) error {
+	ae.node = jsonNode("Block", "statements", ae.stmts(stmt.Statements))
+	return nil
+}
+
+func (ae *astEncoder) VisitIfStmt(stmt *If) error {
+	ae.node = jsonNode("If", "keyword", jsonToken(stmt.Keyword), "condition", ae.expr(stmt.Condition), "thenBranch", ae.stmt(stmt.ThenBranch), "elseBranch", ae.stmt(stmt.ElseBranch))
+	return nil
+}
+
+func (ae *astEncoder) VisitWhileStmt(stmt *While) error {
+	ae.node = jsonNode("While", "keyword", jsonToken(stmt.Keyword), "condition", ae.expr(stmt.Condition), "body", ae.stmt(stmt.Body))
+	return nil
+}
+
+func (ae *astEncoder) VisitBreakStmt(stmt *Break) error {
+	ae.node = jsonNode("Break", "keyword", jsonToken(stmt.Keyword))
+	return nil
+}
+
+func (ae *astEncoder) VisitFunctionStmt(stmt *Function) error {
+	ae.node = jsonNode("Function", "name", jsonToken(stmt.Name), "function", ae.function(&stmt.Function))
+	return nil
+}
+
+func (ae *astEncoder) VisitThrowStmt(stmt *Throw) error {
+	ae.node = jsonNode("Throw", "keyword", jsonToken(stmt.Keyword), "value", ae.expr(stmt.Value))
+	return nil
+}
+
+func (ae *astEncoder) VisitTryStmt(stmt *Try) error {
+	var finallyBody interface{}
+	if stmt.FinallyBody != nil {
+		finallyBody = ae.stmts(stmt.FinallyBody)
+	}
+
+	var catchBody interface{}
+	if stmt.CatchName != nil {
+		catchBody = ae.stmts(stmt.CatchBody)
+	}
+
+	ae.node = jsonNode("Try", "keyword", jsonToken(stmt.Keyword), "body", ae.stmts(stmt.Body), "catchName", jsonToken(stmt.CatchName), "catchBody", catchBody, "finallyBody", finallyBody)
+	return nil
+}
+
+func (ae *astEncoder) VisitReturnStmt(stmt *Return) error {
+	ae.node = jsonNode("Return", "keyword", jsonToken(stmt.Keyword), "value", ae.expr(stmt.Value))
+	return nil
+}
+
+func (ae *astEncoder) VisitClassStmt(stmt *Class) error {
+	var superclass interface{}
+	if stmt.Superclass != nil {
+		superclass, _ = ae.VisitVariableExpr(stmt.Superclass)
+	}
+
+	methods := make([]interface{}, len(stmt.Methods))
+	for idx := range stmt.Methods {
+		method := &stmt.Methods[idx]
+		methods[idx] = jsonNode("Function", "name", jsonToken(method.Name), "function", ae.function(&method.Function))
+	}
+
+	ae.node = jsonNode("Class", "name", jsonToken(stmt.Name), "superclass", superclass, "methods", methods)
+	return nil
+}
+
+func (ae *astEncoder) VisitImportStmt(stmt *Import) error {
+	ae.node = jsonNode("Import", "keyword", jsonToken(stmt.Keyword), "path", jsonToken(stmt.Path), "alias", jsonToken(stmt.Alias), "names", jsonTokens(stmt.Names))
+	return nil
+}
+
+func (ae *astEncoder) VisitExportStmt(stmt *Export) error {
+	ae.node = jsonNode("Export", "keyword", jsonToken(stmt.Keyword), "declaration", ae.stmt(stmt.Declaration))
+	return nil
+}
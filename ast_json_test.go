@@ -0,0 +1,36 @@
+package glox
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMarshalAST(t *testing.T) {
+	stmts, err := ParseAST("print 2 < 2.0;")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	encoded, err := MarshalAST(stmts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{`"kind": "int",`, `"kind": "float",`, `"lexeme": "<",`} {
+		if !strings.Contains(string(encoded), want) {
+			t.Errorf("missing %s in:\n%s", want, encoded)
+		}
+	}
+}
+
+func TestAstPrinterNumbers(t *testing.T) {
+	stmts, err := ParseAST("print 1 + 1.0 * 2.5 - 1e21;")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "(print (- (+ 1 (* 1.0 2.5)) 1e21))"
+	if got := (&AstPrinter{}).PrintStmt(stmts[0]); got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
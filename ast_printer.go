@@ -1,47 +1,262 @@
 package glox
 
-// import "fmt"
+import (
+	"strconv"
+	"strings"
+)
 
-// type AstPrinter struct {}
+// AstPrinter prints syntax trees as S-expressions, such as
+// (+ 1 (group (* 2 3))), to debug the parser.
+type AstPrinter struct {
+	// stmt is the text of the last statement visited, since the visitors
+	// of statements only return an error.
+	stmt string
+}
 
-// func (ap *AstPrinter) Print(expr Expr) string {
-// 	val, _ := expr.Accept(ap)
-// 	return val.(string)
-// }
+// Print returns the S-expression of expr.
+func (ap *AstPrinter) Print(expr Expr) string {
+	val, _ := expr.Accept(ap)
+	return val.(string)
+}
 
-// func (ap *AstPrinter) VisitBinaryExpr(expr *Binary) (interface{}, error) {
-// 	return ap.parenthesize(expr.Operator.Lexeme, expr.Left, expr.Right), nil
-// }
+// PrintStmt returns the S-expression of stmt.
+func (ap *AstPrinter) PrintStmt(stmt Stmt) string {
+	stmt.Accept(ap)
+	return ap.stmt
+}
 
-// func (ap *AstPrinter) VisitGroupingExpr(expr *Grouping) (interface{}, error) {
-// 	return ap.parenthesize("group", expr.Expression), nil
-// }
+func (ap *AstPrinter) VisitBinaryExpr(expr *Binary) (interface{}, error) {
+	return ap.parenthesize(expr.Operator.Lexeme, expr.Left, expr.Right), nil
+}
 
-// func (ap *AstPrinter) VisitLiteralExpr(expr *Literal) (interface{}, error) {
-// 	if expr.Value == nil {
-// 		return "nil", nil
-// 	}
+func (ap *AstPrinter) VisitGroupingExpr(expr *Grouping) (interface{}, error) {
+	return ap.parenthesize("group", expr.Expression), nil
+}
 
-// 	return fmt.Sprint(expr.Value), nil
-// }
+func (ap *AstPrinter) VisitLiteralExpr(expr *Literal) (interface{}, error) {
+	if s, isString := expr.Value.(string); isString {
+		return strconv.Quote(s), nil
+	}
 
-// func (ap *AstPrinter) VisitUnaryExpr(expr *Unary) (interface{}, error) {
-// 	return ap.parenthesize(expr.Operator.Lexeme, expr.Right), nil
-// }
+	// print 1.0 as it was written, so that it is not mistaken for 1.
+	if f, isFloat := expr.Value.(float64); isFloat {
+		text := formatNumber(f)
+		if !strings.ContainsAny(text, ".e") {
+			text += ".0"
+		}
+		return text, nil
+	}
 
-// func (ap *AstPrinter) VisitConditionalExpr(expr *Conditional) (interface{}, error) {
-// 	return ap.parenthesize("?:", expr.Cond, expr.Consequent, expr.Alternate), nil
-// }
+	return stringify(expr.Value), nil
+}
 
-// func (ap *AstPrinter) parenthesize(name string, exprs ...Expr) string {
-// 	var buf string
+func (ap *AstPrinter) VisitUnaryExpr(expr *Unary) (interface{}, error) {
+	return ap.parenthesize(expr.Operator.Lexeme, expr.Right), nil
+}
 
-// 	buf += "(" + name
-// 	for _, expr := range exprs {
-// 		val, _ := expr.Accept(ap)
-// 		buf += " " + val.(string)
-// 	}
-// 	buf += ")"
+func (ap *AstPrinter) VisitConditionalExpr(expr *Conditional) (interface{}, error) {
+	return ap.parenthesize("?:", expr.Cond, expr.Consequent, expr.Alternate), nil
+}
 
-// 	return buf
-// }
+func (ap *AstPrinter) VisitVariableExpr(expr *Variable) (interface{}, error) {
+	return expr.Name.Lexeme, nil
+}
+
+func (ap *AstPrinter) VisitAssignExpr(expr *Assign) (interface{}, error) {
+	return ap.parenthesize("= "+expr.Name.Lexeme, expr.Value), nil
+}
+
+func (ap *AstPrinter) VisitLogicalExpr(expr *Logical) (interface{}, error) {
+	return ap.parenthesize(expr.Operator.Lexeme, expr.Left, expr.Right), nil
+}
+
+func (ap *AstPrinter) VisitCallExpr(expr *Call) (interface{}, error) {
+	return ap.parenthesize("call", append([]Expr{expr.Callee}, expr.Arguments...)...), nil
+}
+
+func (ap *AstPrinter) VisitFunctionExprExpr(expr *FunctionExpr) (interface{}, error) {
+	return ap.function("fun", expr), nil
+}
+
+func (ap *AstPrinter) VisitGetExpr(expr *Get) (interface{}, error) {
+	return ap.parenthesize(". "+expr.Name.Lexeme, expr.Object), nil
+}
+
+func (ap *AstPrinter) VisitSetExpr(expr *Set) (interface{}, error) {
+	return ap.parenthesize(".= "+expr.Name.Lexeme, expr.Object, expr.Value), nil
+}
+
+func (ap *AstPrinter) VisitThisExpr(expr *This) (interface{}, error) {
+	return "this", nil
+}
+
+func (ap *AstPrinter) VisitSuperExpr(expr *Super) (interface{}, error) {
+	return "(super " + expr.Method.Lexeme + ")", nil
+}
+
+func (ap *AstPrinter) VisitListLiteralExpr(expr *ListLiteral) (interface{}, error) {
+	return ap.parenthesize("list", expr.Elements...), nil
+}
+
+func (ap *AstPrinter) VisitIndexExpr(expr *Index) (interface{}, error) {
+	return ap.parenthesize("[]", expr.Object, expr.Index), nil
+}
+
+func (ap *AstPrinter) VisitSetIndexExpr(expr *SetIndex) (interface{}, error) {
+	return ap.parenthesize("[]=", expr.Object, expr.Index, expr.Value), nil
+}
+
+func (ap *AstPrinter) VisitMapLiteralExpr(expr *MapLiteral) (interface{}, error) {
+	entries := make([]Expr, 0, 2*len(expr.Keys))
+	for idx, key := range expr.Keys {
+		entries = append(entries, key, expr.Values[idx])
+	}
+
+	return ap.parenthesize("map", entries...), nil
+}
+
+func (ap *AstPrinter) VisitInterpolatedExpr(expr *Interpolated) (interface{}, error) {
+	return ap.parenthesize("str", expr.Expression), nil
+}
+
+func (ap *AstPrinter) VisitExpressionStmt(stmt *Expression) error {
+	ap.stmt = ap.parenthesize(";", stmt.Expression)
+	return nil
+}
+
+func (ap *AstPrinter) VisitPrintStmt(stmt *Print) error {
+	ap.stmt = ap.parenthesize("print", stmt.Expression)
+	return nil
+}
+
+func (ap *AstPrinter) VisitVarStmt(stmt *Var) error {
+	if stmt.Initializer == nil {
+		ap.stmt = "(var " + stmt.Name.Lexeme + ")"
+	} else {
+		ap.stmt = ap.parenthesize("var "+stmt.Name.Lexeme, stmt.Initializer)
+	}
+	return nil
+}
+
+func (ap *AstPrinter) VisitBlockStmt(stmt *Block) error {
+	ap.stmt = ap.block("block", stmt.Statements)
+	return nil
+}
+
+func (ap *AstPrinter) VisitIfStmt(stmt *If) error {
+	parts := []string{"if", ap.Print(stmt.Condition), ap.PrintStmt(stmt.ThenBranch)}
+	if stmt.ElseBranch != nil {
+		parts = append(parts, ap.PrintStmt(stmt.ElseBranch))
+	}
+
+	ap.stmt = "(" + strings.Join(parts, " ") + ")"
+	return nil
+}
+
+func (ap *AstPrinter) VisitWhileStmt(stmt *While) error {
+	ap.stmt = "(while " + ap.Print(stmt.Condition) + " " + ap.PrintStmt(stmt.Body) + ")"
+	return nil
+}
+
+func (ap *AstPrinter) VisitBreakStmt(stmt *Break) error {
+	ap.stmt = "(break)"
+	return nil
+}
+
+func (ap *AstPrinter) VisitFunctionStmt(stmt *Function) error {
+	ap.stmt = ap.function("fun "+stmt.Name.Lexeme, &stmt.Function)
+	return nil
+}
+
+func (ap *AstPrinter) VisitThrowStmt(stmt *Throw) error {
+	ap.stmt = ap.parenthesize("throw", stmt.Value)
+	return nil
+}
+
+func (ap *AstPrinter) VisitTryStmt(stmt *Try) error {
+	parts := []string{"try", ap.block("block", stmt.Body)}
+	if stmt.CatchName != nil {
+		parts = append(parts, ap.block("catch "+stmt.CatchName.Lexeme, stmt.CatchBody))
+	}
+	if stmt.FinallyBody != nil {
+		parts = append(parts, ap.block("finally", stmt.FinallyBody))
+	}
+
+	ap.stmt = "(" + strings.Join(parts, " ") + ")"
+	return nil
+}
+
+func (ap *AstPrinter) VisitReturnStmt(stmt *Return) error {
+	if stmt.Value == nil {
+		ap.stmt = "(return)"
+	} else {
+		ap.stmt = ap.parenthesize("return", stmt.Value)
+	}
+	return nil
+}
+
+func (ap *AstPrinter) VisitClassStmt(stmt *Class) error {
+	parts := []string{"class " + stmt.Name.Lexeme}
+	if stmt.Superclass != nil {
+		parts = append(parts, "< "+stmt.Superclass.Name.Lexeme)
+	}
+	for idx := range stmt.Methods {
+		parts = append(parts, ap.function("method "+stmt.Methods[idx].Name.Lexeme, &stmt.Methods[idx].Function))
+	}
+
+	ap.stmt = "(" + strings.Join(parts, " ") + ")"
+	return nil
+}
+
+func (ap *AstPrinter) VisitImportStmt(stmt *Import) error {
+	parts := []string{"import", stmt.Path.Lexeme}
+	if stmt.Alias != nil {
+		parts = append(parts, "as", stmt.Alias.Lexeme)
+	}
+	for _, name := range stmt.Names {
+		parts = append(parts, name.Lexeme)
+	}
+
+	ap.stmt = "(" + strings.Join(parts, " ") + ")"
+	return nil
+}
+
+func (ap *AstPrinter) VisitExportStmt(stmt *Export) error {
+	ap.stmt = "(export " + ap.PrintStmt(stmt.Declaration) + ")"
+	return nil
+}
+
+func (ap *AstPrinter) parenthesize(name string, exprs ...Expr) string {
+	var buf strings.Builder
+
+	buf.WriteString("(" + name)
+	for _, expr := range exprs {
+		buf.WriteString(" " + ap.Print(expr))
+	}
+	buf.WriteString(")")
+
+	return buf.String()
+}
+
+// block prints a list of statements under name.
+func (ap *AstPrinter) block(name string, stmts []Stmt) string {
+	var buf strings.Builder
+
+	buf.WriteString("(" + name)
+	for _, stmt := range stmts {
+		buf.WriteString(" " + ap.PrintStmt(stmt))
+	}
+	buf.WriteString(")")
+
+	return buf.String()
+}
+
+// function prints the parameters and the body of a function under name.
+func (ap *AstPrinter) function(name string, fn *FunctionExpr) string {
+	params := make([]string, len(fn.Paramters))
+	for idx, param := range fn.Paramters {
+		params[idx] = param.Lexeme
+	}
+
+	return ap.block(name+" ("+strings.Join(params, " ")+")", fn.Body)
+}
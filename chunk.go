@@ -0,0 +1,94 @@
+package glox
+
+// OpCode is a one-byte instruction of the bytecode backend. The comment on
+// each opcode lists its operands; "k" is a two-byte index into the constant
+// pool, "s" a one-byte slot and "o" a two-byte jump offset.
+type OpCode byte
+
+const (
+	OP_CONSTANT      OpCode = iota // k
+	OP_NIL
+	OP_TRUE
+	OP_FALSE
+	OP_POP
+	OP_GET_LOCAL     // s
+	OP_SET_LOCAL     // s
+	OP_GET_GLOBAL    // k
+	OP_DEFINE_GLOBAL // k
+	OP_SET_GLOBAL    // k
+	OP_GET_UPVALUE   // s
+	OP_SET_UPVALUE   // s
+	OP_GET_PROPERTY  // k
+	OP_SET_PROPERTY  // k
+	OP_GET_SUPER     // k
+	OP_EQUAL
+	OP_GREATER
+	OP_GREATER_EQUAL
+	OP_LESS
+	OP_LESS_EQUAL
+	OP_ADD
+	OP_SUBTRACT
+	OP_MULTIPLY
+	OP_DIVIDE
+	OP_FLOOR_DIVIDE
+	OP_NOT
+	OP_NEGATE
+	OP_STRINGIFY
+	OP_PRINT
+	OP_JUMP          // o
+	OP_JUMP_IF_FALSE // o
+	OP_LOOP          // o
+	OP_CALL          // argument count
+	OP_CLOSURE       // k, then a (isLocal, index) byte pair per upvalue
+	OP_CLOSE_UPVALUE
+	OP_RETURN
+	OP_CLASS         // k
+	OP_INHERIT
+	OP_METHOD        // k
+	OP_BUILD_LIST    // element count
+	OP_BUILD_MAP     // entry count, the stack holds key, value pairs
+	OP_APPEND_LIST   // element count, appended to the list below them
+	OP_APPEND_MAP    // entry count, added to the map below them
+	OP_GET_INDEX
+	OP_SET_INDEX
+	OP_TRY           // o, to the catch handler
+	OP_TRY_FINALLY   // o, to the handler that runs the finally clause
+	OP_END_TRY
+	OP_THROW
+	OP_IMPORT        // k, the path of the module
+)
+
+// Chunk is a sequence of bytecode together with the data it refers to.
+type Chunk struct {
+	Code []byte
+
+	// Constants is the constant pool. Instructions refer to the literals,
+	// names and nested functions they need by their index in the pool.
+	Constants []interface{}
+
+	// Lines stores the source line of every byte in Code, so that runtime
+	// errors can be reported at the right line.
+	Lines []uint32
+}
+
+// Write appends a byte to the chunk.
+func (c *Chunk) Write(b byte, line uint32) {
+	c.Code = append(c.Code, b)
+	c.Lines = append(c.Lines, line)
+}
+
+// AddConstant adds a value to the constant pool and returns its index.
+// Strings and numbers are deduplicated.
+func (c *Chunk) AddConstant(value interface{}) int {
+	switch value.(type) {
+	case string, int64, float64:
+		for idx, constant := range c.Constants {
+			if constant == value {
+				return idx
+			}
+		}
+	}
+
+	c.Constants = append(c.Constants, value)
+	return len(c.Constants) - 1
+}
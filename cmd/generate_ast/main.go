@@ -29,26 +29,35 @@ func main() {
 		"Set          : Object Expr, Name *Token, Value Expr",
 		"This         : Keyword *Token",
 		"Super        : Keyword *Token, Method *Token",
+		"ListLiteral  : Bracket *Token, Elements []Expr",
+		"Index        : Object Expr, Bracket *Token, Index Expr",
+		"SetIndex     : Object Expr, Bracket *Token, Index Expr, Value Expr",
+		"MapLiteral   : Brace *Token, Keys []Expr, Values []Expr",
+		"Interpolated : Expression Expr", // an expression inside "${}", whose value is turned into a string
 	})
 
 	defineAst(outputDir, "Stmt", []string{
 		"Expression   : Expression Expr",
-		"Print        : Expression Expr",
+		"Print        : Keyword *Token, Expression Expr",
 		"Var          : Name *Token, Initializer Expr",
 		"Block        : Statements []Stmt",
-		"If           : Condition Expr, ThenBranch Stmt, ElseBranch Stmt",
-		"While        : Condition Expr, Body Stmt",
-		"Break        : ",
+		"If           : Keyword *Token, Condition Expr, ThenBranch Stmt, ElseBranch Stmt",
+		"While        : Keyword *Token, Condition Expr, Body Stmt",
+		"Break        : Keyword *Token",
 		"Function     : Name *Token, Function FunctionExpr",
+		"Throw        : Keyword *Token, Value Expr",
+		"Try          : Keyword *Token, Body []Stmt, CatchName *Token, CatchBody []Stmt, FinallyBody []Stmt",
 		"Return       : Keyword *Token, Value Expr",
 		"Class        : Name *Token, Superclass *Variable, Methods []Function",
+		"Import       : Keyword *Token, Path *Token, Alias *Token, Names []*Token, Dir string",
+		"Export       : Keyword *Token, Declaration Stmt",
 	})
 }
 
 func defineAst(outputDir string, baseName string, types []string) {
 	path := outputDir + "/" + strings.ToLower(baseName) + ".go"
 
-	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE, 0644)
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
 	if err != nil {
 		panic(err)
 	}
@@ -0,0 +1,16 @@
+package main
+
+import (
+	"fmt"
+	"glox/dap"
+	"os"
+)
+
+// glox-dap is a debug adapter for Lox. Editors start it and talk to it over
+// stdin and stdout using the Debug Adapter Protocol.
+func main() {
+	if err := dap.NewServer(os.Stdin, os.Stdout).Run(); err != nil {
+		fmt.Fprintln(os.Stderr, "glox-dap:", err)
+		os.Exit(1)
+	}
+}
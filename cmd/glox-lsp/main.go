@@ -0,0 +1,16 @@
+package main
+
+import (
+	"fmt"
+	"glox/lsp"
+	"os"
+)
+
+// glox-lsp is a language server for Lox. Editors start it and talk to it
+// over stdin and stdout.
+func main() {
+	if err := lsp.NewServer(os.Stdin, os.Stdout).Run(); err != nil {
+		fmt.Fprintln(os.Stderr, "glox-lsp:", err)
+		os.Exit(1)
+	}
+}
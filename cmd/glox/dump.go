@@ -0,0 +1,92 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"glox"
+	"os"
+)
+
+// dumpAST prints the syntax tree of the script at path, as S-expressions
+// or as JSON, and returns the exit code.
+func dumpAST(path string, format string) int {
+	if format != "sexp" && format != "json" {
+		fmt.Fprintln(os.Stderr, "Unknown AST format:", format)
+		return 64
+	}
+
+	bytes, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 66
+	}
+
+	stmts, err := glox.ParseAST(string(bytes))
+	if err != nil {
+		var compileErr *glox.CompileError
+		if errors.As(err, &compileErr) {
+			for _, syntaxErr := range compileErr.Errors {
+				fmt.Fprintf(os.Stderr, "%s: %s\n", path, syntaxErr)
+			}
+			return 65
+		}
+
+		fmt.Fprintf(os.Stderr, "%s: %s\n", path, err)
+		return 70
+	}
+
+	if format == "json" {
+		encoded, err := glox.MarshalAST(stmts)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", path, err)
+			return 70
+		}
+
+		fmt.Println(string(encoded))
+		return 0
+	}
+
+	printer := &glox.AstPrinter{}
+	for _, stmt := range stmts {
+		fmt.Println(printer.PrintStmt(stmt))
+	}
+	return 0
+}
+
+// printTokens prints the tokens of the script at path, comments included,
+// one per line with their position, type, lexeme and literal, and returns
+// the exit code. Scanning errors are reported as they are met.
+func printTokens(path string) int {
+	bytes, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 66
+	}
+	source := string(bytes)
+
+	errorPrinter := glox.NewErrorPrinter(os.Stderr)
+	errorPrinter.SetSource(source)
+
+	scanner := glox.NewScanner(source, errorPrinter)
+	scanner.SetKeepComments(true)
+
+	for {
+		token := scanner.NextToken()
+
+		position := fmt.Sprintf("%d:%d", token.Line, token.Column)
+		fmt.Printf("%-8s %-13s %s", position, token.Type, token.Lexeme)
+		if token.Literal != nil {
+			fmt.Printf(" (%s)", glox.FormatValue(token.Literal))
+		}
+		fmt.Println()
+
+		if token.Type == glox.EOF {
+			break
+		}
+	}
+
+	if errorPrinter.HadError() {
+		return 65
+	}
+	return 0
+}
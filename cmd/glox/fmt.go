@@ -0,0 +1,297 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"glox"
+	"os"
+	"sort"
+	"strings"
+)
+
+// runFmt runs "glox fmt" and returns the exit code. Without flags it
+// prints the formatted files; -w rewrites them and -d prints a diff.
+func runFmt(args []string) int {
+	flags := flag.NewFlagSet("fmt", flag.ContinueOnError)
+	write := flags.Bool("w", false, "write the result to the file instead of printing it")
+	diff := flags.Bool("d", false, "print a diff instead of the formatted source")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: glox fmt [-w] [-d] file...")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil || flags.NArg() == 0 {
+		flags.Usage()
+		return 64
+	}
+
+	code := 0
+	for _, path := range flags.Args() {
+		bytes, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			code = 66
+			continue
+		}
+		source := string(bytes)
+
+		formatted, err := glox.Format(source)
+		if err != nil {
+			var compileErr *glox.CompileError
+			if errors.As(err, &compileErr) {
+				for _, syntaxErr := range compileErr.Errors {
+					fmt.Fprintf(os.Stderr, "%s: %s\n", path, syntaxErr)
+				}
+				code = 65
+			} else {
+				fmt.Fprintf(os.Stderr, "%s: %s\n", path, err)
+				code = 70
+			}
+			continue
+		}
+
+		if *diff && formatted != source {
+			fmt.Print(unifiedDiff(path, source, formatted))
+		}
+
+		if *write {
+			if formatted != source {
+				if err := writeKeepingMode(path, formatted); err != nil {
+					fmt.Fprintln(os.Stderr, err)
+					code = 73
+				}
+			}
+		} else if !*diff {
+			fmt.Print(formatted)
+		}
+	}
+
+	return code
+}
+
+// diffContext is the number of unchanged lines shown around a change.
+const diffContext = 3
+
+// unifiedDiff returns the changes from a to b in the unified format.
+func unifiedDiff(path string, a string, b string) string {
+	before, after := splitLines(a), splitLines(b)
+
+	edits := diffLines(before, after)
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s (formatted)\n", path, path)
+
+	for start := 0; start < len(edits); {
+		if edits[start].op == ' ' {
+			start++
+			continue
+		}
+
+		// a hunk runs from the first change to the last change that is
+		// followed by fewer than twice the context of unchanged lines.
+		end := start
+		for next := start; next < len(edits); next++ {
+			if edits[next].op != ' ' {
+				end = next + 1
+			} else if next-end >= 2*diffContext {
+				break
+			}
+		}
+
+		from := start - diffContext
+		if from < 0 {
+			from = 0
+		}
+		to := end + diffContext
+		if to > len(edits) {
+			to = len(edits)
+		}
+
+		countA, countB := 0, 0
+		for _, e := range edits[from:to] {
+			if e.op != '+' {
+				countA++
+			}
+			if e.op != '-' {
+				countB++
+			}
+		}
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n", hunkRange(edits[from].a, countA), hunkRange(edits[from].b, countB))
+		for _, e := range edits[from:to] {
+			fmt.Fprintf(&out, "%c%s\n", e.op, e.line)
+		}
+
+		start = to
+	}
+
+	return out.String()
+}
+
+// edit is one line of an edit script: op is ' ' for a kept line, '-' for a
+// removed one and '+' for an added one. a and b are the indexes of the line,
+// or of the next line, in the old and new text.
+type edit struct {
+	op   byte
+	line string
+	a, b int
+}
+
+// diffLines returns a shortest edit script from before to after, with the
+// removed lines of each change ahead of the added ones. It uses the linear
+// space variant of Myers' algorithm, "An O(ND) Difference Algorithm and Its
+// Variations", so large files with few changes are cheap to compare.
+func diffLines(before []string, after []string) []edit {
+	d := &differ{before: before, after: after}
+	d.compare(0, len(before), 0, len(after))
+
+	// put the removals of each change first, then number the lines.
+	for start := 0; start < len(d.edits); start++ {
+		end := start
+		for end < len(d.edits) && d.edits[end].op != ' ' {
+			end++
+		}
+		sort.SliceStable(d.edits[start:end], func(i, j int) bool {
+			return d.edits[start+i].op == '-' && d.edits[start+j].op == '+'
+		})
+		start = end
+	}
+
+	i, j := 0, 0
+	for n := range d.edits {
+		d.edits[n].a, d.edits[n].b = i, j
+		if d.edits[n].op != '+' {
+			i++
+		}
+		if d.edits[n].op != '-' {
+			j++
+		}
+	}
+
+	return d.edits
+}
+
+// differ builds the edit script of diffLines.
+type differ struct {
+	before, after []string
+	edits         []edit
+}
+
+// compare appends the edits that turn before[aLo:aHi] into after[bLo:bHi].
+func (d *differ) compare(aLo int, aHi int, bLo int, bHi int) {
+	for aLo < aHi && bLo < bHi && d.before[aLo] == d.after[bLo] {
+		d.edits = append(d.edits, edit{op: ' ', line: d.before[aLo]})
+		aLo, bLo = aLo+1, bLo+1
+	}
+	suffix := 0
+	for aLo < aHi-suffix && bLo < bHi-suffix && d.before[aHi-1-suffix] == d.after[bHi-1-suffix] {
+		suffix++
+	}
+	aHi, bHi = aHi-suffix, bHi-suffix
+
+	switch {
+	case aLo == aHi:
+		for _, line := range d.after[bLo:bHi] {
+			d.edits = append(d.edits, edit{op: '+', line: line})
+		}
+	case bLo == bHi:
+		for _, line := range d.before[aLo:aHi] {
+			d.edits = append(d.edits, edit{op: '-', line: line})
+		}
+	default:
+		x, y, u, v := d.middleSnake(aLo, aHi, bLo, bHi)
+		d.compare(aLo, x, bLo, y)
+		for _, line := range d.before[x:u] {
+			d.edits = append(d.edits, edit{op: ' ', line: line})
+		}
+		d.compare(u, aHi, v, bHi)
+	}
+
+	for _, line := range d.before[aHi : aHi+suffix] {
+		d.edits = append(d.edits, edit{op: ' ', line: line})
+	}
+}
+
+// middleSnake returns the start (x, y) and the end (u, v) of the diagonal
+// run of equal lines in the middle of a shortest edit script from
+// before[aLo:aHi] to after[bLo:bHi]. It searches forward from the start and
+// backward from the end at the same time until the two paths meet.
+func (d *differ) middleSnake(aLo int, aHi int, bLo int, bHi int) (x, y, u, v int) {
+	n, m := aHi-aLo, bHi-bLo
+	delta := n - m
+	limit := (n + m + 1) / 2
+
+	// forward[k] is the furthest x reached on the diagonal x-y = k from the
+	// start, and backward[c] the furthest distance from the end reached on
+	// the diagonal x-y = delta-c. Both are offset by limit+1.
+	forward := make([]int, 2*limit+3)
+	backward := make([]int, 2*limit+3)
+	offset := limit + 1
+
+	for depth := 0; depth <= limit; depth++ {
+		for k := -depth; k <= depth; k += 2 {
+			var x int
+			if k == -depth || (k != depth && forward[offset+k-1] < forward[offset+k+1]) {
+				x = forward[offset+k+1]
+			} else {
+				x = forward[offset+k-1] + 1
+			}
+			y := x - k
+			startX, startY := x, y
+			for x < n && y < m && d.before[aLo+x] == d.after[bLo+y] {
+				x, y = x+1, y+1
+			}
+			forward[offset+k] = x
+
+			c := delta - k
+			if delta%2 != 0 && c >= -(depth-1) && c <= depth-1 && x+backward[offset+c] >= n {
+				return aLo + startX, bLo + startY, aLo + x, bLo + y
+			}
+		}
+
+		for c := -depth; c <= depth; c += 2 {
+			var x int
+			if c == -depth || (c != depth && backward[offset+c-1] < backward[offset+c+1]) {
+				x = backward[offset+c+1]
+			} else {
+				x = backward[offset+c-1] + 1
+			}
+			y := x - c
+			startX, startY := x, y
+			for x < n && y < m && d.before[aHi-1-x] == d.after[bHi-1-y] {
+				x, y = x+1, y+1
+			}
+			backward[offset+c] = x
+
+			k := delta - c
+			if delta%2 == 0 && k >= -depth && k <= depth && forward[offset+k]+x >= n {
+				return aHi - x, bHi - y, aHi - startX, bHi - startY
+			}
+		}
+	}
+
+	panic("unreachable: the paths always meet")
+}
+
+// hunkRange formats the start and length of a hunk, counting lines from 1.
+func hunkRange(start int, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
+
+func splitLines(text string) []string {
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+// writeKeepingMode replaces the contents of the file at path, keeping its
+// permissions.
+func writeKeepingMode(path string, contents string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, []byte(contents), info.Mode().Perm())
+}
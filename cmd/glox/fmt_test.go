@@ -0,0 +1,89 @@
+package main
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	before := "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\nk\nl\nm\n"
+	after := "a\nB\nc\nd\ne\nf\ng\nh\ni\nj\nk\nl\nm\nn\n"
+
+	want := strings.Join([]string{
+		"--- x.lox",
+		"+++ x.lox (formatted)",
+		"@@ -1,5 +1,5 @@",
+		" a",
+		"-b",
+		"+B",
+		" c",
+		" d",
+		" e",
+		"@@ -11,3 +11,4 @@",
+		" k",
+		" l",
+		" m",
+		"+n",
+		"",
+	}, "\n")
+	if got := unifiedDiff("x.lox", before, after); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+// TestDiffLinesIsShortest checks the edit scripts of random inputs against
+// the length of their longest common subsequence.
+func TestDiffLinesIsShortest(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	lines := func() []string {
+		result := make([]string, random.Intn(30))
+		for i := range result {
+			result[i] = string(rune('a' + random.Intn(4)))
+		}
+		return result
+	}
+
+	for round := 0; round < 500; round++ {
+		before, after := lines(), lines()
+		edits := diffLines(before, after)
+
+		var gotBefore, gotAfter []string
+		changes := 0
+		for _, e := range edits {
+			if e.op != '+' {
+				gotBefore = append(gotBefore, e.line)
+			}
+			if e.op != '-' {
+				gotAfter = append(gotAfter, e.line)
+			}
+			if e.op != ' ' {
+				changes++
+			}
+		}
+		if strings.Join(gotBefore, "") != strings.Join(before, "") || strings.Join(gotAfter, "") != strings.Join(after, "") {
+			t.Fatalf("%q -> %q: the edits don't rebuild both sides", before, after)
+		}
+		if want := len(before) + len(after) - 2*lcsLength(before, after); changes != want {
+			t.Fatalf("%q -> %q: %d changes, want %d", before, after, changes, want)
+		}
+	}
+}
+
+func lcsLength(a []string, b []string) int {
+	row := make([]int, len(b)+1)
+	for i := range a {
+		diagonal := 0
+		for j := range b {
+			next := row[j+1]
+			if a[i] == b[j] {
+				row[j+1] = diagonal + 1
+			} else if row[j] > row[j+1] {
+				row[j+1] = row[j]
+			}
+			diagonal = next
+		}
+	}
+
+	return row[len(b)]
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"glox"
+	"os"
+	"strings"
+)
+
+// runLint runs "glox lint" and returns the exit code: 0 if the files have
+// no warnings, 1 if they have some.
+func runLint(args []string) int {
+	flags := flag.NewFlagSet("lint", flag.ContinueOnError)
+	disable := flags.String("disable", "", "comma-separated codes of the warnings to leave out")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: glox lint [-disable code,...] file...")
+		flags.PrintDefaults()
+		codes := []string{}
+		for _, code := range glox.WarningCodes() {
+			codes = append(codes, string(code))
+		}
+		fmt.Fprintln(flags.Output(), "Warning codes:", strings.Join(codes, ", "))
+	}
+	if err := flags.Parse(args); err != nil || flags.NArg() == 0 {
+		flags.Usage()
+		return 64
+	}
+
+	known := map[glox.WarningCode]bool{}
+	for _, code := range glox.WarningCodes() {
+		known[code] = true
+	}
+
+	disabled := []glox.WarningCode{}
+	for _, code := range strings.Split(*disable, ",") {
+		if code = strings.TrimSpace(code); code == "" {
+			continue
+		}
+
+		if !known[glox.WarningCode(code)] {
+			fmt.Fprintf(flags.Output(), "Unknown warning code: %s\n", code)
+			flags.Usage()
+			return 64
+		}
+		disabled = append(disabled, glox.WarningCode(code))
+	}
+
+	code := 0
+	for _, path := range flags.Args() {
+		bytes, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			code = 66
+			continue
+		}
+
+		warnings, err := glox.Lint(string(bytes), nil, disabled...)
+		if err != nil {
+			var compileErr *glox.CompileError
+			if errors.As(err, &compileErr) {
+				for _, syntaxErr := range compileErr.Errors {
+					fmt.Fprintf(os.Stderr, "%s: %s\n", path, syntaxErr)
+				}
+			}
+			code = 65
+			continue
+		}
+
+		for _, warning := range warnings {
+			fmt.Printf("%s: %s\n", path, warning)
+		}
+		if len(warnings) > 0 && code == 0 {
+			code = 1
+		}
+	}
+
+	return code
+}
@@ -1,13 +1,74 @@
 package main
 
 import (
+	"context"
+	"flag"
+	"fmt"
 	"glox"
 	"os"
 )
 
 func main() {
-	args := os.Args[1:]
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "fmt":
+			os.Exit(runFmt(os.Args[2:]))
+		case "lint":
+			os.Exit(runLint(os.Args[2:]))
+		}
+	}
 
-	g := glox.NewGlox()
-	g.Run(args)
+	backend := flag.String("backend", "tree", "the backend that runs the code: tree or bytecode")
+	debug := flag.Bool("debug", false, "run the script under the textual debugger")
+	maxSteps := flag.Int64("max-steps", 0, "stop the script after this many steps; 0 for no limit")
+	maxDepth := flag.Int("max-depth", glox.DefaultMaxCallDepth, fmt.Sprintf("the maximum depth of calls, at most %d", glox.MaxCallDepthCeiling))
+	timeout := flag.Duration("timeout", 0, "stop the script after this long; 0 for no limit")
+	seed := flag.Int64("seed", 0, "seed math.random; 0 to seed it from the current time")
+	dumpAst := flag.Bool("dump-ast", false, "print the syntax tree of the script instead of running it")
+	astFormat := flag.String("ast-format", "sexp", "the format of -dump-ast: sexp or json")
+	dumpTokens := flag.Bool("dump-tokens", false, "print the tokens of the script instead of running it")
+	flag.Parse()
+
+	if *dumpTokens {
+		if flag.NArg() != 1 {
+			fmt.Fprintln(os.Stderr, "Usage: glox --dump-tokens script")
+			os.Exit(64)
+		}
+		os.Exit(printTokens(flag.Arg(0)))
+	}
+
+	if *dumpAst {
+		if flag.NArg() != 1 {
+			fmt.Fprintln(os.Stderr, "Usage: glox --dump-ast [-ast-format sexp|json] script")
+			os.Exit(64)
+		}
+		os.Exit(dumpAST(flag.Arg(0), *astFormat))
+	}
+
+	opts := &glox.Options{MaxSteps: *maxSteps, MaxCallDepth: *maxDepth, Seed: *seed}
+	if *timeout > 0 {
+		ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+		defer cancel()
+		opts.Context = ctx
+	}
+	switch *backend {
+	case "tree":
+		opts.Backend = glox.TreeWalk
+	case "bytecode":
+		opts.Backend = glox.Bytecode
+	default:
+		fmt.Fprintln(os.Stderr, "Unknown backend:", *backend)
+		os.Exit(64)
+	}
+
+	if *debug {
+		if opts.Backend != glox.TreeWalk || flag.NArg() != 1 {
+			fmt.Fprintln(os.Stderr, "Usage: glox --debug [-backend tree] script")
+			os.Exit(64)
+		}
+		opts.Debugger = glox.NewDebugConsole(flag.Arg(0), os.Stdin, os.Stdout)
+	}
+
+	g := glox.NewGlox(opts)
+	g.Run(flag.Args())
 }
@@ -0,0 +1,1036 @@
+package glox
+
+// funcProto is a function compiled to bytecode. It is the static part of a
+// function; the closure wraps it together with the captured upvalues.
+type funcProto struct {
+	name         string
+	arity        uint32
+	upvalueCount int
+	chunk        Chunk
+
+	// file is the module the function is declared in, empty for the main
+	// script.
+	file string
+}
+
+// local is a local variable living in a stack slot of the current frame.
+type local struct {
+	name  string
+	depth int
+
+	// isCaptured marks that a closure captures the local, so it must be
+	// moved to the heap when it goes out of scope.
+	isCaptured bool
+}
+
+// upvalueRef tells OP_CLOSURE where to find a captured variable: a local of
+// the enclosing function or one of the enclosing function's own upvalues.
+type upvalueRef struct {
+	index   byte
+	isLocal bool
+}
+
+// loopState tracks the innermost loop so that break can jump out of it.
+type loopState struct {
+	enclosing  *loopState
+	scopeDepth int
+	breaks     []int
+}
+
+// tryState tracks a try statement whose handler is installed, so that
+// break and return can uninstall it and run its finally clause on the way
+// out.
+type tryState struct {
+	enclosing  *tryState
+	scopeDepth int
+	loop       *loopState
+
+	// finally is the finally clause, nil if there is none.
+	finally []Stmt
+}
+
+// funcState holds the state of the function currently being compiled.
+// Functions nest, so each one links to the state of its enclosing function.
+type funcState struct {
+	enclosing *funcState
+	proto     *funcProto
+	kind      FunctionType
+
+	locals     []*local
+	upvalues   []upvalueRef
+	scopeDepth int
+
+	loop  *loopState
+	tries *tryState
+}
+
+// classState tracks the innermost class declaration.
+type classState struct {
+	enclosing     *classState
+	hasSuperclass bool
+}
+
+// Compiler compiles the syntax tree into bytecode for the Machine. It
+// walks the same tree as the Interpreter, after the Resolver has checked it,
+// and resolves the local variables and upvalues to stack slots on its own.
+type Compiler struct {
+	errorPrinter *ErrorPrinter
+
+	current      *funcState
+	currentClass *classState
+
+	// line is the line of the most recent token seen. It is recorded for
+	// every emitted byte.
+	line uint32
+
+	// file is recorded in every function. It is set when compiling a
+	// module.
+	file string
+}
+
+func NewCompiler(errorPrinter *ErrorPrinter) *Compiler {
+	return &Compiler{
+		errorPrinter: errorPrinter,
+		line:         1,
+	}
+}
+
+// Compile compiles the statements into the function that represents the
+// top-level script. If the last statement is an expression statement, the
+// script returns its value.
+func (c *Compiler) Compile(statements []Stmt) *funcProto {
+	c.beginFunction("<script>", FunctionType_NONE)
+
+	for idx, stmt := range statements {
+		if expr, isExpression := stmt.(*Expression); isExpression && idx == len(statements)-1 {
+			c.setLine(stmt)
+			c.compileExpr(expr.Expression)
+			c.emitOp(OP_RETURN)
+			return c.endFunction()
+		}
+
+		c.compileStmt(stmt)
+	}
+
+	c.emitOps(OP_NIL, OP_RETURN)
+	return c.endFunction()
+}
+
+/* Implement StmtVisitor interface */
+
+func (c *Compiler) VisitClassStmt(stmt *Class) error {
+	c.line = stmt.Name.Line
+	nameConstant := c.identifierConstant(stmt.Name)
+	c.declareVariable(stmt.Name)
+
+	c.emitOp(OP_CLASS)
+	c.emitShort(nameConstant)
+	c.defineVariable(nameConstant)
+
+	class := &classState{enclosing: c.currentClass}
+	c.currentClass = class
+
+	if stmt.Superclass != nil {
+		c.VisitVariableExpr(stmt.Superclass)
+
+		// the superclass is stored in a local named "super" that the methods
+		// capture, just like any other closure captures a variable.
+		c.beginScope()
+		c.addLocal("super")
+		c.markInitialized()
+
+		c.namedVariable(stmt.Name, false)
+		c.emitOp(OP_INHERIT)
+		class.hasSuperclass = true
+	}
+
+	c.namedVariable(stmt.Name, false)
+	for idx := range stmt.Methods {
+		method := &stmt.Methods[idx]
+		c.line = method.Name.Line
+
+		kind := FunctionType_METHOD
+		if method.Name.Lexeme == "init" {
+			kind = FunctionType_INITIALIZER
+		}
+
+		c.function(method.Name.Lexeme, &method.Function, kind)
+		c.emitOp(OP_METHOD)
+		c.emitShort(c.identifierConstant(method.Name))
+	}
+	c.emitOp(OP_POP)
+
+	if class.hasSuperclass {
+		c.endScope()
+	}
+
+	c.currentClass = class.enclosing
+	return nil
+}
+
+func (c *Compiler) VisitReturnStmt(stmt *Return) error {
+	c.line = stmt.Keyword.Line
+
+	if c.current.tries != nil {
+		c.returnFromTry(stmt)
+		return nil
+	}
+
+	if stmt.Value != nil {
+		c.compileExpr(stmt.Value)
+		c.emitOp(OP_RETURN)
+	} else {
+		c.emitReturn()
+	}
+
+	return nil
+}
+
+// returnFromTry compiles a return inside try statements. The return value
+// is kept in a hidden local while the finally clauses run, and the handlers
+// are uninstalled first, so that the finally clauses can't catch their own
+// errors.
+func (c *Compiler) returnFromTry(stmt *Return) {
+	locals, tries := c.saveLocals(), c.current.tries
+
+	if stmt.Value != nil {
+		c.compileExpr(stmt.Value)
+	} else if c.current.kind == FunctionType_INITIALIZER {
+		c.emitOp(OP_GET_LOCAL)
+		c.emitByte(0)
+	} else {
+		c.emitOp(OP_NIL)
+	}
+	c.addLocal("")
+	c.current.locals[len(c.current.locals)-1].depth = c.current.scopeDepth
+
+	for try := tries; try != nil; try = try.enclosing {
+		c.emitOp(OP_END_TRY)
+		c.current.tries = try.enclosing
+		c.compileFinally(try.finally)
+	}
+
+	c.emitOp(OP_RETURN)
+	c.current.locals, c.current.tries = locals, tries
+}
+
+func (c *Compiler) VisitFunctionStmt(stmt *Function) error {
+	c.line = stmt.Name.Line
+	nameConstant := c.identifierConstant(stmt.Name)
+	c.declareVariable(stmt.Name)
+
+	// a function can refer to itself in its body, so the local is ready
+	// before the body is compiled.
+	if c.current.scopeDepth > 0 {
+		c.markInitialized()
+	}
+
+	c.function(stmt.Name.Lexeme, &stmt.Function, FunctionType_FUNCTION)
+	c.defineVariable(nameConstant)
+	return nil
+}
+
+func (c *Compiler) VisitBreakStmt(stmt *Break) error {
+	loop := c.current.loop
+	locals, tries := c.saveLocals(), c.current.tries
+
+	// leave the try statements inside the loop body, running their finally
+	// clauses with the locals they can see still on the stack.
+	for try := tries; try != nil && try.loop == loop; try = try.enclosing {
+		c.discardLocals(try.scopeDepth)
+		c.emitOp(OP_END_TRY)
+		c.current.tries = try.enclosing
+		c.compileFinally(try.finally)
+	}
+
+	// discard the locals declared inside the loop body.
+	c.discardLocals(loop.scopeDepth)
+	loop.breaks = append(loop.breaks, c.emitJump(OP_JUMP))
+
+	c.current.locals, c.current.tries = locals, tries
+	return nil
+}
+
+// saveLocals returns a copy of the list of locals, to be restored after
+// compiling code that leaves scopes early. The locals themselves are
+// shared, so that captures recorded meanwhile are kept.
+func (c *Compiler) saveLocals() []*local {
+	return append([]*local(nil), c.current.locals...)
+}
+
+// discardLocals emits the code that discards the locals deeper than depth,
+// without ending their scopes. It is used by the jumps that leave scopes
+// early.
+func (c *Compiler) discardLocals(depth int) {
+	locals := c.current.locals
+	for len(locals) > 0 && locals[len(locals)-1].depth > depth {
+		if locals[len(locals)-1].isCaptured {
+			c.emitOp(OP_CLOSE_UPVALUE)
+		} else {
+			c.emitOp(OP_POP)
+		}
+		locals = locals[:len(locals)-1]
+	}
+	c.current.locals = locals
+}
+
+// VisitImportStmt compiles an import like variable declarations. For
+// imported names, the module is looked up again for every name; only the
+// first lookup runs the module.
+func (c *Compiler) VisitImportStmt(stmt *Import) error {
+	c.line = stmt.Keyword.Line
+	path := c.makeConstant(importPath(stmt))
+
+	if stmt.Alias != nil {
+		nameConstant := c.identifierConstant(stmt.Alias)
+		c.declareVariable(stmt.Alias)
+		c.emitOp(OP_IMPORT)
+		c.emitShort(path)
+		c.defineVariable(nameConstant)
+		return nil
+	}
+
+	for _, name := range stmt.Names {
+		nameConstant := c.identifierConstant(name)
+		c.declareVariable(name)
+		c.emitOp(OP_IMPORT)
+		c.emitShort(path)
+		c.line = name.Line
+		c.emitOp(OP_GET_PROPERTY)
+		c.emitShort(nameConstant)
+		c.defineVariable(nameConstant)
+	}
+
+	return nil
+}
+
+func (c *Compiler) VisitExportStmt(stmt *Export) error {
+	c.compileStmt(stmt.Declaration)
+	return nil
+}
+
+func (c *Compiler) VisitThrowStmt(stmt *Throw) error {
+	c.compileExpr(stmt.Value)
+
+	c.line = stmt.Keyword.Line
+	c.emitOp(OP_THROW)
+	return nil
+}
+
+// VisitTryStmt compiles a try statement. OP_TRY installs a handler that
+// the Machine jumps to when an error is raised, with the stack cut back to
+// the height it had at OP_TRY and the error value pushed. The finally
+// clause is compiled once for every way out of the statement.
+func (c *Compiler) VisitTryStmt(stmt *Try) error {
+	c.line = stmt.Keyword.Line
+
+	handlerOp := OP_TRY
+	if stmt.CatchName == nil {
+		handlerOp = OP_TRY_FINALLY
+	}
+
+	handler := c.emitJump(handlerOp)
+	c.beginTry(stmt.FinallyBody)
+	c.compileBlock(stmt.Body)
+	c.endTry()
+	c.emitOp(OP_END_TRY)
+	c.compileFinally(stmt.FinallyBody)
+	exitJump := c.emitJump(OP_JUMP)
+
+	c.patchJump(handler)
+	if stmt.CatchName == nil {
+		c.rethrowAfterFinally(stmt.FinallyBody)
+		c.patchJump(exitJump)
+		return nil
+	}
+
+	// the error value pushed by the Machine becomes the catch variable.
+	c.beginScope()
+	c.addLocal(stmt.CatchName.Lexeme)
+	c.markInitialized()
+
+	if stmt.FinallyBody == nil {
+		for _, s := range stmt.CatchBody {
+			c.compileStmt(s)
+		}
+		c.endScope()
+		c.patchJump(exitJump)
+		return nil
+	}
+
+	// errors raised by the catch clause still run the finally clause.
+	catchHandler := c.emitJump(OP_TRY_FINALLY)
+	c.beginTry(stmt.FinallyBody)
+	for _, s := range stmt.CatchBody {
+		c.compileStmt(s)
+	}
+	c.endTry()
+	c.emitOp(OP_END_TRY)
+	catchExitJump := c.emitJump(OP_JUMP)
+
+	c.patchJump(catchHandler)
+	c.rethrowAfterFinally(stmt.FinallyBody)
+
+	c.patchJump(catchExitJump)
+	c.endScope()
+	c.compileFinally(stmt.FinallyBody)
+	c.patchJump(exitJump)
+	return nil
+}
+
+func (c *Compiler) beginTry(finally []Stmt) {
+	c.current.tries = &tryState{
+		enclosing:  c.current.tries,
+		scopeDepth: c.current.scopeDepth,
+		loop:       c.current.loop,
+		finally:    finally,
+	}
+}
+
+func (c *Compiler) endTry() {
+	c.current.tries = c.current.tries.enclosing
+}
+
+// compileFinally compiles a finally clause, which may be nil, in a scope
+// of its own.
+func (c *Compiler) compileFinally(finally []Stmt) {
+	if finally == nil {
+		return
+	}
+
+	c.compileBlock(finally)
+}
+
+// rethrowAfterFinally compiles the handler installed by OP_TRY_FINALLY. It
+// keeps the pending error in a hidden local, runs the finally clause and
+// throws the error again.
+func (c *Compiler) rethrowAfterFinally(finally []Stmt) {
+	c.beginScope()
+	c.addLocal("")
+	c.markInitialized()
+	slot := len(c.current.locals) - 1
+
+	c.compileFinally(finally)
+	c.emitOp(OP_GET_LOCAL)
+	c.emitByte(byte(slot))
+	c.emitOp(OP_THROW)
+
+	// the scope is never left at runtime, but the locals must be popped off
+	// the compiler's list.
+	c.endScope()
+}
+
+func (c *Compiler) VisitWhileStmt(stmt *While) error {
+	loopStart := len(c.chunk().Code)
+	c.compileExpr(stmt.Condition)
+
+	exitJump := c.emitJump(OP_JUMP_IF_FALSE)
+	c.emitOp(OP_POP)
+
+	loop := &loopState{enclosing: c.current.loop, scopeDepth: c.current.scopeDepth}
+	c.current.loop = loop
+	c.compileStmt(stmt.Body)
+	c.current.loop = loop.enclosing
+
+	c.emitLoop(loopStart)
+	c.patchJump(exitJump)
+	c.emitOp(OP_POP)
+
+	for _, offset := range loop.breaks {
+		c.patchJump(offset)
+	}
+
+	return nil
+}
+
+func (c *Compiler) VisitIfStmt(stmt *If) error {
+	c.compileExpr(stmt.Condition)
+
+	thenJump := c.emitJump(OP_JUMP_IF_FALSE)
+	c.emitOp(OP_POP)
+	c.compileStmt(stmt.ThenBranch)
+
+	elseJump := c.emitJump(OP_JUMP)
+	c.patchJump(thenJump)
+	c.emitOp(OP_POP)
+
+	if stmt.ElseBranch != nil {
+		c.compileStmt(stmt.ElseBranch)
+	}
+	c.patchJump(elseJump)
+
+	return nil
+}
+
+func (c *Compiler) VisitBlockStmt(stmt *Block) error {
+	c.compileBlock(stmt.Statements)
+	return nil
+}
+
+func (c *Compiler) compileBlock(statements []Stmt) {
+	c.beginScope()
+	for _, s := range statements {
+		c.compileStmt(s)
+	}
+	c.endScope()
+}
+
+func (c *Compiler) VisitVarStmt(stmt *Var) error {
+	c.line = stmt.Name.Line
+	nameConstant := c.identifierConstant(stmt.Name)
+	c.declareVariable(stmt.Name)
+
+	if stmt.Initializer != nil {
+		c.compileExpr(stmt.Initializer)
+	} else {
+		c.emitOp(OP_NIL)
+	}
+
+	c.defineVariable(nameConstant)
+	return nil
+}
+
+func (c *Compiler) VisitPrintStmt(stmt *Print) error {
+	c.compileExpr(stmt.Expression)
+	c.emitOp(OP_PRINT)
+	return nil
+}
+
+func (c *Compiler) VisitExpressionStmt(stmt *Expression) error {
+	c.compileExpr(stmt.Expression)
+	c.emitOp(OP_POP)
+	return nil
+}
+
+/* Implement ExprVisitor interface */
+
+func (c *Compiler) VisitSuperExpr(expr *Super) (interface{}, error) {
+	c.line = expr.Keyword.Line
+	name := c.identifierConstant(expr.Method)
+
+	c.namedVariable(&Token{Type: THIS, Lexeme: "this", Line: expr.Keyword.Line}, false)
+	c.namedVariable(&Token{Type: SUPER, Lexeme: "super", Line: expr.Keyword.Line}, false)
+	c.emitOp(OP_GET_SUPER)
+	c.emitShort(name)
+
+	return nil, nil
+}
+
+func (c *Compiler) VisitThisExpr(expr *This) (interface{}, error) {
+	c.namedVariable(expr.Keyword, false)
+	return nil, nil
+}
+
+func (c *Compiler) VisitSetExpr(expr *Set) (interface{}, error) {
+	c.compileExpr(expr.Object)
+	c.compileExpr(expr.Value)
+
+	c.line = expr.Name.Line
+	c.emitOp(OP_SET_PROPERTY)
+	c.emitShort(c.identifierConstant(expr.Name))
+	return nil, nil
+}
+
+func (c *Compiler) VisitGetExpr(expr *Get) (interface{}, error) {
+	c.compileExpr(expr.Object)
+
+	c.line = expr.Name.Line
+	c.emitOp(OP_GET_PROPERTY)
+	c.emitShort(c.identifierConstant(expr.Name))
+	return nil, nil
+}
+
+func (c *Compiler) VisitListLiteralExpr(expr *ListLiteral) (interface{}, error) {
+	c.collection(len(expr.Elements), expr.Bracket, OP_BUILD_LIST, OP_APPEND_LIST, func(idx int) {
+		c.compileExpr(expr.Elements[idx])
+	})
+	return nil, nil
+}
+
+func (c *Compiler) VisitMapLiteralExpr(expr *MapLiteral) (interface{}, error) {
+	c.collection(len(expr.Keys), expr.Brace, OP_BUILD_MAP, OP_APPEND_MAP, func(idx int) {
+		c.compileExpr(expr.Keys[idx])
+		c.compileExpr(expr.Values[idx])
+	})
+	return nil, nil
+}
+
+// collection compiles a literal of count items in batches that fit the byte
+// operand. The first batch makes the collection with build, and each
+// further one adds its items to it with appendOp.
+func (c *Compiler) collection(count int, token *Token, build OpCode, appendOp OpCode, item func(idx int)) {
+	op := build
+	for start := 0; start == 0 || start < count; start += 255 {
+		end := start + 255
+		if end > count {
+			end = count
+		}
+
+		for idx := start; idx < end; idx++ {
+			item(idx)
+		}
+
+		c.line = token.Line
+		c.emitOp(op)
+		c.emitByte(byte(end - start))
+		op = appendOp
+	}
+}
+
+func (c *Compiler) VisitInterpolatedExpr(expr *Interpolated) (interface{}, error) {
+	c.compileExpr(expr.Expression)
+	c.emitOp(OP_STRINGIFY)
+	return nil, nil
+}
+
+func (c *Compiler) VisitIndexExpr(expr *Index) (interface{}, error) {
+	c.compileExpr(expr.Object)
+	c.compileExpr(expr.Index)
+
+	c.line = expr.Bracket.Line
+	c.emitOp(OP_GET_INDEX)
+	return nil, nil
+}
+
+func (c *Compiler) VisitSetIndexExpr(expr *SetIndex) (interface{}, error) {
+	c.compileExpr(expr.Object)
+	c.compileExpr(expr.Index)
+	c.compileExpr(expr.Value)
+
+	c.line = expr.Bracket.Line
+	c.emitOp(OP_SET_INDEX)
+	return nil, nil
+}
+
+func (c *Compiler) VisitFunctionExprExpr(expr *FunctionExpr) (interface{}, error) {
+	c.function("", expr, FunctionType_FUNCTION)
+	return nil, nil
+}
+
+func (c *Compiler) VisitCallExpr(expr *Call) (interface{}, error) {
+	c.compileExpr(expr.Callee)
+	for _, arg := range expr.Arguments {
+		c.compileExpr(arg)
+	}
+
+	// the call is at the line where it starts, as in the Interpreter.
+	c.line = exprToken(expr).Line
+	c.emitOp(OP_CALL)
+	c.emitByte(byte(len(expr.Arguments)))
+	return nil, nil
+}
+
+func (c *Compiler) VisitLogicalExpr(expr *Logical) (interface{}, error) {
+	c.compileExpr(expr.Left)
+
+	if expr.Operator.Type == OR {
+		elseJump := c.emitJump(OP_JUMP_IF_FALSE)
+		endJump := c.emitJump(OP_JUMP)
+
+		c.patchJump(elseJump)
+		c.emitOp(OP_POP)
+		c.compileExpr(expr.Right)
+		c.patchJump(endJump)
+	} else {
+		endJump := c.emitJump(OP_JUMP_IF_FALSE)
+		c.emitOp(OP_POP)
+		c.compileExpr(expr.Right)
+		c.patchJump(endJump)
+	}
+
+	return nil, nil
+}
+
+func (c *Compiler) VisitAssignExpr(expr *Assign) (interface{}, error) {
+	c.compileExpr(expr.Value)
+	c.namedVariable(expr.Name, true)
+	return nil, nil
+}
+
+func (c *Compiler) VisitVariableExpr(expr *Variable) (interface{}, error) {
+	c.namedVariable(expr.Name, false)
+	return nil, nil
+}
+
+func (c *Compiler) VisitLiteralExpr(expr *Literal) (interface{}, error) {
+	switch expr.Value {
+	case nil:
+		c.emitOp(OP_NIL)
+	case true:
+		c.emitOp(OP_TRUE)
+	case false:
+		c.emitOp(OP_FALSE)
+	default:
+		c.emitConstant(expr.Value)
+	}
+
+	return nil, nil
+}
+
+func (c *Compiler) VisitGroupingExpr(expr *Grouping) (interface{}, error) {
+	c.compileExpr(expr.Expression)
+	return nil, nil
+}
+
+func (c *Compiler) VisitBinaryExpr(expr *Binary) (interface{}, error) {
+	c.compileExpr(expr.Left)
+	if expr.Operator.Type == COMMA {
+		c.emitOp(OP_POP)
+		c.compileExpr(expr.Right)
+		return nil, nil
+	}
+	c.compileExpr(expr.Right)
+
+	c.line = expr.Operator.Line
+	switch expr.Operator.Type {
+	case GREATER:
+		c.emitOp(OP_GREATER)
+	case GREATER_EQUAL:
+		c.emitOp(OP_GREATER_EQUAL)
+	case LESS:
+		c.emitOp(OP_LESS)
+	case LESS_EQUAL:
+		c.emitOp(OP_LESS_EQUAL)
+	case BANG_EQUAL:
+		c.emitOps(OP_EQUAL, OP_NOT)
+	case EQUAL_EQUAL:
+		c.emitOp(OP_EQUAL)
+	case MINUS:
+		c.emitOp(OP_SUBTRACT)
+	case PLUS:
+		c.emitOp(OP_ADD)
+	case SLASH:
+		c.emitOp(OP_DIVIDE)
+	case TILDE_SLASH:
+		c.emitOp(OP_FLOOR_DIVIDE)
+	case STAR:
+		c.emitOp(OP_MULTIPLY)
+	}
+
+	return nil, nil
+}
+
+func (c *Compiler) VisitConditionalExpr(expr *Conditional) (interface{}, error) {
+	c.compileExpr(expr.Cond)
+
+	elseJump := c.emitJump(OP_JUMP_IF_FALSE)
+	c.emitOp(OP_POP)
+	c.compileExpr(expr.Consequent)
+
+	endJump := c.emitJump(OP_JUMP)
+	c.patchJump(elseJump)
+	c.emitOp(OP_POP)
+	c.compileExpr(expr.Alternate)
+	c.patchJump(endJump)
+
+	return nil, nil
+}
+
+func (c *Compiler) VisitUnaryExpr(expr *Unary) (interface{}, error) {
+	c.compileExpr(expr.Right)
+
+	c.line = expr.Operator.Line
+	switch expr.Operator.Type {
+	case BANG:
+		c.emitOp(OP_NOT)
+	case MINUS:
+		c.emitOp(OP_NEGATE)
+	}
+
+	return nil, nil
+}
+
+func (c *Compiler) compileStmt(stmt Stmt) {
+	c.setLine(stmt)
+	stmt.Accept(c)
+}
+
+// setLine moves to the line of the token that starts stmt, so that
+// instructions without a token of their own, such as the jumps of a loop,
+// are not attributed to the statement before. Blocks keep the line.
+func (c *Compiler) setLine(stmt Stmt) {
+	if token := stmtToken(stmt); token != nil {
+		c.line = token.Line
+	}
+}
+
+func (c *Compiler) compileExpr(expr Expr) {
+	expr.Accept(c)
+}
+
+// function compiles the body of a function and emits the OP_CLOSURE that
+// creates the closure at runtime.
+func (c *Compiler) function(name string, declaration *FunctionExpr, kind FunctionType) {
+	c.beginFunction(name, kind)
+	c.beginScope()
+
+	for _, param := range declaration.Paramters {
+		c.declareVariable(param)
+		c.markInitialized()
+	}
+	c.current.proto.arity = uint32(len(declaration.Paramters))
+
+	for _, stmt := range declaration.Body {
+		c.compileStmt(stmt)
+	}
+	c.emitReturn()
+
+	upvalues := c.current.upvalues
+	proto := c.endFunction()
+
+	c.emitOp(OP_CLOSURE)
+	c.emitShort(c.makeConstant(proto))
+	for _, upvalue := range upvalues {
+		isLocal := byte(0)
+		if upvalue.isLocal {
+			isLocal = 1
+		}
+		c.emitBytes(isLocal, upvalue.index)
+	}
+}
+
+func (c *Compiler) beginFunction(name string, kind FunctionType) {
+	state := &funcState{
+		enclosing: c.current,
+		proto:     &funcProto{name: name, file: c.file},
+		kind:      kind,
+	}
+
+	// slot zero holds the function being called, or the receiver in methods.
+	slotZero := ""
+	if kind == FunctionType_METHOD || kind == FunctionType_INITIALIZER {
+		slotZero = "this"
+	}
+	state.locals = append(state.locals, &local{name: slotZero, depth: 0})
+
+	c.current = state
+}
+
+func (c *Compiler) endFunction() *funcProto {
+	proto := c.current.proto
+	proto.upvalueCount = len(c.current.upvalues)
+	c.current = c.current.enclosing
+	return proto
+}
+
+func (c *Compiler) beginScope() {
+	c.current.scopeDepth++
+}
+
+// endScope pops the locals of the scope. A captured local is closed over
+// instead, which moves it from the stack to its upvalue.
+func (c *Compiler) endScope() {
+	c.current.scopeDepth--
+	c.discardLocals(c.current.scopeDepth)
+}
+
+// declareVariable adds a local to the current scope. Global variables are
+// late bound, so there is nothing to declare at the top level.
+func (c *Compiler) declareVariable(name *Token) {
+	if c.current.scopeDepth == 0 {
+		return
+	}
+
+	if len(c.current.locals) == 256 {
+		c.error(name, "Too many local variables in function.")
+		return
+	}
+
+	c.addLocal(name.Lexeme)
+}
+
+func (c *Compiler) addLocal(name string) {
+	c.current.locals = append(c.current.locals, &local{name: name, depth: -1})
+}
+
+func (c *Compiler) markInitialized() {
+	if c.current.scopeDepth == 0 {
+		return
+	}
+
+	c.current.locals[len(c.current.locals)-1].depth = c.current.scopeDepth
+}
+
+// defineVariable makes the value on top of the stack the variable's value.
+// For a local that is simply where it already is.
+func (c *Compiler) defineVariable(nameConstant int) {
+	if c.current.scopeDepth > 0 {
+		c.markInitialized()
+		return
+	}
+
+	c.emitOp(OP_DEFINE_GLOBAL)
+	c.emitShort(nameConstant)
+}
+
+// namedVariable emits the instruction that reads, or assigns when assign is
+// true, the variable called name.
+func (c *Compiler) namedVariable(name *Token, assign bool) {
+	c.line = name.Line
+
+	getOp, setOp := OP_GET_LOCAL, OP_SET_LOCAL
+	arg := resolveLocalSlot(c.current, name.Lexeme)
+	if arg == -1 {
+		getOp, setOp = OP_GET_UPVALUE, OP_SET_UPVALUE
+		arg = c.resolveUpvalue(c.current, name)
+	}
+
+	if arg == -1 {
+		getOp, setOp = OP_GET_GLOBAL, OP_SET_GLOBAL
+		constant := c.identifierConstant(name)
+		if assign {
+			c.emitOp(setOp)
+		} else {
+			c.emitOp(getOp)
+		}
+		c.emitShort(constant)
+		return
+	}
+
+	if assign {
+		c.emitOp(setOp)
+	} else {
+		c.emitOp(getOp)
+	}
+	c.emitByte(byte(arg))
+}
+
+// resolveLocalSlot returns the slot of the innermost local called name, or
+// -1 if the function has no such local.
+func resolveLocalSlot(state *funcState, name string) int {
+	for idx := len(state.locals) - 1; idx >= 0; idx-- {
+		if state.locals[idx].name == name {
+			return idx
+		}
+	}
+
+	return -1
+}
+
+// resolveUpvalue looks the variable up in the enclosing functions and
+// threads an upvalue through every function in between.
+func (c *Compiler) resolveUpvalue(state *funcState, name *Token) int {
+	if state.enclosing == nil {
+		return -1
+	}
+
+	if slot := resolveLocalSlot(state.enclosing, name.Lexeme); slot != -1 {
+		state.enclosing.locals[slot].isCaptured = true
+		return c.addUpvalue(state, name, byte(slot), true)
+	}
+
+	if index := c.resolveUpvalue(state.enclosing, name); index != -1 {
+		return c.addUpvalue(state, name, byte(index), false)
+	}
+
+	return -1
+}
+
+func (c *Compiler) addUpvalue(state *funcState, name *Token, index byte, isLocal bool) int {
+	for idx, upvalue := range state.upvalues {
+		if upvalue.index == index && upvalue.isLocal == isLocal {
+			return idx
+		}
+	}
+
+	if len(state.upvalues) == 256 {
+		c.error(name, "Too many closure variables in function.")
+		return 0
+	}
+
+	state.upvalues = append(state.upvalues, upvalueRef{index: index, isLocal: isLocal})
+	return len(state.upvalues) - 1
+}
+
+func (c *Compiler) chunk() *Chunk {
+	return &c.current.proto.chunk
+}
+
+func (c *Compiler) emitByte(b byte) {
+	c.chunk().Write(b, c.line)
+}
+
+func (c *Compiler) emitBytes(bytes ...byte) {
+	for _, b := range bytes {
+		c.emitByte(b)
+	}
+}
+
+func (c *Compiler) emitOp(op OpCode) {
+	c.emitByte(byte(op))
+}
+
+func (c *Compiler) emitOps(ops ...OpCode) {
+	for _, op := range ops {
+		c.emitOp(op)
+	}
+}
+
+func (c *Compiler) emitShort(v int) {
+	c.emitBytes(byte(v>>8), byte(v))
+}
+
+// emitReturn emits the implicit return at the end of a function body. An
+// initializer always returns the instance.
+func (c *Compiler) emitReturn() {
+	if c.current.kind == FunctionType_INITIALIZER {
+		c.emitOp(OP_GET_LOCAL)
+		c.emitByte(0)
+	} else {
+		c.emitOp(OP_NIL)
+	}
+
+	c.emitOp(OP_RETURN)
+}
+
+func (c *Compiler) emitConstant(value interface{}) {
+	c.emitOp(OP_CONSTANT)
+	c.emitShort(c.makeConstant(value))
+}
+
+// emitJump emits a jump with a placeholder offset and returns the position
+// of the offset, to be filled in by patchJump.
+func (c *Compiler) emitJump(op OpCode) int {
+	c.emitOp(op)
+	c.emitBytes(0xff, 0xff)
+	return len(c.chunk().Code) - 2
+}
+
+func (c *Compiler) patchJump(offset int) {
+	jump := len(c.chunk().Code) - offset - 2
+	if jump > 0xffff {
+		c.errorPrinter.Error(c.line, "Too much code to jump over.")
+	}
+
+	c.chunk().Code[offset] = byte(jump >> 8)
+	c.chunk().Code[offset+1] = byte(jump)
+}
+
+func (c *Compiler) emitLoop(loopStart int) {
+	c.emitOp(OP_LOOP)
+
+	offset := len(c.chunk().Code) - loopStart + 2
+	if offset > 0xffff {
+		c.errorPrinter.Error(c.line, "Loop body too large.")
+	}
+
+	c.emitShort(offset)
+}
+
+func (c *Compiler) makeConstant(value interface{}) int {
+	constant := c.chunk().AddConstant(value)
+	if constant > 0xffff {
+		c.errorPrinter.Error(c.line, "Too many constants in one chunk.")
+		return 0
+	}
+
+	return constant
+}
+
+func (c *Compiler) identifierConstant(name *Token) int {
+	return c.makeConstant(name.Lexeme)
+}
+
+func (c *Compiler) error(token *Token, message string) {
+	c.errorPrinter.TokenError(*token, message)
+}
@@ -0,0 +1,539 @@
+// Package dap implements a Debug Adapter Protocol server for Lox. It runs
+// a script with a glox.Debugger attached and lets a client set breakpoints,
+// step and inspect variables. Messages are read from a stream and written
+// to another, so the server runs over stdio and can be driven by a scripted
+// client.
+package dap
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"glox"
+	"glox/internal/framing"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// threadID is the id of the only thread a Lox program has.
+const threadID = 1
+
+// Server debugs one program for one client.
+type Server struct {
+	in *bufio.Reader
+
+	// writeMu serializes the messages written by the request loop and by
+	// the goroutine running the program.
+	writeMu sync.Mutex
+	out     io.Writer
+	seq     int
+
+	// program is the absolute path of the script, set by launch.
+	program     string
+	noDebug     bool
+	stopOnEntry bool
+	debugger    *glox.Debugger
+
+	// done is closed when the program has finished. It is nil until the
+	// program starts.
+	done chan struct{}
+
+	// resume takes the action a stopped program goes on with.
+	resume chan glox.StepAction
+
+	// stateMu guards the state of a stopped program. handles are the
+	// variablesReferences handed out since the program stopped: an
+	// *glox.Environment for a scope, or a value whose members are shown.
+	stateMu sync.Mutex
+	stop    *glox.Stop
+	handles []interface{}
+}
+
+// NewServer returns a Server that reads requests from in and writes
+// responses and events to out.
+func NewServer(in io.Reader, out io.Writer) *Server {
+	return &Server{
+		in:     bufio.NewReader(in),
+		out:    out,
+		resume: make(chan glox.StepAction),
+	}
+}
+
+// Run serves requests until the client disconnects or closes the input. A
+// program that is still running then is terminated.
+func (s *Server) Run() error {
+	defer s.terminate()
+
+	for {
+		body, err := framing.ReadMessage(s.in)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		var req request
+		if err := json.Unmarshal(body, &req); err != nil {
+			return fmt.Errorf("bad message: %v", err)
+		}
+
+		if req.Type != "request" {
+			continue
+		}
+
+		result, err := s.handle(&req)
+		if err != nil {
+			s.respond(&req, nil, err)
+		} else {
+			s.respond(&req, result, nil)
+		}
+
+		switch req.Command {
+		case "initialize":
+			// the client sends its configuration once it has seen this.
+			s.sendEvent("initialized", nil)
+		case "continue", "next", "stepIn", "stepOut":
+			s.resumeWith(resumeActions[req.Command])
+		case "disconnect":
+			return nil
+		}
+	}
+}
+
+// write sends a message. set fills in the sequence number.
+func (s *Server) write(message interface{}, set func(seq int)) {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	s.seq++
+	set(s.seq)
+
+	body, err := json.Marshal(message)
+	if err != nil {
+		panic(err)
+	}
+
+	framing.WriteMessage(s.out, body)
+}
+
+func (s *Server) respond(req *request, body interface{}, err error) {
+	resp := &response{Type: "response", RequestSeq: req.Seq, Command: req.Command, Success: err == nil, Body: body}
+	if err != nil {
+		resp.Message = err.Error()
+	}
+
+	s.write(resp, func(seq int) { resp.Seq = seq })
+}
+
+func (s *Server) sendEvent(name string, body interface{}) {
+	ev := &event{Type: "event", Event: name, Body: body}
+	s.write(ev, func(seq int) { ev.Seq = seq })
+}
+
+var resumeActions = map[string]glox.StepAction{
+	"continue": glox.Continue,
+	"next":     glox.StepOver,
+	"stepIn":   glox.StepIn,
+	"stepOut":  glox.StepOut,
+}
+
+// handle runs a request and returns the body of its response.
+func (s *Server) handle(req *request) (interface{}, error) {
+	switch req.Command {
+	case "initialize":
+		return &capabilities{
+			SupportsConfigurationDoneRequest: true,
+			SupportsTerminateRequest:         true,
+			SupportsEvaluateForHovers:        true,
+		}, nil
+
+	case "launch":
+		var args launchArguments
+		if err := json.Unmarshal(req.Arguments, &args); err != nil {
+			return nil, err
+		}
+		return nil, s.launch(&args)
+
+	case "setBreakpoints":
+		var args setBreakpointsArguments
+		if err := json.Unmarshal(req.Arguments, &args); err != nil {
+			return nil, err
+		}
+		return s.setBreakpoints(&args), nil
+
+	case "setExceptionBreakpoints":
+		return map[string]interface{}{"breakpoints": []Breakpoint{}}, nil
+
+	case "configurationDone":
+		return nil, s.start()
+
+	case "threads":
+		return map[string]interface{}{"threads": []Thread{{ID: threadID, Name: "main"}}}, nil
+
+	case "stackTrace":
+		return s.stackTrace()
+
+	case "scopes":
+		var args scopesArguments
+		if err := json.Unmarshal(req.Arguments, &args); err != nil {
+			return nil, err
+		}
+		return s.scopes(args.FrameID)
+
+	case "variables":
+		var args variablesArguments
+		if err := json.Unmarshal(req.Arguments, &args); err != nil {
+			return nil, err
+		}
+		return s.variables(args.VariablesReference)
+
+	case "evaluate":
+		var args evaluateArguments
+		if err := json.Unmarshal(req.Arguments, &args); err != nil {
+			return nil, err
+		}
+		return s.evaluate(&args)
+
+	case "continue":
+		if !s.stopped() {
+			return nil, errors.New("The program is not stopped.")
+		}
+		return map[string]interface{}{"allThreadsContinued": true}, nil
+
+	case "next", "stepIn", "stepOut":
+		if !s.stopped() {
+			return nil, errors.New("The program is not stopped.")
+		}
+		return nil, nil
+
+	case "pause":
+		if s.debugger != nil {
+			s.debugger.Pause()
+		}
+		return nil, nil
+
+	case "terminate", "disconnect":
+		s.terminate()
+		return nil, nil
+	}
+
+	return nil, fmt.Errorf("Unsupported request '%s'.", req.Command)
+}
+
+func (s *Server) launch(args *launchArguments) error {
+	if args.Program == "" {
+		return errors.New("Missing 'program' to debug.")
+	}
+
+	program, err := filepath.Abs(args.Program)
+	if err != nil {
+		return err
+	}
+
+	s.program, s.noDebug, s.stopOnEntry = program, args.NoDebug, args.StopOnEntry
+	s.debugger = glox.NewDebugger(s.onStop)
+	return nil
+}
+
+// file returns the name the debugger uses for the file at path: "" for the
+// main script.
+func (s *Server) file(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+
+	if path == s.program {
+		return ""
+	}
+	return path
+}
+
+func (s *Server) source(file string) *Source {
+	if file == "" {
+		file = s.program
+	}
+
+	return &Source{Name: filepath.Base(file), Path: file}
+}
+
+// setBreakpoints replaces the breakpoints of a file. Lines without a
+// statement are accepted too; the program never stops there.
+func (s *Server) setBreakpoints(args *setBreakpointsArguments) interface{} {
+	lines := args.Lines
+	if args.Breakpoints != nil {
+		lines = make([]int, len(args.Breakpoints))
+		for idx, bp := range args.Breakpoints {
+			lines[idx] = bp.Line
+		}
+	}
+
+	breakpoints := []Breakpoint{}
+	debuggerLines := []uint32{}
+	for _, line := range lines {
+		breakpoints = append(breakpoints, Breakpoint{Verified: s.debugger != nil && line > 0, Line: line})
+		if line > 0 {
+			debuggerLines = append(debuggerLines, uint32(line))
+		}
+	}
+
+	if s.debugger != nil {
+		s.debugger.SetBreakpoints(s.file(args.Source.Path), debuggerLines)
+	}
+
+	return map[string]interface{}{"breakpoints": breakpoints}
+}
+
+// start runs the program on a goroutine of its own.
+func (s *Server) start() error {
+	if s.debugger == nil {
+		return errors.New("Launch a program first.")
+	}
+	if s.done != nil {
+		return nil
+	}
+
+	opts := &glox.Options{
+		Stdout: &output{server: s, category: "stdout"},
+		Stderr: &output{server: s, category: "stderr"},
+	}
+	if !s.noDebug {
+		opts.Debugger = s.debugger
+		if s.stopOnEntry {
+			s.debugger.StopOnEntry()
+		}
+	}
+
+	s.done = make(chan struct{})
+	go func() {
+		defer close(s.done)
+
+		exitCode := 0
+		var compileErr *glox.CompileError
+		var runtimeErr *glox.RuntimeError
+		switch err := glox.NewVM(opts).RunFile(s.program); {
+		case err == nil, err == glox.ErrTerminated:
+		case errors.As(err, &compileErr):
+			exitCode = 65
+		case errors.As(err, &runtimeErr):
+			exitCode = 70
+		default:
+			s.sendEvent("output", map[string]interface{}{"category": "stderr", "output": err.Error() + "\n"})
+			exitCode = 66
+		}
+
+		s.sendEvent("exited", map[string]interface{}{"exitCode": exitCode})
+		s.sendEvent("terminated", nil)
+	}()
+
+	return nil
+}
+
+// terminate stops the program, if it is running, and waits for it to end.
+func (s *Server) terminate() {
+	if s.done == nil {
+		return
+	}
+
+	// the program may be about to stop, so keep offering to resume it
+	// until it has ended.
+	s.debugger.Terminate()
+	select {
+	case s.resume <- glox.Terminate:
+		<-s.done
+	case <-s.done:
+	}
+}
+
+// onStop is called on the program's goroutine when it stops. It waits for
+// the client to resume it.
+func (s *Server) onStop(stop *glox.Stop) glox.StepAction {
+	s.stateMu.Lock()
+	s.stop, s.handles = stop, nil
+	s.stateMu.Unlock()
+
+	s.sendEvent("stopped", map[string]interface{}{
+		"reason":            string(stop.Reason),
+		"threadId":          threadID,
+		"allThreadsStopped": true,
+	})
+
+	action := <-s.resume
+
+	s.stateMu.Lock()
+	s.stop, s.handles = nil, nil
+	s.stateMu.Unlock()
+
+	return action
+}
+
+func (s *Server) stopped() bool {
+	s.stateMu.Lock()
+	defer s.stateMu.Unlock()
+
+	return s.stop != nil
+}
+
+// resumeWith resumes a stopped program.
+func (s *Server) resumeWith(action glox.StepAction) {
+	if !s.stopped() {
+		return
+	}
+
+	select {
+	case s.resume <- action:
+	case <-s.done:
+	}
+}
+
+func (s *Server) stackTrace() (interface{}, error) {
+	s.stateMu.Lock()
+	defer s.stateMu.Unlock()
+
+	if s.stop == nil {
+		return nil, errors.New("The program is not stopped.")
+	}
+
+	frames := make([]StackFrame, len(s.stop.Frames))
+	for idx, frame := range s.stop.Frames {
+		frames[idx] = StackFrame{
+			ID:     idx + 1,
+			Name:   frame.Function,
+			Source: s.source(frame.File),
+			Line:   int(frame.Line),
+			Column: 1,
+		}
+	}
+
+	return map[string]interface{}{"stackFrames": frames, "totalFrames": len(frames)}, nil
+}
+
+// frame returns the frame with the id handed out by stackTrace. The
+// caller holds stateMu.
+func (s *Server) frame(id int) (*glox.DebugFrame, error) {
+	if s.stop == nil {
+		return nil, errors.New("The program is not stopped.")
+	}
+	if id < 1 || id > len(s.stop.Frames) {
+		return nil, fmt.Errorf("Unknown frame %d.", id)
+	}
+
+	return s.stop.Frames[id-1], nil
+}
+
+// reference returns a variablesReference for v. The caller holds stateMu.
+func (s *Server) reference(v interface{}) int {
+	s.handles = append(s.handles, v)
+	return len(s.handles)
+}
+
+// scopes lists the environments of a frame, innermost first, down to the
+// globals. The builtins are left out.
+func (s *Server) scopes(frameID int) (interface{}, error) {
+	s.stateMu.Lock()
+	defer s.stateMu.Unlock()
+
+	frame, err := s.frame(frameID)
+	if err != nil {
+		return nil, err
+	}
+
+	scopes := []Scope{}
+	for env := frame.Env; env != nil && env.Enclosing() != nil; env = env.Enclosing() {
+		name := "Locals"
+		switch {
+		case env.IsGlobal():
+			name = "Globals"
+		case len(scopes) > 0:
+			name = "Enclosing " + strconv.Itoa(len(scopes))
+		}
+
+		scopes = append(scopes, Scope{Name: name, VariablesReference: s.reference(env), Expensive: env.IsGlobal()})
+	}
+
+	return map[string]interface{}{"scopes": scopes}, nil
+}
+
+func (s *Server) variables(ref int) (interface{}, error) {
+	s.stateMu.Lock()
+	defer s.stateMu.Unlock()
+
+	if ref < 1 || ref > len(s.handles) {
+		return nil, fmt.Errorf("Unknown variables reference %d.", ref)
+	}
+
+	var bindings []glox.Binding
+	if env, isEnv := s.handles[ref-1].(*glox.Environment); isEnv {
+		bindings = env.Bindings()
+	} else {
+		bindings = glox.Members(s.handles[ref-1])
+	}
+
+	variables := make([]Variable, len(bindings))
+	for idx, binding := range bindings {
+		variables[idx] = s.variable(binding.Name, binding.Value)
+	}
+
+	return map[string]interface{}{"variables": variables}, nil
+}
+
+// variable describes a value. Values with members can be expanded. The
+// caller holds stateMu.
+func (s *Server) variable(name string, value glox.Value) Variable {
+	v := Variable{Name: name, Value: glox.FormatValue(value)}
+	if len(glox.Members(value)) > 0 {
+		v.VariablesReference = s.reference(value)
+	}
+
+	return v
+}
+
+// evaluate looks up a variable, or a path of members like "this.n", in a
+// frame. Other expressions are not supported.
+func (s *Server) evaluate(args *evaluateArguments) (interface{}, error) {
+	s.stateMu.Lock()
+	defer s.stateMu.Unlock()
+
+	frame, err := s.frame(args.FrameID)
+	if err != nil {
+		return nil, err
+	}
+
+	path := strings.Split(strings.TrimSpace(args.Expression), ".")
+	value, ok := frame.Env.Lookup(path[0])
+	if !ok {
+		return nil, fmt.Errorf("Undefined variable '%s'.", path[0])
+	}
+
+	for _, name := range path[1:] {
+		found := false
+		for _, member := range glox.Members(value) {
+			if member.Name == name {
+				value, found = member.Value, true
+				break
+			}
+		}
+
+		if !found {
+			return nil, fmt.Errorf("Undefined property '%s'.", name)
+		}
+	}
+
+	v := s.variable(args.Expression, value)
+	return map[string]interface{}{"result": v.Value, "variablesReference": v.VariablesReference}, nil
+}
+
+// output sends what the program writes as output events.
+type output struct {
+	server   *Server
+	category string
+}
+
+func (o *output) Write(p []byte) (int, error) {
+	o.server.sendEvent("output", map[string]interface{}{"category": o.category, "output": string(p)})
+	return len(p), nil
+}
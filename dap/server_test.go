@@ -0,0 +1,320 @@
+package dap
+
+import (
+	"bufio"
+	"encoding/json"
+	"glox/internal/framing"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const testProgram = `var total = 0;
+fun add(n) {
+  var next = total + n;
+  total = next;
+  return next;
+}
+var xs = [1, {"k": 2}];
+add(5);
+print total;
+`
+
+// message is a message the server sent: a response or an event.
+type message struct {
+	Type       string          `json:"type"`
+	RequestSeq int             `json:"request_seq"`
+	Success    bool            `json:"success"`
+	Command    string          `json:"command"`
+	Message    string          `json:"message"`
+	Event      string          `json:"event"`
+	Body       json.RawMessage `json:"body"`
+}
+
+// client is a scripted client talking to a Server over pipes.
+type client struct {
+	t        *testing.T
+	requests io.Writer
+	messages chan *message
+	done     chan error
+	seq      int
+
+	// outputs collects the output events seen so far.
+	outputs string
+}
+
+func newClient(t *testing.T) *client {
+	serverIn, requests := io.Pipe()
+	replies, serverOut := io.Pipe()
+
+	c := &client{
+		t:        t,
+		requests: requests,
+		messages: make(chan *message, 100),
+		done:     make(chan error, 1),
+	}
+
+	go func() {
+		c.done <- NewServer(serverIn, serverOut).Run()
+		serverOut.Close()
+	}()
+
+	go func() {
+		defer close(c.messages)
+
+		in := bufio.NewReader(replies)
+		for {
+			body, err := framing.ReadMessage(in)
+			if err != nil {
+				return
+			}
+
+			msg := &message{}
+			if err := json.Unmarshal(body, msg); err != nil {
+				t.Error(err)
+				return
+			}
+			c.messages <- msg
+		}
+	}()
+
+	t.Cleanup(func() { requests.Close() })
+	return c
+}
+
+// send sends a request and returns its seq.
+func (c *client) send(command string, arguments interface{}) int {
+	c.seq++
+	body, err := json.Marshal(map[string]interface{}{
+		"seq":       c.seq,
+		"type":      "request",
+		"command":   command,
+		"arguments": arguments,
+	})
+	if err != nil {
+		c.t.Fatal(err)
+	}
+
+	if err := framing.WriteMessage(c.requests, body); err != nil {
+		c.t.Fatal(err)
+	}
+	return c.seq
+}
+
+// next returns the next message, collecting output events on the way.
+func (c *client) next(waitingFor string) *message {
+	c.t.Helper()
+
+	for {
+		select {
+		case msg, ok := <-c.messages:
+			if !ok {
+				c.t.Fatalf("the server closed the stream while waiting for %s", waitingFor)
+			}
+			if msg.Event == "output" {
+				var body struct{ Output string }
+				json.Unmarshal(msg.Body, &body)
+				c.outputs += body.Output
+				continue
+			}
+			return msg
+		case <-time.After(5 * time.Second):
+			c.t.Fatalf("timed out waiting for %s", waitingFor)
+		}
+	}
+}
+
+// request sends a request, waits for its successful response and decodes
+// its body into v, if it is not nil.
+func (c *client) request(command string, arguments interface{}, v interface{}) {
+	c.t.Helper()
+
+	seq := c.send(command, arguments)
+	msg := c.next(command)
+	if msg.Type != "response" || msg.RequestSeq != seq {
+		c.t.Fatalf("%s: got %+v, want its response", command, msg)
+	}
+	if !msg.Success {
+		c.t.Fatalf("%s failed: %s", command, msg.Message)
+	}
+
+	if v != nil {
+		if err := json.Unmarshal(msg.Body, v); err != nil {
+			c.t.Fatalf("%s: %v", command, err)
+		}
+	}
+}
+
+// event waits for the event name and decodes its body into v, if it is not
+// nil.
+func (c *client) event(name string, v interface{}) {
+	c.t.Helper()
+
+	msg := c.next(name)
+	if msg.Type != "event" || msg.Event != name {
+		c.t.Fatalf("got %+v, want the %s event", msg, name)
+	}
+
+	if v != nil {
+		if err := json.Unmarshal(msg.Body, v); err != nil {
+			c.t.Fatalf("%s: %v", name, err)
+		}
+	}
+}
+
+// stopped waits for the program to stop and returns its top frame.
+func (c *client) stopped(reason string) StackFrame {
+	c.t.Helper()
+
+	var stop struct{ Reason string }
+	c.event("stopped", &stop)
+	if stop.Reason != reason {
+		c.t.Errorf("stopped: got reason %q, want %q", stop.Reason, reason)
+	}
+
+	var trace struct{ StackFrames []StackFrame }
+	c.request("stackTrace", map[string]interface{}{"threadId": threadID}, &trace)
+	if len(trace.StackFrames) == 0 {
+		c.t.Fatal("stackTrace: no frames")
+	}
+	return trace.StackFrames[0]
+}
+
+func (c *client) variables(ref int) map[string]Variable {
+	c.t.Helper()
+
+	var body struct{ Variables []Variable }
+	c.request("variables", map[string]interface{}{"variablesReference": ref}, &body)
+
+	variables := map[string]Variable{}
+	for _, v := range body.Variables {
+		variables[v.Name] = v
+	}
+	return variables
+}
+
+// launch starts a session on testProgram with a breakpoint on line and
+// returns the client and the path of the program.
+func launch(t *testing.T, line int) (*client, string) {
+	program := filepath.Join(t.TempDir(), "program.lox")
+	if err := os.WriteFile(program, []byte(testProgram), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := newClient(t)
+
+	var caps capabilities
+	c.request("initialize", map[string]interface{}{"adapterID": "glox"}, &caps)
+	if !caps.SupportsConfigurationDoneRequest {
+		t.Errorf("initialize: got %+v", caps)
+	}
+	c.event("initialized", nil)
+
+	c.request("launch", map[string]interface{}{"program": program}, nil)
+
+	var set struct{ Breakpoints []Breakpoint }
+	c.request("setBreakpoints", map[string]interface{}{
+		"source":      map[string]interface{}{"path": program},
+		"breakpoints": []map[string]interface{}{{"line": line}},
+	}, &set)
+	if len(set.Breakpoints) != 1 || !set.Breakpoints[0].Verified || set.Breakpoints[0].Line != line {
+		t.Errorf("setBreakpoints: got %+v", set.Breakpoints)
+	}
+
+	c.request("configurationDone", nil, nil)
+	return c, program
+}
+
+// finish continues to the end of the program and checks its output.
+func (c *client) finish() {
+	c.t.Helper()
+
+	c.request("continue", map[string]interface{}{"threadId": threadID}, nil)
+
+	var exited struct{ ExitCode int }
+	c.event("exited", &exited)
+	if exited.ExitCode != 0 {
+		c.t.Errorf("exit code: got %d, want 0", exited.ExitCode)
+	}
+	c.event("terminated", nil)
+
+	if c.outputs != "5\n" {
+		c.t.Errorf("output: got %q, want %q", c.outputs, "5\n")
+	}
+}
+
+func TestSession(t *testing.T) {
+	c, program := launch(t, 3)
+
+	top := c.stopped("breakpoint")
+	if top.Name != "add" || top.Line != 3 || top.Source == nil || top.Source.Path != program {
+		t.Errorf("at the breakpoint: got frame %+v", top)
+	}
+
+	var threads struct{ Threads []Thread }
+	c.request("threads", nil, &threads)
+	if len(threads.Threads) != 1 || threads.Threads[0].ID != threadID {
+		t.Errorf("threads: got %+v", threads.Threads)
+	}
+
+	var scopes struct{ Scopes []Scope }
+	c.request("scopes", map[string]interface{}{"frameId": top.ID}, &scopes)
+	if len(scopes.Scopes) < 2 || scopes.Scopes[0].Name != "Locals" || scopes.Scopes[len(scopes.Scopes)-1].Name != "Globals" {
+		t.Fatalf("scopes: got %+v", scopes.Scopes)
+	}
+
+	if n := c.variables(scopes.Scopes[0].VariablesReference)["n"]; n.Value != "5" {
+		t.Errorf("local n: got %+v, want 5", n)
+	}
+
+	var xs struct {
+		Result             string
+		VariablesReference int
+	}
+	c.request("evaluate", map[string]interface{}{"expression": "xs", "frameId": top.ID}, &xs)
+	if xs.Result != `[1, {"k": 2}]` || xs.VariablesReference == 0 {
+		t.Fatalf("evaluate xs: got %+v", xs)
+	}
+	if element := c.variables(xs.VariablesReference)["1"]; element.Value != `{"k": 2}` || element.VariablesReference == 0 {
+		t.Errorf("xs[1]: got %+v", element)
+	}
+
+	c.request("next", map[string]interface{}{"threadId": threadID}, nil)
+	if top := c.stopped("step"); top.Line != 4 {
+		t.Errorf("after next: got line %d, want 4", top.Line)
+	}
+
+	c.finish()
+
+	c.request("disconnect", nil, nil)
+	select {
+	case err := <-c.done:
+		if err != nil {
+			t.Errorf("Run: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after disconnect")
+	}
+}
+
+func TestBreakpointOnTopLevelCall(t *testing.T) {
+	c, _ := launch(t, 8)
+
+	if top := c.stopped("breakpoint"); top.Line != 8 {
+		t.Errorf("at the breakpoint: got frame %+v, want line 8", top)
+	}
+
+	c.request("stepIn", map[string]interface{}{"threadId": threadID}, nil)
+	if top := c.stopped("step"); top.Name != "add" || top.Line != 3 {
+		t.Errorf("after stepIn: got frame %+v, want add at line 3", top)
+	}
+
+	c.request("stepOut", map[string]interface{}{"threadId": threadID}, nil)
+	if top := c.stopped("step"); top.Line != 9 {
+		t.Errorf("after stepOut: got line %d, want 9", top.Line)
+	}
+
+	c.finish()
+}
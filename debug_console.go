@@ -0,0 +1,267 @@
+package glox
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const debugHelp = `Commands:
+  c, continue         run to the next breakpoint
+  s, step             step into the next statement
+  n, next             step over calls to the next statement
+  o, out              run until the current function returns
+  b [file:]line       set a breakpoint; b alone lists them
+  d [file:]line       delete a breakpoint
+  bt, where           print the call stack
+  f, frame N          select frame N of the call stack
+  env                 print the environments of the selected frame
+  this                print the instance of the selected method
+  p, print NAME       print a variable
+  q, quit             stop the program
+An empty line repeats the last command.`
+
+// debugConsole is the textual front end of a Debugger, used by the --debug
+// mode of the command line.
+type debugConsole struct {
+	debugger *Debugger
+	in       *bufio.Scanner
+	out      io.Writer
+
+	// main is the path of the script. Its breakpoints are kept under "".
+	main string
+
+	// sources caches the lines of the files shown so far.
+	sources map[string][]string
+
+	stop     *Stop
+	frame    int
+	lastLine string
+}
+
+// NewDebugConsole returns a Debugger that stops before the first statement
+// of the script at path and then reads commands from in and writes to out.
+func NewDebugConsole(path string, in io.Reader, out io.Writer) *Debugger {
+	console := &debugConsole{
+		in:      bufio.NewScanner(in),
+		out:     out,
+		main:    path,
+		sources: map[string][]string{},
+	}
+
+	console.debugger = NewDebugger(console.onStop)
+	console.debugger.StopOnEntry()
+	fmt.Fprintln(out, "Debugging "+path+". Type h for help.")
+
+	return console.debugger
+}
+
+func (dc *debugConsole) onStop(stop *Stop) StepAction {
+	dc.stop, dc.frame = stop, 0
+
+	fmt.Fprintf(dc.out, "Stopped (%s) at %s\n", stop.Reason, location(stop.File, stop.Line))
+	dc.printLine(stop.File, stop.Line)
+
+	for {
+		fmt.Fprint(dc.out, "(debug) ")
+		if !dc.in.Scan() {
+			// without more commands, let the program finish.
+			fmt.Fprintln(dc.out)
+			return Continue
+		}
+
+		line := strings.TrimSpace(dc.in.Text())
+		if line == "" {
+			line = dc.lastLine
+		}
+		dc.lastLine = line
+
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		if action, resume := dc.command(fields[0], fields[1:]); resume {
+			return action
+		}
+	}
+}
+
+// command runs a command. It returns the action to resume with and true
+// if the command resumes the program.
+func (dc *debugConsole) command(name string, args []string) (StepAction, bool) {
+	switch name {
+	case "c", "continue":
+		return Continue, true
+	case "s", "step":
+		return StepIn, true
+	case "n", "next":
+		return StepOver, true
+	case "o", "out":
+		return StepOut, true
+	case "q", "quit":
+		return Terminate, true
+
+	case "b", "break":
+		if len(args) == 0 {
+			dc.listBreakpoints()
+		} else {
+			dc.setBreakpoint(args[0], true)
+		}
+	case "d", "delete":
+		if len(args) == 0 {
+			fmt.Fprintln(dc.out, "Usage: d [file:]line")
+		} else {
+			dc.setBreakpoint(args[0], false)
+		}
+	case "bt", "where":
+		for idx, frame := range dc.stop.Frames {
+			marker := " "
+			if idx == dc.frame {
+				marker = "*"
+			}
+			fmt.Fprintf(dc.out, "%s #%d %s (%s)\n", marker, idx, frame.Function, location(frame.File, frame.Line))
+		}
+	case "f", "frame":
+		idx := -1
+		if len(args) == 1 {
+			idx, _ = strconv.Atoi(args[0])
+		}
+		if idx < 0 || idx >= len(dc.stop.Frames) {
+			fmt.Fprintf(dc.out, "Usage: f N, with N from 0 to %d\n", len(dc.stop.Frames)-1)
+			break
+		}
+		dc.frame = idx
+		frame := dc.stop.Frames[idx]
+		fmt.Fprintf(dc.out, "#%d %s (%s)\n", idx, frame.Function, location(frame.File, frame.Line))
+		dc.printLine(frame.File, frame.Line)
+	case "env":
+		dc.printEnvironments()
+	case "this":
+		if this, ok := dc.stop.Frames[dc.frame].This(); ok {
+			dc.printValue("this", this)
+		} else {
+			fmt.Fprintln(dc.out, "Not in a method.")
+		}
+	case "p", "print":
+		if len(args) != 1 {
+			fmt.Fprintln(dc.out, "Usage: p NAME")
+			break
+		}
+		if val, ok := dc.stop.Frames[dc.frame].Env.Lookup(args[0]); ok {
+			dc.printValue(args[0], val)
+		} else {
+			fmt.Fprintf(dc.out, "Undefined variable '%s'.\n", args[0])
+		}
+	case "h", "help":
+		fmt.Fprintln(dc.out, debugHelp)
+	default:
+		fmt.Fprintf(dc.out, "Unknown command '%s'. Type h for help.\n", name)
+	}
+
+	return Continue, false
+}
+
+// setBreakpoint adds or removes the breakpoint at spec, which is a line of
+// the current file or file:line.
+func (dc *debugConsole) setBreakpoint(spec string, set bool) {
+	file := dc.stop.File
+	if idx := strings.LastIndex(spec, ":"); idx >= 0 {
+		file, spec = spec[:idx], spec[idx+1:]
+		if file == dc.main {
+			file = ""
+		}
+	}
+
+	line, err := strconv.Atoi(spec)
+	if err != nil || line < 1 {
+		fmt.Fprintln(dc.out, "Expect a line number.")
+		return
+	}
+
+	lines := []uint32{}
+	for _, existing := range dc.debugger.Breakpoints(file) {
+		if existing != uint32(line) {
+			lines = append(lines, existing)
+		}
+	}
+	if set {
+		lines = append(lines, uint32(line))
+	}
+	dc.debugger.SetBreakpoints(file, lines)
+
+	if set {
+		fmt.Fprintf(dc.out, "Breakpoint at %s\n", location(file, uint32(line)))
+	} else {
+		fmt.Fprintf(dc.out, "Deleted breakpoint at %s\n", location(file, uint32(line)))
+	}
+}
+
+func (dc *debugConsole) listBreakpoints() {
+	dc.debugger.mu.Lock()
+	files := make([]string, 0, len(dc.debugger.breakpoints))
+	for file := range dc.debugger.breakpoints {
+		files = append(files, file)
+	}
+	dc.debugger.mu.Unlock()
+
+	count := 0
+	for _, file := range files {
+		for _, line := range dc.debugger.Breakpoints(file) {
+			fmt.Fprintf(dc.out, "  %s\n", location(file, line))
+			count++
+		}
+	}
+
+	if count == 0 {
+		fmt.Fprintln(dc.out, "No breakpoints.")
+	}
+}
+
+// printEnvironments prints the environment chain of the selected frame,
+// innermost first. The builtins are left out.
+func (dc *debugConsole) printEnvironments() {
+	depth := 0
+	for env := dc.stop.Frames[dc.frame].Env; env != nil && env.Enclosing() != nil; env = env.Enclosing() {
+		if env.IsGlobal() {
+			fmt.Fprintln(dc.out, "globals:")
+		} else {
+			fmt.Fprintf(dc.out, "scope %d:\n", depth)
+		}
+
+		for _, binding := range env.Bindings() {
+			fmt.Fprintf(dc.out, "  %s = %s\n", binding.Name, FormatValue(binding.Value))
+		}
+		depth++
+	}
+}
+
+func (dc *debugConsole) printValue(name string, value Value) {
+	fmt.Fprintf(dc.out, "%s = %s\n", name, FormatValue(value))
+	for _, member := range Members(value) {
+		fmt.Fprintf(dc.out, "  %s = %s\n", member.Name, FormatValue(member.Value))
+	}
+}
+
+// printLine prints a line of a file, if the file can be read.
+func (dc *debugConsole) printLine(file string, line uint32) {
+	lines, ok := dc.sources[file]
+	if !ok {
+		path := file
+		if path == "" {
+			path = dc.main
+		}
+
+		if source, err := os.ReadFile(path); err == nil {
+			lines = strings.Split(string(source), "\n")
+		}
+		dc.sources[file] = lines
+	}
+
+	if line >= 1 && int(line) <= len(lines) {
+		fmt.Fprintf(dc.out, "%5d | %s\n", line, lines[line-1])
+	}
+}
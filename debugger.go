@@ -0,0 +1,401 @@
+package glox
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// ErrTerminated is returned by a VM whose program was stopped by its
+// Debugger. Try statements do not catch it.
+var ErrTerminated = errors.New("Terminated by the debugger.")
+
+// StepAction tells a stopped Debugger how to go on.
+type StepAction int
+
+const (
+	// Continue runs until the next breakpoint.
+	Continue StepAction = iota
+
+	// StepIn stops at the next statement, in a function called by the
+	// current one if there is a call.
+	StepIn
+
+	// StepOver stops at the next statement of the current function, or of
+	// its caller once it returns.
+	StepOver
+
+	// StepOut stops once the current function has returned.
+	StepOut
+
+	// Terminate stops the program. The VM returns ErrTerminated.
+	Terminate
+)
+
+// StopReason tells why the Debugger stopped. The values are the ones the
+// Debug Adapter Protocol uses.
+type StopReason string
+
+const (
+	StopEntry      StopReason = "entry"
+	StopBreakpoint StopReason = "breakpoint"
+	StopStep       StopReason = "step"
+	StopPause      StopReason = "pause"
+)
+
+// Stop describes the state of a stopped program. It is only valid until
+// the OnStop callback that received it returns.
+type Stop struct {
+	Reason StopReason
+
+	// File and Line locate the statement about to run. File is empty for
+	// the main script.
+	File string
+	Line uint32
+
+	// Frames is the call stack, innermost first.
+	Frames []*DebugFrame
+}
+
+// DebugFrame is a call in progress: a function, or the top level of the
+// script or of a module.
+type DebugFrame struct {
+	// Function is the name of the function, "<script>" for the top level.
+	Function string
+
+	// File and Line locate the statement the frame is running. File is
+	// empty for the main script.
+	File string
+	Line uint32
+
+	// Env is the innermost environment of the frame. Its enclosing
+	// environments lead to the closure of the function and to the
+	// globals.
+	Env *Environment
+
+	// lineStart is the first statement run on the current line, used to
+	// tell a new pass over the line, e.g. in a loop, from the statements
+	// nested in the first one.
+	lineStart Stmt
+}
+
+// This returns the instance a method was called on. ok is false outside of
+// methods.
+func (df *DebugFrame) This() (value Value, ok bool) {
+	return df.Env.Lookup("this")
+}
+
+// Debugger pauses the Interpreter at breakpoints and steps through the
+// program. The Interpreter calls it before it executes each statement.
+// Only the TreeWalk backend supports debugging.
+type Debugger struct {
+	// OnStop is called when the program stops. It runs on the goroutine
+	// running the program, which waits for it to return the next action.
+	OnStop func(stop *Stop) StepAction
+
+	// mu guards the fields that other goroutines may change while the
+	// program runs.
+	mu          sync.Mutex
+	breakpoints map[string]map[uint32]bool
+	pause       StopReason
+	terminated  bool
+
+	frames []*DebugFrame
+
+	// action is the last action returned by OnStop. The step actions are
+	// relative to stepFrame and stepDepth, the frame and stack depth the
+	// program stopped at.
+	action    StepAction
+	stepFrame *DebugFrame
+	stepDepth int
+}
+
+// NewDebugger returns a Debugger that calls onStop when the program stops.
+func NewDebugger(onStop func(stop *Stop) StepAction) *Debugger {
+	return &Debugger{
+		OnStop:      onStop,
+		breakpoints: map[string]map[uint32]bool{},
+	}
+}
+
+// SetBreakpoints replaces the breakpoints of file with the lines. Use ""
+// for the main script and the path of the module otherwise, as it appears
+// in the import.
+func (d *Debugger) SetBreakpoints(file string, lines []uint32) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.breakpoints[file] = map[uint32]bool{}
+	for _, line := range lines {
+		d.breakpoints[file][line] = true
+	}
+}
+
+// Breakpoints returns the lines of the breakpoints in file, sorted.
+func (d *Debugger) Breakpoints(file string) []uint32 {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	lines := []uint32{}
+	for line := range d.breakpoints[file] {
+		lines = append(lines, line)
+	}
+	sort.Slice(lines, func(i, j int) bool { return lines[i] < lines[j] })
+
+	return lines
+}
+
+// StopOnEntry makes the program stop before its first statement.
+func (d *Debugger) StopOnEntry() {
+	d.mu.Lock()
+	d.pause = StopEntry
+	d.mu.Unlock()
+}
+
+// Pause makes the running program stop at the next statement. It may be
+// called from any goroutine.
+func (d *Debugger) Pause() {
+	d.mu.Lock()
+	d.pause = StopPause
+	d.mu.Unlock()
+}
+
+// Terminate stops the running program before its next statement. It may
+// be called from any goroutine.
+func (d *Debugger) Terminate() {
+	d.mu.Lock()
+	d.terminated = true
+	d.mu.Unlock()
+}
+
+// enterFrame pushes the frame of a call. env is the environment of the
+// function's parameters.
+func (d *Debugger) enterFrame(function string, env *Environment) {
+	d.frames = append(d.frames, &DebugFrame{Function: frameName(function), Env: env})
+}
+
+func (d *Debugger) exitFrame() {
+	d.frames = d.frames[:len(d.frames)-1]
+}
+
+// before is called by the Interpreter before it executes stmt in env. It
+// returns ErrTerminated if the program has to stop.
+func (d *Debugger) before(stmt Stmt, env *Environment) error {
+	token := stmtToken(stmt)
+	if token == nil {
+		return nil
+	}
+
+	if len(d.frames) == 0 {
+		d.enterFrame("<script>", env)
+	}
+
+	frame := d.frames[len(d.frames)-1]
+	frame.Env = env
+
+	newLine := token.Line != frame.Line || token.File != frame.File || stmt == frame.lineStart
+	if newLine {
+		frame.File, frame.Line, frame.lineStart = token.File, token.Line, stmt
+	}
+
+	d.mu.Lock()
+	if d.terminated {
+		d.mu.Unlock()
+		return ErrTerminated
+	}
+
+	var reason StopReason
+	if d.pause != "" {
+		reason, d.pause = d.pause, ""
+	} else if newLine && d.breakpoints[token.File][token.Line] {
+		reason = StopBreakpoint
+	}
+	d.mu.Unlock()
+
+	if reason == "" && d.stepping(frame, newLine) {
+		reason = StopStep
+	}
+	if reason == "" {
+		return nil
+	}
+
+	stop := &Stop{Reason: reason, File: token.File, Line: token.Line}
+	for idx := len(d.frames) - 1; idx >= 0; idx-- {
+		stop.Frames = append(stop.Frames, d.frames[idx])
+	}
+
+	d.action = d.OnStop(stop)
+	d.stepFrame, d.stepDepth = frame, len(d.frames)
+
+	if d.action == Terminate {
+		d.Terminate()
+		return ErrTerminated
+	}
+
+	return nil
+}
+
+// stepping reports whether the step the program is doing ends before the
+// statement about to run in frame.
+func (d *Debugger) stepping(frame *DebugFrame, newLine bool) bool {
+	switch d.action {
+	case StepIn:
+		return newLine || frame != d.stepFrame
+	case StepOver:
+		return len(d.frames) < d.stepDepth || (frame == d.stepFrame && newLine)
+	case StepOut:
+		return len(d.frames) < d.stepDepth
+	}
+
+	return false
+}
+
+// stmtToken returns the token that starts a statement, or nil if the
+// statement does not stop the debugger. Blocks do not, the statements in
+// them do.
+func stmtToken(stmt Stmt) *Token {
+	switch s := stmt.(type) {
+	case *Expression:
+		return exprToken(s.Expression)
+	case *Print:
+		return s.Keyword
+	case *Var:
+		return s.Name
+	case *If:
+		return s.Keyword
+	case *While:
+		return s.Keyword
+	case *Break:
+		return s.Keyword
+	case *Function:
+		return s.Name
+	case *Throw:
+		return s.Keyword
+	case *Try:
+		return s.Keyword
+	case *Return:
+		return s.Keyword
+	case *Class:
+		return s.Name
+	case *Import:
+		return s.Keyword
+	case *Export:
+		return s.Keyword
+	}
+
+	return nil
+}
+
+// exprToken returns the leftmost token of an expression that has one.
+func exprToken(expr Expr) *Token {
+	switch e := expr.(type) {
+	case *Binary:
+		if token := exprToken(e.Left); token != nil {
+			return token
+		}
+		return e.Operator
+	case *Logical:
+		if token := exprToken(e.Left); token != nil {
+			return token
+		}
+		return e.Operator
+	case *Grouping:
+		return exprToken(e.Expression)
+	case *Interpolated:
+		return exprToken(e.Expression)
+	case *Unary:
+		return e.Operator
+	case *Conditional:
+		return exprToken(e.Cond)
+	case *Variable:
+		return e.Name
+	case *Assign:
+		return e.Name
+	case *Call:
+		if token := exprToken(e.Callee); token != nil {
+			return token
+		}
+		return e.Paren
+	case *Get:
+		if token := exprToken(e.Object); token != nil {
+			return token
+		}
+		return e.Name
+	case *Set:
+		if token := exprToken(e.Object); token != nil {
+			return token
+		}
+		return e.Name
+	case *This:
+		return e.Keyword
+	case *Super:
+		return e.Keyword
+	case *ListLiteral:
+		return e.Bracket
+	case *MapLiteral:
+		return e.Brace
+	case *Index:
+		if token := exprToken(e.Object); token != nil {
+			return token
+		}
+		return e.Bracket
+	case *SetIndex:
+		if token := exprToken(e.Object); token != nil {
+			return token
+		}
+		return e.Bracket
+	}
+
+	return nil
+}
+
+// FormatValue returns value the way the debugger shows it: like print
+// does, but with strings quoted.
+func FormatValue(value Value) string {
+	return repr(value)
+}
+
+// Members returns the parts of a value a debugger can expand: the fields
+// of an instance, the elements of a list, the entries of a map and the
+// exports of a module. It returns nil for the other values.
+func Members(value Value) []Binding {
+	switch v := value.(type) {
+	case *LoxInstance:
+		names := make([]string, 0, len(v.Fields))
+		for name := range v.Fields {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		members := make([]Binding, len(names))
+		for idx, name := range names {
+			members[idx] = Binding{Name: name, Value: v.Fields[name]}
+		}
+		return members
+	case *LoxList:
+		members := make([]Binding, len(v.Elements))
+		for idx, element := range v.Elements {
+			members[idx] = Binding{Name: strconv.Itoa(idx), Value: element}
+		}
+		return members
+	case *LoxMap:
+		members := make([]Binding, len(v.keys))
+		for idx, key := range v.keys {
+			members[idx] = Binding{Name: repr(key), Value: v.entries[key]}
+		}
+		return members
+	case *LoxModule:
+		members := []Binding{}
+		for _, binding := range v.globals.Bindings() {
+			if v.exports == nil || v.exports[binding.Name] {
+				members = append(members, binding)
+			}
+		}
+		return members
+	case *LoxError:
+		return []Binding{{Name: "message", Value: v.Message}, {Name: "line", Value: int64(v.Line)}}
+	}
+
+	return nil
+}
@@ -1,8 +1,21 @@
 package glox
 
+import "sort"
+
 // Environment stores variable values.
+//
+// The variables of a local scope are stored in a slice and accessed by the
+// slot index the Resolver assigned to them, which is the order in which they
+// are declared in the scope. The global environment is different: globals
+// are late bound and never resolved, so they are stored by name.
 type Environment struct {
-	// a mapping of variable names to their values.
+	// slots holds the values of the local variables, indexed by slot, and
+	// names their names, for the debugger.
+	slots []interface{}
+	names []string
+
+	// values is a mapping of variable names to their values. It is only
+	// used by the global environment.
 	values map[string]interface{}
 
 	// enclosing is the parent environment of this environment.
@@ -11,59 +24,155 @@ type Environment struct {
 	enclosing *Environment
 }
 
-// NewEnvironment returns an Environment.
+// NewEnvironment returns an Environment for a local scope.
 func NewEnvironment(enclosing *Environment) *Environment {
+	return &Environment{
+		enclosing: enclosing,
+	}
+}
+
+// NewGlobalEnvironment returns an Environment that stores its variables
+// by name. Every module has a global environment of its own, enclosed by
+// the environment of the builtins that all modules share.
+func NewGlobalEnvironment(enclosing *Environment) *Environment {
 	return &Environment{
 		values: make(map[string]interface{}),
 		enclosing: enclosing,
 	}
 }
 
-// Define defines a new variable in the current environment.
+// Define defines a new variable in the current environment. In a local
+// scope the variable takes the next slot, so variables must be defined in
+// the order the Resolver declared them.
 func (e *Environment) Define(name string, value interface{}) {
-	e.values[name] = value
+	if e.values != nil {
+		e.values[name] = value
+		return
+	}
+
+	e.slots = append(e.slots, value)
+	e.names = append(e.names, name)
 }
 
-// Get looks up a variable from the environment.
+// Get looks up a global variable by name.
 // It firstly looks at the current environment, and goes up from
 // its parent environment.
 // It will return a RuntimeError if the variable is still not
 // found when it reaches the top-level environment.
 func (e *Environment) Get(name *Token) (interface{}, error) {
-	val, defined := e.values[name.Lexeme]
+	val, defined := e.lookup(name.Lexeme)
 	if !defined {
-		if e.enclosing != nil {
-			return e.enclosing.Get(name)
-		}
-
 		return nil, NewRuntimeError(name, "Undefined variable '" + name.Lexeme + "'.")
 	}
 
 	return val, nil
 }
 
-func (e *Environment) GetAt(distance int, name string) interface{} {
-	return e.ancestor(distance).values[name]
+// lookup is Get without the error, for callers that have no token.
+func (e *Environment) lookup(name string) (interface{}, bool) {
+	for env := e; env != nil; env = env.enclosing {
+		if val, defined := env.values[name]; defined {
+			return val, true
+		}
+	}
+
+	return nil, false
 }
 
-// Assign assigns a new value to the variable.
+// GetAt returns the value in the slot of the environment distance hops up
+// the parent chain.
+func (e *Environment) GetAt(distance int, slot int) interface{} {
+	return e.ancestor(distance).slots[slot]
+}
+
+// Assign assigns a new value to a global variable.
 // It looks up the variable in the same way as Get(), and it
 // assigns value to the variable when finds it.
 func (e *Environment) Assign(name *Token, val interface{}) error {
-	if _, defined := e.values[name.Lexeme]; !defined {
-		if e.enclosing != nil {
-			return e.enclosing.Assign(name, val)
-		}
-
+	if !e.assign(name.Lexeme, val) {
 		return NewRuntimeError(name, "Undefined variable '" + name.Lexeme + "'.")
 	}
 
-	e.values[name.Lexeme] = val
 	return nil
 }
 
-func (e *Environment) AssignAt(distance int, name *Token, val interface{}) {
-	e.ancestor(distance).values[name.Lexeme] = val
+// assign is Assign without the error, for callers that have no token.
+func (e *Environment) assign(name string, val interface{}) bool {
+	for env := e; env != nil; env = env.enclosing {
+		if _, defined := env.values[name]; defined {
+			env.values[name] = val
+			return true
+		}
+	}
+
+	return false
+}
+
+// AssignAt assigns a new value to the slot of the environment distance
+// hops up the parent chain.
+func (e *Environment) AssignAt(distance int, slot int, val interface{}) {
+	e.ancestor(distance).slots[slot] = val
+}
+
+// Binding is a variable and its value, as shown by the debugger.
+type Binding struct {
+	Name  string
+	Value Value
+}
+
+// Bindings returns the variables of the environment. Local variables are in
+// the order they were defined, globals are sorted by name.
+func (e *Environment) Bindings() []Binding {
+	if e.values == nil {
+		bindings := make([]Binding, len(e.slots))
+		for idx, val := range e.slots {
+			bindings[idx] = Binding{Name: e.names[idx], Value: val}
+		}
+		return bindings
+	}
+
+	names := make([]string, 0, len(e.values))
+	for name := range e.values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	bindings := make([]Binding, len(names))
+	for idx, name := range names {
+		bindings[idx] = Binding{Name: name, Value: e.values[name]}
+	}
+	return bindings
+}
+
+// Lookup returns the value of the variable name, searching this environment
+// and then the enclosing ones.
+func (e *Environment) Lookup(name string) (Value, bool) {
+	for env := e; env != nil; env = env.enclosing {
+		if env.values != nil {
+			if val, defined := env.values[name]; defined {
+				return val, true
+			}
+			continue
+		}
+
+		for idx := len(env.names) - 1; idx >= 0; idx-- {
+			if env.names[idx] == name {
+				return env.slots[idx], true
+			}
+		}
+	}
+
+	return nil, false
+}
+
+// Enclosing returns the parent environment, or nil for the builtins.
+func (e *Environment) Enclosing() *Environment {
+	return e.enclosing
+}
+
+// IsGlobal reports whether the environment holds global variables.
+func (e *Environment) IsGlobal() bool {
+	return e.values != nil
 }
 
 // ancestor walks a fixed number of hops up the parent chain and returns the environment there.
@@ -2,42 +2,279 @@ package glox
 
 import (
 	"fmt"
-	"log"
+	"io"
+	"strings"
+	"unicode/utf8"
 )
 
 type ErrorPrinter struct {
 	hadError bool
 	hadRuntimeError bool
+
+	// writer is the diagnostics sink every error is reported to.
+	writer io.Writer
+
+	// errors collects every static error reported since the last Reset so
+	// that embedders can inspect them instead of scraping the log.
+	errors []*SyntaxError
+
+	// warnings collects the warnings reported since the last Reset. They
+	// don't count as errors.
+	warnings []*Warning
+
+	// file names the module the reported errors are in. It is empty for
+	// the main script.
+	file string
+
+	// source is the text being compiled. Errors at a token quote the
+	// token's line from it.
+	source string
 }
 
-func NewErrorPrinter() *ErrorPrinter {
+// NewErrorPrinter returns an ErrorPrinter that reports errors to w. Pass
+// io.Discard to only collect them.
+func NewErrorPrinter(w io.Writer) *ErrorPrinter {
 	return &ErrorPrinter{
 		hadError: false,
 		hadRuntimeError: false,
+		writer: w,
 	}
 }
 
+// SetSource sets the source text that the following errors are reported
+// in, so that the reports can show the offending line.
+func (ep *ErrorPrinter) SetSource(source string) {
+	ep.source = source
+}
+
+// Error reports an error at a line, without a more precise position.
 func (ep *ErrorPrinter) Error(line uint32, message string) {
-	ep.report(line, "", message)
+	ep.report(Token{Line: line}, "", message)
+}
+
+// ErrorAt reports an error at the span of the token without quoting the
+// token, for errors in text that is not a complete token, such as an
+// unterminated string.
+func (ep *ErrorPrinter) ErrorAt(token Token, message string) {
+	ep.report(token, "", message)
 }
 
 func (ep *ErrorPrinter) TokenError(token Token, message string) {
 	if token.Type == EOF {
-		ep.report(token.Line, " at end ", message)
+		ep.report(token, " at end ", message)
 	} else {
-		ep.report(token.Line, " at '" + token.Lexeme + "'", message)
+		ep.report(token, " at '" + token.Lexeme + "'", message)
+	}
+}
+
+// Warning reports a warning at the token. Unlike the errors, a warning does
+// not stop the code from running.
+func (ep *ErrorPrinter) Warning(token Token, code WarningCode, message string) {
+	warning := &Warning{
+		Code: code,
+		File: ep.file,
+		Line: token.Line,
+		Column: token.Column,
+		Offset: token.Offset,
+		Length: token.Length,
+		Message: message,
 	}
+
+	fmt.Fprintln(ep.writer, warning.String())
+	fmt.Fprint(ep.writer, ep.snippet(token))
+	ep.warnings = append(ep.warnings, warning)
 }
 
+// RuntimeError reports a runtime error. A *CompileError can be raised at
+// runtime too, by importing a module with static errors, but those have
+// been reported while compiling the module.
 func (ep *ErrorPrinter) RuntimeError(err error) {
-	runtimeErr := err.(*runtimeError)
-	fmt.Printf("%s\n[line %d]\n", runtimeErr.Error(), runtimeErr.Token.Line)
 	ep.hadRuntimeError = true
+
+	if limitErr, isLimitError := err.(*LimitError); isLimitError {
+		if limitErr.Token != nil {
+			fmt.Fprintf(ep.writer, "%s\n[%s]\n", limitErr.Error(), location(limitErr.Token.File, limitErr.Token.Line))
+		} else {
+			fmt.Fprintln(ep.writer, limitErr.Error())
+		}
+		return
+	}
+
+	runtimeErr, isRuntimeError := err.(*RuntimeError)
+	if !isRuntimeError {
+		if _, isCompileError := err.(*CompileError); !isCompileError {
+			fmt.Fprintln(ep.writer, err.Error())
+		}
+		return
+	}
+
+	fmt.Fprintf(ep.writer, "%s\n[%s]\n", runtimeErr.Error(), location(runtimeErr.Token.File, runtimeErr.Token.Line))
+
+	// the trace is only worth printing if the error was raised in a
+	// function.
+	if len(runtimeErr.Trace) > 1 {
+		printTrace(ep.writer, runtimeErr.Trace)
+	}
+}
+
+// maxPrintedFrames bounds the frames printed for a deep trace, such as the
+// trace of a runaway recursion. The innermost and outermost frames are
+// printed.
+const maxPrintedFrames = 20
+
+func printTrace(w io.Writer, trace []StackFrame) {
+	if len(trace) <= maxPrintedFrames {
+		for _, frame := range trace {
+			fmt.Fprintf(w, "  %s\n", frame)
+		}
+		return
+	}
+
+	for _, frame := range trace[:maxPrintedFrames/2] {
+		fmt.Fprintf(w, "  %s\n", frame)
+	}
+	fmt.Fprintf(w, "  ... %d more frames\n", len(trace) - maxPrintedFrames)
+	for _, frame := range trace[len(trace)-maxPrintedFrames/2:] {
+		fmt.Fprintf(w, "  %s\n", frame)
+	}
+}
+
+// HadError reports whether a scanning, parsing or resolving error has been
+// reported since the last Reset.
+func (ep *ErrorPrinter) HadError() bool {
+	return ep.hadError
 }
 
-func (ep *ErrorPrinter) report(line uint32, where string, message string) {
-	log.Printf("[line %v] Error %v: %v\n", line, where, message)
+// HadRuntimeError reports whether a runtime error has been reported since
+// the last Reset.
+func (ep *ErrorPrinter) HadRuntimeError() bool {
+	return ep.hadRuntimeError
+}
+
+// Errors returns the static errors reported since the last Reset.
+func (ep *ErrorPrinter) Errors() []*SyntaxError {
+	return ep.errors
+}
+
+// Warnings returns the warnings reported since the last Reset.
+func (ep *ErrorPrinter) Warnings() []*Warning {
+	return ep.warnings
+}
+
+// Reset clears the error state so the printer can be reused for the next
+// piece of source, e.g. the next line typed into the REPL.
+func (ep *ErrorPrinter) Reset() {
+	ep.hadError = false
+	ep.hadRuntimeError = false
+	ep.errors = nil
+	ep.warnings = nil
+}
+
+func (ep *ErrorPrinter) report(token Token, where string, message string) {
+	fmt.Fprintf(ep.writer, "[%v] Error %v: %v\n", location(ep.file, token.Line), where, message)
+	fmt.Fprint(ep.writer, ep.snippet(token))
+
 	ep.hadError = true
+	ep.errors = append(ep.errors, &SyntaxError{
+		File: ep.file,
+		Line: token.Line,
+		Column: token.Column,
+		Offset: token.Offset,
+		Length: token.Length,
+		Where: where,
+		Message: message,
+	})
+}
+
+// snippet quotes the source line of the token and underlines the token:
+//
+//	    3 | print a b;
+//	      |         ^
+//
+// It returns "" if the token has no position in the source.
+func (ep *ErrorPrinter) snippet(token Token) string {
+	if token.Column == 0 || int(token.Offset + token.Length) > len(ep.source) {
+		return ""
+	}
+
+	lineStart := strings.LastIndexByte(ep.source[:token.Offset], '\n') + 1
+	lineEnd := len(ep.source)
+	if idx := strings.IndexByte(ep.source[token.Offset:], '\n'); idx != -1 {
+		lineEnd = int(token.Offset) + idx
+	}
+
+	// a token spanning several lines, like a multi-line string, is only
+	// underlined up to the end of its first line.
+	spanEnd := int(token.Offset + token.Length)
+	if spanEnd > lineEnd {
+		spanEnd = lineEnd
+	}
+
+	// keep the tabs of the line, so the caret lines up with the token.
+	var indent strings.Builder
+	for _, r := range ep.source[lineStart:token.Offset] {
+		if r == '\t' {
+			indent.WriteRune('\t')
+		} else {
+			indent.WriteRune(' ')
+		}
+	}
+
+	width := utf8.RuneCountInString(ep.source[token.Offset:spanEnd])
+	if width == 0 {
+		width = 1
+	}
+
+	gutter := fmt.Sprintf("%5d | ", token.Line)
+	return fmt.Sprintf("%s%s\n%s| %s%s\n",
+		gutter, strings.TrimRight(ep.source[lineStart:lineEnd], "\r"),
+		strings.Repeat(" ", len(gutter) - 2), indent.String(), strings.Repeat("^", width))
+}
+
+// location formats a source position for error messages. The file is
+// left out for the main script.
+func location(file string, line uint32) string {
+	if file == "" {
+		return fmt.Sprintf("line %d", line)
+	}
+
+	return fmt.Sprintf("%s, line %d", file, line)
+}
+
+// SyntaxError describes a single error found while scanning, parsing or
+// resolving the source, before any code is run.
+type SyntaxError struct {
+	// File is the module the error is in, empty for the main script.
+	File    string
+	Line    uint32
+
+	// Column, Offset and Length locate the span of the error in the
+	// source, as in Token. Column is 0 if only the line is known.
+	Column  uint32
+	Offset  uint32
+	Length  uint32
+
+	Where   string
+	Message string
+}
+
+func (se *SyntaxError) Error() string {
+	return fmt.Sprintf("[%v] Error%v: %v", location(se.File, se.Line), se.Where, se.Message)
+}
+
+// CompileError is returned by the VM when the source could not be run
+// because of one or more static errors.
+type CompileError struct {
+	Errors []*SyntaxError
+}
+
+func (ce *CompileError) Error() string {
+	messages := make([]string, 0, len(ce.Errors))
+	for _, err := range ce.Errors {
+		messages = append(messages, err.Error())
+	}
+
+	return strings.Join(messages, "\n")
 }
 
 // parserError represents the errors that occured during parsing.
@@ -55,23 +292,126 @@ func (pe *parserError) Error() string {
 	return pe.message
 }
 
-// runtimeError represents the errors that occured during interpreting.
-type runtimeError struct {
+// RuntimeError represents the errors that occured during interpreting.
+// Both the errors raised by the interpreter and the values thrown by throw
+// statements are RuntimeErrors, so both can be caught by try statements.
+type RuntimeError struct {
 	Token *Token
 	message string
+
+	// value is the value of a throw statement. isThrown distinguishes
+	// throw nil from the errors raised by the interpreter.
+	value interface{}
+	isThrown bool
+
+	// Trace lists the calls that were in progress when the error was
+	// raised, innermost first. The last frame is the top level of the
+	// script.
+	Trace []StackFrame
+
+	// file and line locate the error in the innermost frame that has not
+	// been added to Trace yet, as the Interpreter unwinds the calls.
+	file string
+	line uint32
+}
+
+// StackFrame is a call that was in progress when a runtime error was
+// raised.
+type StackFrame struct {
+	// Function is the name of the function, "<script>" for the top level
+	// of the script or of a module.
+	Function string
+
+	// File and Line locate the code the frame was executing: the error
+	// itself in the innermost frame, a call in the others. File is empty
+	// for the main script.
+	File string
+	Line uint32
+}
+
+func (sf StackFrame) String() string {
+	return fmt.Sprintf("at %s (%s)", sf.Function, location(sf.File, sf.Line))
 }
 
-func NewRuntimeError(token *Token, message string) *runtimeError {
-	return &runtimeError{
+func NewRuntimeError(token *Token, message string) *RuntimeError {
+	return &RuntimeError{
 		Token: token,
 		message: message,
+		file: token.File,
+		line: token.Line,
 	}
 }
 
-func (re *runtimeError) Error() string {
+// NewThrowError returns the error for a throw statement at token.
+func NewThrowError(token *Token, value interface{}) *RuntimeError {
+	message := stringify(value)
+	if loxError, isLoxError := value.(*LoxError); isLoxError {
+		message = loxError.Message
+	}
+
+	return &RuntimeError{
+		Token: token,
+		message: message,
+		value: value,
+		isThrown: true,
+		file: token.File,
+		line: token.Line,
+	}
+}
+
+// exitFrame adds the frame of function to the trace, as the error leaves
+// the function.
+func (re *RuntimeError) exitFrame(function string) {
+	re.Trace = append(re.Trace, StackFrame{Function: frameName(function), File: re.file, Line: re.line})
+}
+
+// setCallSite sets the position in the frame the error unwinds to next: the
+// call of the function it left.
+func (re *RuntimeError) setCallSite(token *Token) {
+	re.file, re.line = token.File, token.Line
+}
+
+// frameName returns the name of a function as it appears in stack traces.
+func frameName(function string) string {
+	if function == "" {
+		return "<anonymous function>"
+	}
+
+	return function
+}
+
+// Thrown returns the value of the throw statement that raised the error.
+// ok is false for the errors raised by the interpreter itself.
+func (re *RuntimeError) Thrown() (value Value, ok bool) {
+	return re.value, re.isThrown
+}
+
+// errorValue returns the value a catch clause receives for the error.
+func (re *RuntimeError) errorValue() interface{} {
+	if re.isThrown {
+		return re.value
+	}
+
+	return &LoxError{Message: re.message, Line: re.Line()}
+}
+
+func (re *RuntimeError) Error() string {
 	return re.message
 }
 
+// Line returns the line of the token where the error occured.
+func (re *RuntimeError) Line() uint32 {
+	return re.Token.Line
+}
+
+// isFatal reports whether err stops the whole program. A fatal error is not
+// a RuntimeError: try statements do not catch it and natives that call back
+// into Lox code pass it on unchanged.
+func isFatal(err error) bool {
+	_, isLimitError := err.(*LimitError)
+	return err == ErrTerminated || isLimitError
+}
+
 // breakError is used to break loop.
 type breakError struct {
 }
@@ -15,6 +15,11 @@ type ExprVisitor interface {
     VisitSetExpr(expr *Set) (interface{}, error)
     VisitThisExpr(expr *This) (interface{}, error)
     VisitSuperExpr(expr *Super) (interface{}, error)
+    VisitListLiteralExpr(expr *ListLiteral) (interface{}, error)
+    VisitIndexExpr(expr *Index) (interface{}, error)
+    VisitSetIndexExpr(expr *SetIndex) (interface{}, error)
+    VisitMapLiteralExpr(expr *MapLiteral) (interface{}, error)
+    VisitInterpolatedExpr(expr *Interpolated) (interface{}, error)
 }
 
 type Expr interface {
@@ -148,3 +153,51 @@ func (s *Super) Accept(visitor ExprVisitor) (interface{}, error) {
     return visitor.VisitSuperExpr(s)
 }
 
+type ListLiteral struct {
+    Bracket *Token
+    Elements []Expr
+}
+
+func (l *ListLiteral) Accept(visitor ExprVisitor) (interface{}, error) {
+    return visitor.VisitListLiteralExpr(l)
+}
+
+type Index struct {
+    Object Expr
+    Bracket *Token
+    Index Expr
+}
+
+func (i *Index) Accept(visitor ExprVisitor) (interface{}, error) {
+    return visitor.VisitIndexExpr(i)
+}
+
+type SetIndex struct {
+    Object Expr
+    Bracket *Token
+    Index Expr
+    Value Expr
+}
+
+func (s *SetIndex) Accept(visitor ExprVisitor) (interface{}, error) {
+    return visitor.VisitSetIndexExpr(s)
+}
+
+type MapLiteral struct {
+    Brace *Token
+    Keys []Expr
+    Values []Expr
+}
+
+func (m *MapLiteral) Accept(visitor ExprVisitor) (interface{}, error) {
+    return visitor.VisitMapLiteralExpr(m)
+}
+
+type Interpolated struct {
+    Expression Expr
+}
+
+func (i *Interpolated) Accept(visitor ExprVisitor) (interface{}, error) {
+    return visitor.VisitInterpolatedExpr(i)
+}
+
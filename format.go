@@ -0,0 +1,448 @@
+package glox
+
+import (
+	"errors"
+	"io"
+	"strings"
+)
+
+// formatIndent is one level of indentation in formatted code.
+const formatIndent = "  "
+
+// Format returns source printed in the canonical style: statements on
+// lines of their own, blocks indented by two spaces with the opening brace
+// at the end of the line, and single spaces around binary operators and
+// after commas. Comments are kept, and so are single blank lines and the
+// line breaks inside lists, maps and argument lists. Formatting formatted
+// code does not change it.
+//
+// Source with static errors is not formatted; the errors are returned as a
+// *CompileError.
+func Format(source string) (string, error) {
+	errorPrinter := NewErrorPrinter(io.Discard)
+	errorPrinter.SetSource(source)
+
+	NewParser(NewScanner(source, errorPrinter).ScanTokens(), errorPrinter).Parse()
+	if errorPrinter.hadError {
+		return "", &CompileError{Errors: errorPrinter.Errors()}
+	}
+
+	tokens := scanWithComments(source)
+	formatted := newFormatter(tokens).format()
+
+	// the formatter only changes the space between the tokens. Check it,
+	// so that a bug in it can't change what the code does.
+	if !sameTokens(tokens, scanWithComments(formatted)) {
+		return "", errors.New("formatting changed the tokens of the code")
+	}
+
+	return formatted, nil
+}
+
+func scanWithComments(source string) []Token {
+	scanner := NewScanner(source, NewErrorPrinter(io.Discard))
+	scanner.SetKeepComments(true)
+
+	return scanner.ScanTokens()
+}
+
+// sameTokens reports whether two token lists read the same. Comments only
+// lose their trailing spaces.
+func sameTokens(a []Token, b []Token) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for idx := range a {
+		if a[idx].Type != b[idx].Type {
+			return false
+		}
+		if a[idx].Type != COMMENT && a[idx].Lexeme != b[idx].Lexeme {
+			return false
+		}
+	}
+
+	return true
+}
+
+// delimiterKind tells what a paren, bracket or brace belongs to.
+type delimiterKind int
+
+const (
+	delimiterGroup delimiterKind = iota // groups, calls, lists and maps
+	delimiterFor                        // the clauses of a for loop
+	delimiterBlock                      // blocks and bodies
+)
+
+// delimiter is an open paren, bracket or brace.
+type delimiter struct {
+	token *Token
+	kind  delimiterKind
+
+	// indent is the indentation of the line the delimiter is on.
+	indent int
+
+	// broken is set if the source breaks the line right after the
+	// delimiter. The elements then go on lines of their own, indented by
+	// one level, and so does the closing delimiter at the outer level.
+	broken bool
+
+	// continued is set once an element of a delimiter that is not broken
+	// starts a new line. The following lines are indented by one level.
+	continued bool
+
+	// questions counts the ? of conditionals waiting for their :, to tell
+	// them from the : of map entries.
+	questions int
+}
+
+// formatter prints the tokens of a source in the canonical style. It only
+// decides what goes between two tokens: nothing, a space or a line break.
+type formatter struct {
+	tokens []Token
+
+	// kinds holds the delimiter kind of each paren, bracket and brace.
+	kinds []delimiterKind
+
+	out        strings.Builder
+	indent     int
+	delimiters []*delimiter
+
+	// prev is the index of the last token printed, comments included, and
+	// code the index of the last token printed that is not a comment.
+	// Both are -1 at the start.
+	prev int
+	code int
+
+	// unary is set if the last minus printed is a unary minus.
+	unary bool
+
+	// newline is set when the next token has to start a new line, and
+	// atStatementStart when that line starts a statement or an element
+	// rather than continuing one.
+	newline          bool
+	atStatementStart bool
+}
+
+func newFormatter(tokens []Token) *formatter {
+	f := &formatter{tokens: tokens, prev: -1, code: -1, atStatementStart: true}
+	f.classify()
+
+	return f
+}
+
+// classify finds the kind of each delimiter. A brace where a statement can
+// start opens a block, unless it opens a map literal in an expression
+// statement; the parser tells them apart the same way.
+func (f *formatter) classify() {
+	f.kinds = make([]delimiterKind, len(f.tokens))
+	open := []int{}
+	prev := -1
+
+	for idx := range f.tokens {
+		switch f.tokens[idx].Type {
+		case COMMENT:
+			continue
+		case LEFT_PAREN:
+			if prev >= 0 && f.tokens[prev].Type == FOR {
+				f.kinds[idx] = delimiterFor
+			}
+			open = append(open, idx)
+		case LEFT_BRACKET:
+			open = append(open, idx)
+		case LEFT_BRACE:
+			if f.startsStatement(prev, open) && !f.isMapLiteral(idx) {
+				f.kinds[idx] = delimiterBlock
+			}
+			open = append(open, idx)
+		case RIGHT_PAREN, RIGHT_BRACKET, RIGHT_BRACE:
+			if len(open) > 0 {
+				f.kinds[idx] = f.kinds[open[len(open)-1]]
+				open = open[:len(open)-1]
+			}
+		}
+		prev = idx
+	}
+}
+
+// startsStatement reports whether a statement can start after the code
+// token at prev, given the delimiters open at that point.
+func (f *formatter) startsStatement(prev int, open []int) bool {
+	if prev < 0 {
+		return true
+	}
+
+	switch f.tokens[prev].Type {
+	case RIGHT_PAREN, ELSE, TRY, FINALLY, IDENTIFIER:
+		// bodies, and the brace of a class.
+		return true
+	case SEMICOLON:
+		return len(open) == 0 || f.kinds[open[len(open)-1]] == delimiterBlock
+	case LEFT_BRACE, RIGHT_BRACE:
+		return f.kinds[prev] == delimiterBlock
+	}
+
+	return false
+}
+
+// isMapLiteral reports whether the brace at idx opens a map literal, as
+// Parser.isMapLiteral does.
+func (f *formatter) isMapLiteral(idx int) bool {
+	depth := 0
+	for next := idx + 1; next < len(f.tokens); next++ {
+		switch f.tokens[next].Type {
+		case LEFT_PAREN, LEFT_BRACKET:
+			depth++
+		case RIGHT_PAREN, RIGHT_BRACKET:
+			depth--
+		case COLON:
+			if depth == 0 {
+				return true
+			}
+		case QUESTION_MARK:
+			if depth == 0 {
+				return false
+			}
+		case SEMICOLON, LEFT_BRACE, RIGHT_BRACE, EOF:
+			return false
+		}
+	}
+
+	return false
+}
+
+func (f *formatter) format() string {
+	for idx := range f.tokens {
+		if f.tokens[idx].Type == EOF {
+			break
+		}
+
+		if f.tokens[idx].Type == COMMENT {
+			f.comment(idx)
+		} else {
+			f.token(idx)
+		}
+		f.prev = idx
+	}
+
+	if f.prev < 0 {
+		return ""
+	}
+	f.out.WriteString("\n")
+	return f.out.String()
+}
+
+// comment prints a comment. A comment on the line of the previous token
+// stays there; any other comment gets a line of its own.
+func (f *formatter) comment(idx int) {
+	token := &f.tokens[idx]
+	text := strings.TrimRight(token.Lexeme, " \t\r")
+
+	switch {
+	case f.prev < 0:
+	case token.Line == endLine(&f.tokens[f.prev]):
+		f.out.WriteString(" ")
+	default:
+		f.lineBreak(idx, f.atStatementStart)
+	}
+	f.out.WriteString(text)
+
+	// a line comment runs to the end of the line. A block comment keeps the
+	// code after it on its line.
+	if strings.HasPrefix(text, "//") || f.tokens[idx+1].Line > endLine(token) {
+		f.newline = true
+	}
+}
+
+func (f *formatter) token(idx int) {
+	token := &f.tokens[idx]
+
+	var closed *delimiter
+	if isClosing(token.Type) && len(f.delimiters) > 0 {
+		closed = f.delimiters[len(f.delimiters)-1]
+		f.delimiters = f.delimiters[:len(f.delimiters)-1]
+		f.indent = closed.indent
+	}
+
+	switch {
+	case f.prev < 0:
+	case closed != nil && closed.kind == delimiterBlock:
+		// an empty block stays on one line.
+		if f.tokens[f.prev].Type != LEFT_BRACE {
+			f.lineBreak(idx, true)
+		}
+	case closed != nil && closed.broken:
+		f.lineBreak(idx, true)
+	case f.newline:
+		f.lineBreak(idx, f.atStatementStart)
+	case f.space(idx):
+		f.out.WriteString(" ")
+	}
+
+	if token.Type == MINUS {
+		f.unary = !f.endsValue()
+	}
+
+	f.out.WriteString(token.Lexeme)
+	f.newline, f.atStatementStart = false, false
+
+	switch token.Type {
+	case LEFT_PAREN, LEFT_BRACKET, LEFT_BRACE:
+		open := &delimiter{token: token, kind: f.kinds[idx], indent: f.indent}
+		f.delimiters = append(f.delimiters, open)
+		next := f.nextCode(idx)
+
+		switch {
+		case open.kind == delimiterBlock:
+			f.indent++
+			f.newline = f.tokens[idx+1].Type != RIGHT_BRACE
+			f.atStatementStart = true
+		case next.Line > token.Line && !isClosing(next.Type):
+			open.broken = true
+			f.indent++
+			f.newline, f.atStatementStart = true, true
+		}
+	case RIGHT_BRACE:
+		if closed != nil && closed.kind == delimiterBlock {
+			switch f.nextCode(idx).Type {
+			case ELSE, CATCH, FINALLY:
+				// the statement goes on after the brace. If a comment
+				// pushes it to the next line, it lines up with the brace.
+				f.atStatementStart = true
+			case RIGHT_PAREN, RIGHT_BRACKET, COMMA, SEMICOLON, DOT, LEFT_PAREN:
+			default:
+				f.newline, f.atStatementStart = true, true
+			}
+		}
+	case SEMICOLON:
+		if top := f.top(); top == nil || top.kind == delimiterBlock {
+			f.newline, f.atStatementStart = true, true
+		}
+	case COMMA:
+		if next := f.nextCode(idx); next.Line > token.Line {
+			top := f.top()
+			if top != nil && !top.broken && !top.continued {
+				top.continued = true
+				f.indent++
+			}
+			f.newline, f.atStatementStart = true, true
+		}
+	case QUESTION_MARK:
+		if top := f.top(); top != nil {
+			top.questions++
+		}
+	case COLON:
+		if top := f.top(); top != nil && top.questions > 0 {
+			top.questions--
+		}
+	}
+
+	f.code = idx
+}
+
+// lineBreak ends the line before the token at idx. A blank line in the
+// source is kept, except after an opening and before a closing delimiter.
+// A line that continues a statement is indented by one more level.
+func (f *formatter) lineBreak(idx int, atStatementStart bool) {
+	f.out.WriteString("\n")
+
+	prev := &f.tokens[f.prev]
+	switch {
+	case prev.Type == LEFT_PAREN || prev.Type == LEFT_BRACKET || prev.Type == LEFT_BRACE:
+	case isClosing(f.tokens[idx].Type):
+	case f.tokens[idx].Line > endLine(prev)+1:
+		f.out.WriteString("\n")
+	}
+
+	indent := f.indent
+	if !atStatementStart {
+		indent++
+	}
+	f.out.WriteString(strings.Repeat(formatIndent, indent))
+}
+
+// space reports whether a space goes between the last token printed and
+// the token at idx, on the same line.
+func (f *formatter) space(idx int) bool {
+	prev := &f.tokens[f.prev]
+	if prev.Type == COMMENT {
+		return true
+	}
+
+	switch f.tokens[idx].Type {
+	case COMMA, SEMICOLON, RIGHT_PAREN, RIGHT_BRACKET, RIGHT_BRACE, DOT:
+		return false
+	case STRING, INTERPOLATION:
+		// the rest of a string after an interpolated expression.
+		if strings.HasPrefix(f.tokens[idx].Lexeme, "}") {
+			return false
+		}
+	case LEFT_PAREN, LEFT_BRACKET:
+		// calls and indexing.
+		if f.endsValue() {
+			return false
+		}
+	case COLON:
+		// the : of a map entry.
+		top := f.top()
+		if top != nil && top.token.Type == LEFT_BRACE && top.kind == delimiterGroup && top.questions == 0 {
+			return false
+		}
+	}
+
+	switch prev.Type {
+	case LEFT_PAREN, LEFT_BRACKET, LEFT_BRACE, DOT, BANG, INTERPOLATION:
+		return false
+	case MINUS:
+		return !f.unary
+	}
+
+	return true
+}
+
+// endsValue reports whether the last code token printed ends an operand,
+// so that a following ( or [ is a call or an index and a following minus
+// is a binary minus.
+func (f *formatter) endsValue() bool {
+	if f.code < 0 {
+		return false
+	}
+
+	switch f.tokens[f.code].Type {
+	case IDENTIFIER, STRING, NUMBER, RIGHT_PAREN, RIGHT_BRACKET, THIS, TRUE, FALSE, NIL:
+		return true
+	case RIGHT_BRACE:
+		return f.kinds[f.code] == delimiterGroup
+	}
+
+	return false
+}
+
+func (f *formatter) top() *delimiter {
+	if len(f.delimiters) == 0 {
+		return nil
+	}
+
+	return f.delimiters[len(f.delimiters)-1]
+}
+
+// nextCode returns the first token after idx that is not a comment.
+func (f *formatter) nextCode(idx int) *Token {
+	for next := idx + 1; next < len(f.tokens); next++ {
+		if f.tokens[next].Type != COMMENT {
+			return &f.tokens[next]
+		}
+	}
+
+	return &f.tokens[len(f.tokens)-1]
+}
+
+func isClosing(tokenType TokenType) bool {
+	return tokenType == RIGHT_PAREN || tokenType == RIGHT_BRACKET || tokenType == RIGHT_BRACE
+}
+
+// endLine returns the line a token ends on. Strings and comments can span
+// lines.
+func endLine(token *Token) uint32 {
+	return token.Line + uint32(strings.Count(token.Lexeme, "\n"))
+}
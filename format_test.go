@@ -0,0 +1,33 @@
+package glox
+
+import "testing"
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   string
+	}{
+		{"spacing", "print 1+2*-x;", "print 1 + 2 * -x;\n"},
+		{"blocks", "if (x) { a(); } else { b(); }", "if (x) {\n  a();\n} else {\n  b();\n}\n"},
+		{"else after a comment", "if (x) {\n  a();\n} // after close\nelse b();\n", "if (x) {\n  a();\n} // after close\nelse b();\n"},
+		{"interpolation", `print "a${ 1+2 }b";`, "print \"a${1 + 2}b\";\n"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := Format(test.source)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != test.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, test.want)
+			}
+
+			again, err := Format(got)
+			if err != nil || again != got {
+				t.Errorf("formatting again: got:\n%s\n%v", again, err)
+			}
+		})
+	}
+}
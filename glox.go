@@ -1,104 +1,61 @@
 package glox
 
 import (
-	"bufio"
+	"errors"
 	"fmt"
 	"os"
 )
 
+// Glox is the command line front end. It runs a script file or starts the
+// REPL on top of a VM.
 type Glox struct {
-	interpreter *Interpreter
+	vm *VM
 
-	// errorPrinter receives and reports errors that occur during
-	// scanning, parsing and interpreting.
-	errorPrinter *ErrorPrinter
+	// opts configures the VM, and the new one the REPL makes on :reset.
+	opts *Options
 }
 
-func NewGlox() *Glox {
-	ep := NewErrorPrinter()
-
+// NewGlox returns a Glox whose VM is configured by opts.
+func NewGlox(opts *Options) *Glox {
 	return &Glox{
-		errorPrinter: ep,
-		interpreter: NewInterpreter(ep),
+		vm:   NewVM(opts),
+		opts: opts,
 	}
 }
 
 func (g *Glox) Run(args []string) {
 	if len(args) > 1 {
-		fmt.Println("Usage: glox [script]")
+		fmt.Fprintln(os.Stderr, "Usage: glox [-backend tree|bytecode] [--debug] [script]")
 		os.Exit(64)
 	}
 
 	if len(args) == 1 {
 		g.runFile(args[0])
 	} else {
-		g.runPrompt()
+		g.runPrompt(os.Stdin, os.Stdout)
 	}
 }
 
+// runFile runs the script and maps the error to the exit codes used by
+// sysexits.h: 65 for static errors, 70 for runtime errors.
 func (g *Glox) runFile(path string) {
-	bytes, err := os.ReadFile(path)
-	if err != nil {
-		panic(err)
-	}
-
-	g.run(string(bytes))
-
-	if g.errorPrinter.hadError {
-		os.Exit(65)
-	}
-
-	if g.errorPrinter.hadRuntimeError {
-		os.Exit(70)
-	}
-}
-
-func (g *Glox) runPrompt() {
-	reader := bufio.NewScanner(os.Stdin)
-	for {
-		g.errorPrinter.hadError = false
-
-		fmt.Print("> ")
-		if !reader.Scan() {
-			break
-		}
-		scanner := NewScanner(reader.Text(), g.errorPrinter)
-		tokens := scanner.ScanTokens()
-
-		parser := NewParser(tokens, g.errorPrinter)
-		syntax := parser.ParseREPL()
-
-		// If they enter a statement, execute it. And if they enter an expression,
-		// evaluate it and display the result value.
-		switch syntax.(type) {
-		case []Stmt:
-			g.interpreter.Interpret(syntax.([]Stmt))
-		case *Expression:
-			result := g.interpreter.InterpretREPL(syntax.(*Expression).Expression)
-			if result != "" {
-				fmt.Println("=", result)
-			}
-		}
-	}
-}
-
-func (g *Glox) run(source string) {
-	scanner := NewScanner(source, g.errorPrinter)
-	tokens := scanner.ScanTokens()
-
-	parser := NewParser(tokens, g.errorPrinter)
-	stmts := parser.Parse()
-
-	if g.errorPrinter.hadError {
+	err := g.vm.RunFile(path)
+	if err == nil {
 		return
 	}
 
-	resolver := NewResolver(g.interpreter, g.errorPrinter)
-	resolver.resolveStatements(stmts)
-
-	if g.errorPrinter.hadError {
+	var compileErr *CompileError
+	var runtimeErr *RuntimeError
+	var limitErr *LimitError
+	switch {
+	case err == ErrTerminated:
 		return
+	case errors.As(err, &compileErr):
+		os.Exit(65)
+	case errors.As(err, &runtimeErr), errors.As(err, &limitErr):
+		os.Exit(70)
+	default:
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(66)
 	}
-
-	g.interpreter.Interpret(stmts)
 }
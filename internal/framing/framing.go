@@ -0,0 +1,47 @@
+// Package framing reads and writes the messages of the Language Server and
+// Debug Adapter protocols. Both send every message as a JSON body preceded
+// by a Content-Length header and a blank line.
+package framing
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+)
+
+// ReadMessage reads the body of the next message. It returns io.EOF if the
+// stream ends before a new message starts.
+func ReadMessage(in *bufio.Reader) ([]byte, error) {
+	if _, err := in.Peek(1); err != nil {
+		return nil, err
+	}
+
+	header, err := textproto.NewReader(in).ReadMIMEHeader()
+	if err == io.EOF {
+		// the stream ended in the middle of the header.
+		return nil, io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	length, err := strconv.Atoi(header.Get("Content-Length"))
+	if err != nil || length < 0 {
+		return nil, fmt.Errorf("bad Content-Length header: %q", header.Get("Content-Length"))
+	}
+
+	body := make([]byte, length)
+	if _, err := io.ReadFull(in, body); err != nil {
+		return nil, err
+	}
+
+	return body, nil
+}
+
+// WriteMessage writes body as a message.
+func WriteMessage(out io.Writer, body []byte) error {
+	_, err := fmt.Fprintf(out, "Content-Length: %d\r\n\r\n%s", len(body), body)
+	return err
+}
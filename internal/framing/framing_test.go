@@ -0,0 +1,69 @@
+package framing
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	bodies := []string{`{"seq":1}`, ``, `{"text":"line\r\nnext ünicode"}`}
+
+	var stream bytes.Buffer
+	for _, body := range bodies {
+		if err := WriteMessage(&stream, []byte(body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	in := bufio.NewReader(&stream)
+	for _, want := range bodies {
+		body, err := ReadMessage(in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(body) != want {
+			t.Errorf("got %q, want %q", body, want)
+		}
+	}
+
+	if _, err := ReadMessage(in); err != io.EOF {
+		t.Errorf("at the end: got %v, want io.EOF", err)
+	}
+}
+
+func TestReadMessage(t *testing.T) {
+	tests := []struct {
+		name    string
+		stream  string
+		body    string
+		wantErr bool
+	}{
+		{"other headers", "Content-Type: application/json\r\nContent-Length: 2\r\n\r\n{}", "{}", false},
+		{"header case", "content-length: 2\r\n\r\n{}", "{}", false},
+		{"bare new lines", "Content-Length: 2\n\n{}", "{}", false},
+		{"missing length", "Content-Type: application/json\r\n\r\n{}", "", true},
+		{"bad length", "Content-Length: two\r\n\r\n{}", "", true},
+		{"negative length", "Content-Length: -1\r\n\r\n{}", "", true},
+		{"short body", "Content-Length: 10\r\n\r\n{}", "", true},
+		{"truncated header", "Content-Length: 2\r\n", "", true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			body, err := ReadMessage(bufio.NewReader(strings.NewReader(test.stream)))
+			if test.wantErr {
+				if err == nil || err == io.EOF {
+					t.Errorf("got %q, %v, want an error", body, err)
+				}
+				return
+			}
+
+			if err != nil || string(body) != test.body {
+				t.Errorf("got %q, %v, want %q", body, err, test.body)
+			}
+		})
+	}
+}
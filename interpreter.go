@@ -2,46 +2,148 @@ package glox
 
 import (
 	"fmt"
-	"strconv"
+	"io"
+	"math/rand"
 )
 
 type Interpreter struct {
 	// errorPrinter reports the runtimeErrors during interpreting.
 	errorPrinter *ErrorPrinter
 
+	// stdout receives the output of print statements.
+	stdout       io.Writer
+
 	// environment tracks the current environment. It changes as we enter
 	// and exit local scopes. 
 	environment  *Environment
 
-	// globals holds a fixed reference to the outermost global environment.
-	// It provides the interpreter with access to the native functions.
+	// globals holds a fixed reference to the global environment of the
+	// main script. Imported modules have global environments of their own.
 	globals		 *Environment
 
-	// locals stores the number of hops from the current environment to the
-	// environment where the variable is defined for every variables in the
-	// local scope.
-	locals		 map[Expr]int
+	// builtins encloses the global environment of every module. It holds
+	// the native functions.
+	builtins	 *Environment
+
+	// importer loads the modules for import statements. It is set by the
+	// VM that owns the interpreter.
+	importer	 moduleImporter
+
+	// locals stores, for every variable in the local scope, the number of
+	// hops from the current environment to the environment where the
+	// variable is defined and its slot in that environment.
+	locals		 map[Expr]resolvedLocal
+
+	// debugger, if set, is called before each statement is executed.
+	debugger	 *Debugger
+
+	// limiter counts the statements and expressions against the limits of
+	// the VM, and callDepth is the number of function calls in progress.
+	limiter		 *limiter
+	callDepth	 int
+
+	// lastToken starts the last statement that has a token. A limit hit
+	// at a block or a literal, which have none, is reported there.
+	lastToken	 *Token
+
+	// random is the generator behind math.random.
+	random		 *rand.Rand
+}
+
+// resolvedLocal locates a local variable: the environment is depth hops up
+// the parent chain and the variable is in its slot.
+type resolvedLocal struct {
+	depth int
+	slot  int
 }
 
-func NewInterpreter(errorPrinter *ErrorPrinter) *Interpreter {
-	env := NewEnvironment(nil)
-	env.Define("clock", &Clock{})
-	return &Interpreter{
+// NewInterpreter returns an Interpreter that writes the program output to
+// stdout and reports runtime errors through errorPrinter.
+func NewInterpreter(errorPrinter *ErrorPrinter, stdout io.Writer) *Interpreter {
+	builtins := NewGlobalEnvironment(nil)
+	env := NewGlobalEnvironment(builtins)
+	i := &Interpreter{
 		errorPrinter: errorPrinter,
+		stdout: stdout,
 		globals: env,
+		builtins: builtins,
 		environment: env,
-		locals: make(map[Expr]int),
+		locals: make(map[Expr]resolvedLocal),
+		limiter: newLimiter(&Options{}),
+		random: newRandomSource(),
+	}
+
+	i.DefineNative("clock", 0, clock)
+	i.builtins.Define("math", newMathModule(i.random))
+	return i
+}
+
+// Seed seeds the generator behind math.random, so that the numbers it
+// returns are the same from one run to the next.
+func (i *Interpreter) Seed(seed int64) {
+	i.random.Seed(seed)
+}
+
+// DefineNative defines a global function name, taking arity arguments, that
+// is implemented by the Go function fn.
+func (i *Interpreter) DefineNative(name string, arity int, fn NativeFunc) {
+	i.builtins.Define(name, NewNativeFunction(name, arity, fn))
+}
+
+// DefineFunc defines a global function name that calls the Go function fn.
+// The arguments and results are converted as described in WrapFunc.
+func (i *Interpreter) DefineFunc(name string, fn interface{}) error {
+	native, err := WrapFunc(name, fn)
+	if err != nil {
+		return err
 	}
+
+	i.builtins.Define(name, native)
+	return nil
 }
 
 func (i *Interpreter) Interpret(statements []Stmt) {
 	for _, statement := range statements {
 		if err := i.execute(statement); err != nil {
+			if runtimeErr, isRuntimeError := err.(*RuntimeError); isRuntimeError {
+				runtimeErr.exitFrame("<script>")
+			}
+
 			i.errorPrinter.RuntimeError(err)
 		}
 	}
 }
 
+// interpret executes the statements and stops at the first runtime error,
+// which is returned to the caller instead of being reported. If the last
+// statement is an expression statement, its value is returned.
+func (i *Interpreter) interpret(statements []Stmt) (interface{}, error) {
+	var val interface{}
+	var err error
+
+	for _, statement := range statements {
+		if stmt, isExpression := statement.(*Expression); isExpression {
+			// evaluated here to keep its value, but it is still a step for
+			// the debugger and the limits.
+			if err = i.beforeExecute(stmt); err == nil {
+				val, err = i.evaluate(stmt.Expression)
+			}
+		} else {
+			val, err = nil, i.execute(statement)
+		}
+
+		if err != nil {
+			if runtimeErr, isRuntimeError := err.(*RuntimeError); isRuntimeError {
+				runtimeErr.exitFrame("<script>")
+			}
+
+			return nil, err
+		}
+	}
+
+	return val, nil
+}
+
 // InterpretREPL will just be used in REPL.
 // It will try to evaluate expression and display the value.
 func (i *Interpreter) InterpretREPL(expression Expr) string {
@@ -73,15 +175,16 @@ func (i *Interpreter) VisitClassStmt(stmt *Class) error {
 		}
 	}
 
-	i.environment.Define(stmt.Name.Lexeme, nil)
-
 	if stmt.Superclass != nil {
 		i.environment = NewEnvironment(i.environment)
 		i.environment.Define("super", superclass)
 	}
 
 	methods := map[string]*LoxFunction{}
-	for _, method := range stmt.Methods {
+	for idx := range stmt.Methods {
+		// take the address of the element rather than of the loop variable,
+		// otherwise every method would share the last declaration.
+		method := &stmt.Methods[idx]
 		isInitializer := method.Name.Lexeme == "init"
 		function := &LoxFunction{method.Name.Lexeme, &method.Function, i.environment, isInitializer}
 		methods[method.Name.Lexeme] = function
@@ -93,13 +196,9 @@ func (i *Interpreter) VisitClassStmt(stmt *Class) error {
 		i.environment = i.environment.enclosing
 	}
 
-	// That two-stage variable binding process allows references to the
-	// class inside its own methods.
-	err = i.environment.Assign(stmt.Name, class)
-	if err != nil {
-		return err
-	}
-
+	// The class is defined after its methods are created. The methods can
+	// still refer to the class, since they only look it up when they run.
+	i.environment.Define(stmt.Name.Lexeme, class)
 	return nil
 }
 
@@ -128,6 +227,71 @@ func (i *Interpreter) VisitFunctionStmt(stmt *Function) error {
 	return nil
 }
 
+func (i *Interpreter) VisitThrowStmt(stmt *Throw) error {
+	value, err := i.evaluate(stmt.Value)
+	if err != nil {
+		return err
+	}
+
+	return NewThrowError(stmt.Keyword, value)
+}
+
+// VisitTryStmt runs the try block and hands a RuntimeError raised in it to
+// the catch clause. The control flow errors of break and return are not
+// caught, but the finally clause runs for them too. An error raised by the
+// finally clause replaces the pending one.
+func (i *Interpreter) VisitTryStmt(stmt *Try) error {
+	err := i.executeBlock(stmt.Body, NewEnvironment(i.environment))
+
+	if runtimeErr, isRuntimeError := err.(*RuntimeError); isRuntimeError && stmt.CatchName != nil {
+		environment := NewEnvironment(i.environment)
+		environment.Define(stmt.CatchName.Lexeme, runtimeErr.errorValue())
+		err = i.executeBlock(stmt.CatchBody, environment)
+	}
+
+	// fatal errors stop the program without running the finally clause.
+	if stmt.FinallyBody != nil && !isFatal(err) {
+		if finallyErr := i.executeBlock(stmt.FinallyBody, NewEnvironment(i.environment)); finallyErr != nil {
+			return finallyErr
+		}
+	}
+
+	return err
+}
+
+// VisitImportStmt binds the module, or the names imported from it, like
+// variable declarations.
+func (i *Interpreter) VisitImportStmt(stmt *Import) error {
+	if i.importer == nil {
+		return NewRuntimeError(stmt.Keyword, "Can't import modules here.")
+	}
+
+	module, err := i.importer(importPath(stmt), stmt.Keyword)
+	if err != nil {
+		return err
+	}
+
+	if stmt.Alias != nil {
+		i.environment.Define(stmt.Alias.Lexeme, module)
+		return nil
+	}
+
+	for _, name := range stmt.Names {
+		val, err := module.Get(name)
+		if err != nil {
+			return err
+		}
+
+		i.environment.Define(name.Lexeme, val)
+	}
+
+	return nil
+}
+
+func (i *Interpreter) VisitExportStmt(stmt *Export) error {
+	return i.execute(stmt.Declaration)
+}
+
 func (i *Interpreter) VisitBreakStmt(stmt *Break) error {
 	return NewBreakError()
 }
@@ -198,7 +362,7 @@ func (i *Interpreter) VisitPrintStmt(stmt *Print) error {
 		return err
 	}
 
-	fmt.Println(stringify(val))
+	fmt.Fprintln(i.stdout, stringify(val))
 	return nil
 }
 
@@ -208,9 +372,32 @@ func (i *Interpreter) VisitExpressionStmt(stmt *Expression) error {
 }
 
 func (i *Interpreter) execute(stmt Stmt) error {
+	if err := i.beforeExecute(stmt); err != nil {
+		return err
+	}
+
 	return stmt.Accept(i)
 }
 
+// beforeExecute calls the debugger and counts a step before stmt runs.
+func (i *Interpreter) beforeExecute(stmt Stmt) error {
+	if i.debugger != nil {
+		if err := i.debugger.before(stmt, i.environment); err != nil {
+			return err
+		}
+	}
+
+	if token := stmtToken(stmt); token != nil {
+		i.lastToken = token
+	}
+
+	if i.limiter.step() {
+		return i.limiter.err(i.lastToken)
+	}
+
+	return nil
+}
+
 func (i *Interpreter) executeBlock(statements []Stmt, environment *Environment) error {
 	previous := i.environment
 	defer func() {
@@ -232,10 +419,12 @@ func (i *Interpreter) executeBlock(statements []Stmt, environment *Environment)
 /* Implement ExprVisitor interface */
 
 func (i *Interpreter) VisitSuperExpr(expr *Super) (interface{}, error) {
-	distance := i.locals[expr]
-	superclass := i.environment.GetAt(distance, "super").(*LoxClass)
+	// "super" and "this" are the only variables of their environments, so
+	// both are in slot 0.
+	distance := i.locals[expr].depth
+	superclass := i.environment.GetAt(distance, 0).(*LoxClass)
 
-	object := i.environment.GetAt(distance-1, "this").(*LoxInstance)
+	object := i.environment.GetAt(distance-1, 0).(*LoxInstance)
 
 	method := superclass.findMethod(expr.Method.Lexeme)
 
@@ -276,14 +465,122 @@ func (i *Interpreter) VisitGetExpr(expr *Get) (interface{}, error) {
 		return nil, err
 	}
 
-	instance, isLoxInstance := object.(*LoxInstance)
-	if isLoxInstance {
-		return instance.Get(expr.Name)
+	switch object := object.(type) {
+	case *LoxInstance:
+		return object.Get(expr.Name)
+	case *LoxList:
+		return object.Get(expr.Name)
+	case *LoxMap:
+		return object.Get(expr.Name)
+	case *LoxError:
+		return object.Get(expr.Name)
+	case *LoxModule:
+		return object.Get(expr.Name)
+	case string:
+		return stringGet(object, expr.Name)
 	}
 
 	return nil, NewRuntimeError(expr.Name, "Only instances have properties.")
 }
 
+func (i *Interpreter) VisitListLiteralExpr(expr *ListLiteral) (interface{}, error) {
+	elements := make([]interface{}, 0, len(expr.Elements))
+	for _, element := range expr.Elements {
+		val, err := i.evaluate(element)
+		if err != nil {
+			return nil, err
+		}
+
+		elements = append(elements, val)
+	}
+
+	return NewLoxList(elements), nil
+}
+
+func (i *Interpreter) VisitMapLiteralExpr(expr *MapLiteral) (interface{}, error) {
+	m := NewLoxMap()
+	for idx, keyExpr := range expr.Keys {
+		key, err := i.evaluate(keyExpr)
+		if err != nil {
+			return nil, err
+		}
+
+		val, err := i.evaluate(expr.Values[idx])
+		if err != nil {
+			return nil, err
+		}
+
+		if !m.Set(key, val) {
+			return nil, NewRuntimeError(expr.Brace, invalidKey(key))
+		}
+	}
+
+	return m, nil
+}
+
+func (i *Interpreter) VisitInterpolatedExpr(expr *Interpolated) (interface{}, error) {
+	val, err := i.evaluate(expr.Expression)
+	if err != nil {
+		return nil, err
+	}
+
+	return stringify(val), nil
+}
+
+func (i *Interpreter) VisitIndexExpr(expr *Index) (interface{}, error) {
+	object, err := i.evaluate(expr.Object)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err := i.evaluate(expr.Index)
+	if err != nil {
+		return nil, err
+	}
+
+	if indexable, isIndexable := object.(LoxIndexable); isIndexable {
+		return indexable.GetIndex(expr.Bracket, index)
+	}
+
+	if s, isString := object.(string); isString {
+		return stringGetIndex(expr.Bracket, s, index)
+	}
+
+	return nil, NewRuntimeError(expr.Bracket, "Only lists, maps and strings can be indexed.")
+}
+
+func (i *Interpreter) VisitSetIndexExpr(expr *SetIndex) (interface{}, error) {
+	object, err := i.evaluate(expr.Object)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err := i.evaluate(expr.Index)
+	if err != nil {
+		return nil, err
+	}
+
+	val, err := i.evaluate(expr.Value)
+	if err != nil {
+		return nil, err
+	}
+
+	if isString(object) {
+		return nil, NewRuntimeError(expr.Bracket, "Strings can't be modified.")
+	}
+
+	indexable, isIndexable := object.(LoxIndexable)
+	if !isIndexable {
+		return nil, NewRuntimeError(expr.Bracket, "Only lists and maps can be indexed.")
+	}
+
+	if err := indexable.SetIndex(expr.Bracket, index, val); err != nil {
+		return nil, err
+	}
+
+	return val, nil
+}
+
 func (i *Interpreter) VisitFunctionExprExpr(expr *FunctionExpr) (interface{}, error) {
 	return &LoxFunction{Name: "", Declaration: expr, Closure: i.environment}, nil
 }
@@ -304,19 +601,40 @@ func (i *Interpreter) VisitCallExpr(expr *Call) (interface{}, error) {
 		arguments = append(arguments, argument)
 	}
 
+	// the call is at the line where it starts, even if its arguments span
+	// more lines.
+	callSite := exprToken(expr)
+
 	// check the type to make sure that the callee can be called indeed.
 	if _, isLoxCallable := callee.(LoxCallable); !isLoxCallable {
-		return nil, NewRuntimeError(expr.Paren, "Can only call functions and classes.")
+		return nil, NewRuntimeError(callSite, "Can only call functions and classes.")
 	}
 	function := callee.(LoxCallable)
 
 	// check the number of arguments.
 	if uint32(len(arguments)) != function.Arity() {
-		return nil, NewRuntimeError(expr.Paren, fmt.Sprintf("Expected %d arguments but got %d.", function.Arity(), len(arguments)))
+		return nil, NewRuntimeError(callSite, fmt.Sprintf("Expected %d arguments but got %d.", function.Arity(), len(arguments)))
 	}
 
 	ret, err := function.Call(i, arguments)
 	if err != nil {
+		// the error continues unwinding in the frame of this call.
+		if runtimeErr, isRuntimeError := err.(*RuntimeError); isRuntimeError {
+			runtimeErr.setCallSite(callSite)
+			return nil, err
+		}
+
+		// natives know nothing about tokens, so their errors are reported at
+		// the call site.
+		if _, isNative := function.(*NativeFunction); isNative && !isFatal(err) {
+			return nil, NewRuntimeError(callSite, err.Error())
+		}
+
+		// the depth limit is hit at the call that goes too deep.
+		if limitErr, isLimitError := err.(*LimitError); isLimitError && limitErr.Token == nil {
+			limitErr.Token = callSite
+		}
+
 		return nil, err
 	}
 
@@ -352,11 +670,11 @@ func (i *Interpreter) VisitAssignExpr(expr *Assign) (interface{}, error) {
 
 	// We look up the variable’s scope distance. If not found, we assume
 	// it’s global.
-	distance, ok := i.locals[expr]
+	local, ok := i.locals[expr]
 	if ok {
-		i.environment.AssignAt(distance, expr.Name, val)
+		i.environment.AssignAt(local.depth, local.slot, val)
 	} else {
-		err := i.globals.Assign(expr.Name, val)
+		err := i.environment.Assign(expr.Name, val)
 		if err != nil {
 			return nil, err
 		}
@@ -394,38 +712,42 @@ func (i *Interpreter) VisitBinaryExpr(expr *Binary) (interface{}, error) {
 			return nil, err
 		}
 
-		return left.(float64) > right.(float64), nil
+		cmp, ok := compareNumbers(left, right)
+		return ok && cmp > 0, nil
 	case GREATER_EQUAL:	// >=
 		if err := i.checkNumberOperands(expr.Operator, left, right); err != nil {
 			return nil, err
 		}
 
-		return left.(float64) >= right.(float64), nil
+		cmp, ok := compareNumbers(left, right)
+		return ok && cmp >= 0, nil
 	case LESS:			// <
 		if err := i.checkNumberOperands(expr.Operator, left, right); err != nil {
 			return nil, err
 		}
 
-		return left.(float64) < right.(float64), nil
+		cmp, ok := compareNumbers(left, right)
+		return ok && cmp < 0, nil
 	case LESS_EQUAL:	// <=
 		if err := i.checkNumberOperands(expr.Operator, left, right); err != nil {
 			return nil, err
 		}
 
-		return left.(float64) <= right.(float64), nil
+		cmp, ok := compareNumbers(left, right)
+		return ok && cmp <= 0, nil
 	case BANG_EQUAL:	// !=
-		return !(left == right), nil
+		return !valuesEqual(left, right), nil
 	case EQUAL_EQUAL:	// ==
-		return left == right, nil
+		return valuesEqual(left, right), nil
 	case MINUS:			// -
 		if err := i.checkNumberOperands(expr.Operator, left, right); err != nil {
 			return nil, err
 		}
 
-		return left.(float64) - right.(float64), nil
+		return subtractNumbers(left, right), nil
 	case PLUS:			// +
-		if isFloat64(left) && isFloat64(right) {
-			return left.(float64) + right.(float64), nil
+		if isNumber(left) && isNumber(right) {
+			return addNumbers(left, right), nil
 		}
 
 		if isString(left) && isString(right) {
@@ -433,12 +755,12 @@ func (i *Interpreter) VisitBinaryExpr(expr *Binary) (interface{}, error) {
 		}
 
 		// concatenate them when one operand is string and the other is number.
-		if isString(left) && isFloat64(right) {
-			return left.(string) + strconv.FormatFloat(right.(float64), 'f', -1, 64), nil
+		if isString(left) && isNumber(right) {
+			return left.(string) + formatNumber(right), nil
 		}
 
-		if isFloat64(left) && isString(right) {
-			return strconv.FormatFloat(left.(float64), 'f', -1, 64) + right.(string), nil
+		if isNumber(left) && isString(right) {
+			return formatNumber(left) + right.(string), nil
 		}
 
 		return nil, NewRuntimeError(expr.Operator, "both operands must be numbers or strings.")
@@ -448,17 +770,30 @@ func (i *Interpreter) VisitBinaryExpr(expr *Binary) (interface{}, error) {
 		}
 
 		// divisor can not be 0
-		if right.(float64) == 0 {
+		if toFloat(right) == 0 {
 			return nil, NewRuntimeError(expr.Operator, "divisor can not be 0.")
 		}
 
-		return left.(float64) / right.(float64), nil
+		return toFloat(left) / toFloat(right), nil
+	case TILDE_SLASH:	// ~/
+		if err := i.checkNumberOperands(expr.Operator, left, right); err != nil {
+			return nil, err
+		}
+
+		if toFloat(right) == 0 {
+			return nil, NewRuntimeError(expr.Operator, "divisor can not be 0.")
+		}
+
+		return floorDivideNumbers(left, right), nil
 	case STAR:			// *
 		if err := i.checkNumberOperands(expr.Operator, left, right); err != nil {
 			return nil, err
 		}
 
-		return left.(float64) * right.(float64), nil
+		return multiplyNumbers(left, right), nil
+	case COMMA:			// ,
+		// both operands are evaluated and the right one is the result.
+		return right, nil
 	}
 
 	// unreachable.
@@ -502,7 +837,7 @@ func (i *Interpreter) VisitUnaryExpr(expr *Unary) (interface{}, error) {
 			return nil, err
 		}
 
-		return -right.(float64), nil
+		return negateNumber(right), nil
 	}
 
 	// unreachable.
@@ -510,11 +845,19 @@ func (i *Interpreter) VisitUnaryExpr(expr *Unary) (interface{}, error) {
 }
 
 func (i *Interpreter) evaluate(expr Expr) (interface{}, error) {
+	if i.limiter.step() {
+		token := exprToken(expr)
+		if token == nil {
+			token = i.lastToken
+		}
+		return nil, i.limiter.err(token)
+	}
+
 	return expr.Accept(i)
 }
 
 func (i *Interpreter) checkNumberOperand(operator *Token, operand interface{}) error {
-	if isFloat64(operand) {
+	if isNumber(operand) {
 		return nil
 	}
 
@@ -522,7 +865,7 @@ func (i *Interpreter) checkNumberOperand(operator *Token, operand interface{}) e
 }
 
 func (i *Interpreter) checkNumberOperands(operator *Token, operand1 interface{}, operand2 interface{}) error {
-	if isFloat64(operand1) && isFloat64(operand2) {
+	if isNumber(operand1) && isNumber(operand2) {
 		return nil
 	}
 
@@ -530,21 +873,23 @@ func (i *Interpreter) checkNumberOperands(operator *Token, operand1 interface{},
 }
 
 // resolve is called by Resolver to tell the Interpreter how many scopes there
-// are between the current scope and the scope where the variable is defined
-// each time it visits a variable.
-func (i *Interpreter) resolve(expr Expr, depth int) {
-	i.locals[expr] = depth
+// are between the current scope and the scope where the variable is defined,
+// and the slot of the variable in that scope, each time it visits a variable.
+func (i *Interpreter) resolve(expr Expr, depth int, slot int) {
+	i.locals[expr] = resolvedLocal{depth: depth, slot: slot}
 }
 
-// lookUpVariable firstly look up the resolved distance in the map. If the
-// distance can not be found in the map, the variable must be global. If we
-// do get a distance, then we call GetAt() to get the variable.
+// lookUpVariable firstly look up the resolved location in the map. If the
+// location can not be found in the map, the variable must be global, and it
+// is looked up by name in the global environment the current environment
+// descends from, which is the one of the module the code belongs to. If we
+// do get a location, then we call GetAt() to get the variable.
 func (i *Interpreter) lookUpVariable(name *Token, expr Expr) (interface{}, error) {
-	distance, ok := i.locals[expr]
+	local, ok := i.locals[expr]
 	if ok {
-		return i.environment.GetAt(distance, name.Lexeme), nil
+		return i.environment.GetAt(local.depth, local.slot), nil
 	} else {
-		return i.globals.Get(name)
+		return i.environment.Get(name)
 	}
 }
 
@@ -568,8 +913,8 @@ func stringify(v interface{}) string {
 		return "nil"
 	}
 
-	if isFloat64(v) {
-		return strconv.Itoa(int(v.(float64)))
+	if isNumber(v) {
+		return formatNumber(v)
 	}
 
 	return fmt.Sprintf("%v", v)
@@ -592,12 +937,3 @@ func isString(v interface{}) bool {
 
 	return false
 }
-
-func isFloat64(v interface{}) bool {
-	switch v.(type) {
-	case float64:
-		return true
-	}
-
-	return false
-}
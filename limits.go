@@ -0,0 +1,166 @@
+package glox
+
+import (
+	"context"
+	"math"
+)
+
+// DefaultMaxCallDepth bounds the depth of calls when Options.MaxCallDepth
+// is not set, so that runaway recursion fails with a LimitError instead of
+// overflowing the Go stack.
+const DefaultMaxCallDepth = 1024
+
+// MaxCallDepthCeiling is the largest Options.MaxCallDepth a VM accepts;
+// larger values are lowered to it. Every call of the TreeWalk backend takes
+// a few kilobytes of Go stack, so deeper recursion would overflow the Go
+// stack, which can't be recovered from.
+const MaxCallDepthCeiling = 100000
+
+// contextCheckInterval is the number of steps between two checks of the
+// context, which are too slow to make at every step.
+const contextCheckInterval = 1024
+
+// Limit names one of the resource limits of Options.
+type Limit int
+
+const (
+	// StepLimit is Options.MaxSteps.
+	StepLimit Limit = iota
+
+	// CallDepthLimit is Options.MaxCallDepth.
+	CallDepthLimit
+
+	// ContextLimit is the cancellation or the deadline of Options.Context.
+	ContextLimit
+)
+
+func (l Limit) String() string {
+	switch l {
+	case StepLimit:
+		return "step limit"
+	case CallDepthLimit:
+		return "call depth limit"
+	default:
+		return "context"
+	}
+}
+
+// LimitError is returned when a program exceeds one of the limits set in
+// Options. Unlike a RuntimeError, it stops the whole program: try
+// statements do not catch it.
+type LimitError struct {
+	Limit Limit
+
+	// Token locates the code that was running when the limit was hit. It
+	// may be nil.
+	Token *Token
+
+	// Err is the error of the context, for ContextLimit.
+	Err error
+}
+
+func (le *LimitError) Error() string {
+	switch le.Limit {
+	case StepLimit:
+		return "Step limit exceeded."
+	case CallDepthLimit:
+		return "Stack overflow."
+	default:
+		return "Execution stopped: " + le.Err.Error() + "."
+	}
+}
+
+// Unwrap returns the error of the context, so that errors.Is can tell a
+// deadline from a cancellation.
+func (le *LimitError) Unwrap() error {
+	return le.Err
+}
+
+// limiter enforces the step budget and the context of Options. The
+// Interpreter and the Machine call step before each unit of work.
+type limiter struct {
+	maxSteps     int64
+	maxCallDepth int
+	ctx          context.Context
+
+	steps int64
+
+	// next is the step at which step has to check the limits again.
+	next int64
+
+	// exceeded is the limit that stopped the program.
+	exceeded Limit
+}
+
+func newLimiter(opts *Options) *limiter {
+	l := &limiter{
+		maxSteps:     opts.MaxSteps,
+		maxCallDepth: opts.MaxCallDepth,
+		ctx:          opts.Context,
+	}
+	if l.maxCallDepth <= 0 {
+		l.maxCallDepth = DefaultMaxCallDepth
+	}
+	if l.maxCallDepth > MaxCallDepthCeiling {
+		l.maxCallDepth = MaxCallDepthCeiling
+	}
+
+	l.reset()
+	return l
+}
+
+// reset starts counting the steps of a new run.
+func (l *limiter) reset() {
+	l.steps = 0
+	l.next = math.MaxInt64
+	if l.maxSteps > 0 {
+		l.next = l.maxSteps + 1
+	}
+
+	// check a context that is already done before running anything.
+	if l.ctx != nil {
+		l.next = 1
+	}
+}
+
+// step counts a step and reports whether a limit has been exceeded.
+func (l *limiter) step() bool {
+	l.steps++
+	return l.steps >= l.next && l.check()
+}
+
+func (l *limiter) check() bool {
+	if l.maxSteps > 0 && l.steps > l.maxSteps {
+		l.exceeded = StepLimit
+		return true
+	}
+
+	if l.ctx != nil {
+		select {
+		case <-l.ctx.Done():
+			l.exceeded = ContextLimit
+			return true
+		default:
+		}
+	}
+
+	l.next = math.MaxInt64
+	if l.ctx != nil {
+		l.next = l.steps + contextCheckInterval
+	}
+	if l.maxSteps > 0 && l.maxSteps+1 < l.next {
+		l.next = l.maxSteps + 1
+	}
+
+	return false
+}
+
+// err returns the error for the limit step reported, at token.
+func (l *limiter) err(token *Token) error {
+	limitErr := &LimitError{Limit: l.exceeded, Token: token}
+	if l.exceeded == ContextLimit {
+		limitErr.Err = l.ctx.Err()
+	}
+
+	return limitErr
+}
@@ -0,0 +1,73 @@
+package glox
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestStepLimitLine(t *testing.T) {
+	source := "var i = 0;\nwhile (true) {\n  {}\n  i = i + 1;\n}\n"
+
+	for _, backend := range backends {
+		// whatever the statement the limit stops at, blocks included, the
+		// error has a line inside the loop.
+		for steps := int64(2); steps < 20; steps++ {
+			vm := NewVM(&Options{Backend: backend.backend, MaxSteps: steps, Stderr: io.Discard})
+
+			var limitErr *LimitError
+			_, err := vm.Eval(source)
+			if !errors.As(err, &limitErr) || limitErr.Limit != StepLimit {
+				t.Fatalf("%s, %d steps: got %v, want a step limit error", backend.name, steps, err)
+			}
+			if limitErr.Token == nil || limitErr.Token.Line < 2 || limitErr.Token.Line > 4 {
+				t.Errorf("%s, %d steps: got token %v, want one on lines 2 to 4", backend.name, steps, limitErr.Token)
+			}
+		}
+	}
+}
+
+func TestLimitErrors(t *testing.T) {
+	expired, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	<-expired.Done()
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name   string
+		opts   Options
+		source string
+		limit  Limit
+		cause  error
+	}{
+		{"steps", Options{MaxSteps: 1000}, "while (true) {}", StepLimit, nil},
+		{"call depth", Options{MaxCallDepth: 50}, "fun f() { f(); }\nf();", CallDepthLimit, nil},
+		{"deadline", Options{Context: expired}, "while (true) {}", ContextLimit, context.DeadlineExceeded},
+		{"canceled", Options{Context: canceled}, "while (true) {}", ContextLimit, context.Canceled},
+	}
+
+	for _, backend := range backends {
+		for _, test := range tests {
+			opts := test.opts
+			opts.Backend, opts.Stderr = backend.backend, io.Discard
+
+			var limitErr *LimitError
+			_, err := NewVM(&opts).Eval(test.source)
+			if !errors.As(err, &limitErr) || limitErr.Limit != test.limit {
+				t.Errorf("%s: %s: got %v, want a %v error", backend.name, test.name, err, test.limit)
+				continue
+			}
+
+			if test.cause != nil && !errors.Is(err, test.cause) {
+				t.Errorf("%s: %s: got %v, want it to wrap %v", backend.name, test.name, err, test.cause)
+			}
+			if test.cause == nil && errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("%s: %s: %v wraps a deadline", backend.name, test.name, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,333 @@
+package glox
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// errInterrupted is returned by readLine when the user presses Ctrl-C.
+var errInterrupted = errors.New("interrupted")
+
+// lineReader reads the input of the REPL one line at a time.
+type lineReader interface {
+	// readLine shows prompt and returns the next line, without its end of
+	// line. It returns io.EOF at the end of the input.
+	readLine(prompt string) (string, error)
+}
+
+// plainReader reads lines from an input that is not a terminal.
+type plainReader struct {
+	in  *bufio.Scanner
+	out io.Writer
+}
+
+func (pr *plainReader) readLine(prompt string) (string, error) {
+	fmt.Fprint(pr.out, prompt)
+	if !pr.in.Scan() {
+		if err := pr.in.Err(); err != nil {
+			return "", err
+		}
+		return "", io.EOF
+	}
+
+	return pr.in.Text(), nil
+}
+
+// maxHistory is the number of lines the history keeps.
+const maxHistory = 1000
+
+// lineEditor reads lines from a terminal in raw mode. It supports the usual
+// Emacs-style editing keys, a history browsed with the up and down arrows
+// and tab completion.
+type lineEditor struct {
+	fd  uintptr
+	in  *bufio.Reader
+	out io.Writer
+
+	// complete returns the words that can replace the one ending at pos in
+	// line, and the position where that word starts.
+	complete func(line []rune, pos int) (start int, candidates []string)
+
+	// history holds the lines entered so far, the latest last. historyFile,
+	// if set, receives every new line.
+	history     []string
+	historyFile string
+
+	// line is the line being edited, pos the position of the cursor in it
+	// and prompt the prompt shown before it.
+	line   []rune
+	pos    int
+	prompt string
+}
+
+// newLineEditor returns a lineEditor that reads from the terminal in and
+// keeps its history in historyFile, if it is not empty.
+func newLineEditor(in *os.File, out io.Writer, historyFile string) *lineEditor {
+	le := &lineEditor{
+		fd:          in.Fd(),
+		in:          bufio.NewReader(in),
+		out:         out,
+		historyFile: historyFile,
+	}
+
+	if historyFile != "" {
+		if data, err := os.ReadFile(historyFile); err == nil {
+			for _, line := range strings.Split(string(data), "\n") {
+				if line != "" {
+					le.history = append(le.history, line)
+				}
+			}
+		}
+		// the file only grows, so cut it back to the lines that are kept.
+		if len(le.history) > maxHistory {
+			le.history = le.history[len(le.history)-maxHistory:]
+			os.WriteFile(historyFile, []byte(strings.Join(le.history, "\n")+"\n"), 0600)
+		}
+	}
+
+	return le
+}
+
+func (le *lineEditor) readLine(prompt string) (string, error) {
+	restore, err := makeRaw(le.fd)
+	if err != nil {
+		return "", err
+	}
+	defer restore()
+
+	le.line, le.pos, le.prompt = nil, 0, prompt
+	le.refresh()
+
+	// browsing is the index of the history entry shown, and saved the line
+	// that was being edited before browsing started.
+	browsing := len(le.history)
+	var saved []rune
+
+	for {
+		r, _, err := le.in.ReadRune()
+		if err != nil {
+			return "", err
+		}
+
+		switch r {
+		case '\r', '\n':
+			fmt.Fprint(le.out, "\n")
+			line := string(le.line)
+			le.addHistory(line)
+			return line, nil
+		case 3: // Ctrl-C
+			fmt.Fprint(le.out, "^C\n")
+			return "", errInterrupted
+		case 4: // Ctrl-D
+			if len(le.line) == 0 {
+				fmt.Fprint(le.out, "\n")
+				return "", io.EOF
+			}
+			le.delete(le.pos, le.pos+1)
+		case 127, 8: // Backspace, Ctrl-H
+			le.delete(le.pos-1, le.pos)
+		case 1: // Ctrl-A
+			le.moveTo(0)
+		case 5: // Ctrl-E
+			le.moveTo(len(le.line))
+		case 2: // Ctrl-B
+			le.moveTo(le.pos - 1)
+		case 6: // Ctrl-F
+			le.moveTo(le.pos + 1)
+		case 11: // Ctrl-K
+			le.delete(le.pos, len(le.line))
+		case 21: // Ctrl-U
+			le.delete(0, le.pos)
+		case 23: // Ctrl-W
+			le.delete(le.wordStart(), le.pos)
+		case 12: // Ctrl-L
+			fmt.Fprint(le.out, "\x1b[H\x1b[2J")
+			le.refresh()
+		case 9: // Tab
+			le.completeWord()
+		case 16, 14: // Ctrl-P, Ctrl-N
+			browsing, saved = le.browse(r == 16, browsing, saved)
+		case 27: // Escape sequences of the arrows and the other keys.
+			switch le.escape() {
+			case "[A", "OA":
+				browsing, saved = le.browse(true, browsing, saved)
+			case "[B", "OB":
+				browsing, saved = le.browse(false, browsing, saved)
+			case "[C", "OC":
+				le.moveTo(le.pos + 1)
+			case "[D", "OD":
+				le.moveTo(le.pos - 1)
+			case "[H", "OH", "[1~", "[7~":
+				le.moveTo(0)
+			case "[F", "OF", "[4~", "[8~":
+				le.moveTo(len(le.line))
+			case "[3~":
+				le.delete(le.pos, le.pos+1)
+			}
+		default:
+			if unicode.IsPrint(r) {
+				le.insert([]rune{r})
+			}
+		}
+	}
+}
+
+// escape reads the rest of an escape sequence after the escape character:
+// a '[' or 'O' and then parameters up to a final letter or '~'.
+func (le *lineEditor) escape() string {
+	var seq strings.Builder
+	for {
+		b, err := le.in.ReadByte()
+		if err != nil {
+			return seq.String()
+		}
+		seq.WriteByte(b)
+
+		if seq.Len() > 1 && (b == '~' || (b >= 'A' && b <= 'Z') || (b >= 'a' && b <= 'z')) {
+			return seq.String()
+		}
+		if seq.Len() == 1 && b != '[' && b != 'O' {
+			return seq.String()
+		}
+	}
+}
+
+// refresh redraws the prompt and the line and puts the cursor in place.
+func (le *lineEditor) refresh() {
+	fmt.Fprintf(le.out, "\r%s%s\x1b[K", le.prompt, string(le.line))
+	if back := len(le.line) - le.pos; back > 0 {
+		fmt.Fprintf(le.out, "\x1b[%dD", back)
+	}
+}
+
+func (le *lineEditor) moveTo(pos int) {
+	if pos >= 0 && pos <= len(le.line) {
+		le.pos = pos
+		le.refresh()
+	}
+}
+
+func (le *lineEditor) insert(text []rune) {
+	line := make([]rune, 0, len(le.line)+len(text))
+	line = append(line, le.line[:le.pos]...)
+	line = append(line, text...)
+	le.line = append(line, le.line[le.pos:]...)
+	le.pos += len(text)
+	le.refresh()
+}
+
+// delete removes the runes from start up to end, if they are in the line.
+func (le *lineEditor) delete(start int, end int) {
+	if start < 0 || end > len(le.line) || start >= end {
+		return
+	}
+
+	le.line = append(le.line[:start], le.line[end:]...)
+	le.pos = start
+	le.refresh()
+}
+
+// wordStart returns the start of the word before the cursor, skipping the
+// spaces before it.
+func (le *lineEditor) wordStart() int {
+	start := le.pos
+	for start > 0 && unicode.IsSpace(le.line[start-1]) {
+		start--
+	}
+	for start > 0 && !unicode.IsSpace(le.line[start-1]) {
+		start--
+	}
+
+	return start
+}
+
+// browse shows the previous or the next line of the history. browsing is
+// the index of the entry shown, len(history) for the line being edited,
+// which is kept in saved.
+func (le *lineEditor) browse(previous bool, browsing int, saved []rune) (int, []rune) {
+	if browsing == len(le.history) {
+		saved = append([]rune(nil), le.line...)
+	}
+
+	switch {
+	case previous && browsing > 0:
+		browsing--
+	case !previous && browsing < len(le.history):
+		browsing++
+	default:
+		return browsing, saved
+	}
+
+	if browsing == len(le.history) {
+		le.line = saved
+	} else {
+		le.line = []rune(le.history[browsing])
+	}
+	le.pos = len(le.line)
+	le.refresh()
+
+	return browsing, saved
+}
+
+// completeWord completes the word before the cursor. A single candidate
+// replaces the word; several ones are extended to their common prefix, or
+// listed if that does not add anything.
+func (le *lineEditor) completeWord() {
+	if le.complete == nil {
+		return
+	}
+
+	start, candidates := le.complete(le.line, le.pos)
+	if len(candidates) == 0 {
+		return
+	}
+
+	word := string(le.line[start:le.pos])
+	prefix := candidates[0]
+	for _, candidate := range candidates[1:] {
+		for !strings.HasPrefix(candidate, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+
+	if len(prefix) > len(word) {
+		le.delete(start, le.pos)
+		le.insert([]rune(prefix))
+		return
+	}
+
+	if len(candidates) > 1 {
+		fmt.Fprintf(le.out, "\n%s\n", strings.Join(candidates, "  "))
+		le.refresh()
+	}
+}
+
+// addHistory records a line that is not empty and differs from the last
+// one.
+func (le *lineEditor) addHistory(line string) {
+	if strings.TrimSpace(line) == "" || (len(le.history) > 0 && le.history[len(le.history)-1] == line) {
+		return
+	}
+
+	le.history = append(le.history, line)
+	if len(le.history) > maxHistory {
+		le.history = le.history[1:]
+	}
+
+	if le.historyFile == "" {
+		return
+	}
+
+	file, err := os.OpenFile(le.historyFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	fmt.Fprintln(file, line)
+}
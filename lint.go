@@ -0,0 +1,214 @@
+package glox
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// WarningCode identifies a kind of warning, so that it can be turned off.
+type WarningCode string
+
+const (
+	// UnusedVariable is a local variable, function or class that is never
+	// read.
+	UnusedVariable WarningCode = "unused-variable"
+
+	// UnusedParameter is a parameter the function never reads.
+	UnusedParameter WarningCode = "unused-parameter"
+
+	// UnreachableCode is a statement after a return, break or throw in the
+	// same block.
+	UnreachableCode WarningCode = "unreachable-code"
+
+	// ShadowedVariable is a declaration that hides a variable of an
+	// enclosing scope.
+	ShadowedVariable WarningCode = "shadowed-variable"
+
+	// UndeclaredGlobal is an assignment to a global that is never declared.
+	UndeclaredGlobal WarningCode = "undeclared-global"
+)
+
+// WarningCodes returns the codes of all the warnings.
+func WarningCodes() []WarningCode {
+	return []WarningCode{UnusedVariable, UnusedParameter, UnreachableCode, ShadowedVariable, UndeclaredGlobal}
+}
+
+// Warning describes code that is valid but probably wrong.
+type Warning struct {
+	Code WarningCode
+
+	// File is the module the warning is in, empty for the main script.
+	File string
+	Line uint32
+
+	// Column, Offset and Length locate the span of the warning in the
+	// source, as in Token.
+	Column uint32
+	Offset uint32
+	Length uint32
+
+	Message string
+}
+
+func (w *Warning) String() string {
+	return fmt.Sprintf("[%v] Warning (%v): %v", location(w.File, w.Line), w.Code, w.Message)
+}
+
+// Lint scans, parses and resolves source and returns the warnings about
+// it in source order, leaving out the ones whose code is disabled. It never runs the code.
+// Static errors are returned as a *CompileError. The Globals of opts, which
+// may be nil, count as declared, like the natives.
+func Lint(source string, opts *Options, disabled ...WarningCode) ([]*Warning, error) {
+	errorPrinter := NewErrorPrinter(io.Discard)
+	errorPrinter.SetSource(source)
+
+	tokens := NewScanner(source, errorPrinter).ScanTokens()
+	stmts := NewParser(tokens, errorPrinter).Parse()
+	if errorPrinter.hadError {
+		return nil, &CompileError{Errors: errorPrinter.Errors()}
+	}
+
+	resolver := NewResolver(nil, errorPrinter)
+	resolver.EnableWarnings(disabled...)
+	if opts != nil {
+		for name := range opts.Globals {
+			resolver.knownGlobals[name] = true
+		}
+	}
+	resolver.Resolve(stmts)
+	if errorPrinter.hadError {
+		return nil, &CompileError{Errors: errorPrinter.Errors()}
+	}
+
+	// the warnings about a scope come when it ends, after the ones about
+	// the code in it.
+	warnings := errorPrinter.Warnings()
+	sort.SliceStable(warnings, func(i, j int) bool {
+		return warnings[i].Offset < warnings[j].Offset
+	})
+
+	return warnings, nil
+}
+
+// EnableWarnings makes the Resolver report warnings through its
+// ErrorPrinter, except those whose code is disabled. Variables whose name
+// starts with an underscore are never reported as unused.
+func (r *Resolver) EnableWarnings(disabled ...WarningCode) {
+	r.warn = true
+	r.disabled = map[WarningCode]bool{}
+	for _, code := range disabled {
+		r.disabled[code] = true
+	}
+
+	if r.globalNames == nil {
+		r.globalNames = map[string]*Token{}
+	}
+
+	r.knownGlobals = map[string]bool{}
+	for _, name := range Builtins() {
+		r.knownGlobals[name] = true
+	}
+}
+
+func (r *Resolver) warning(token *Token, code WarningCode, message string) {
+	if !r.disabled[code] {
+		r.errorPrinter.Warning(*token, code, message)
+	}
+}
+
+// declareGlobal records a top-level declaration.
+func (r *Resolver) declareGlobal(name *Token) {
+	if _, ok := r.globalNames[name.Lexeme]; !ok {
+		r.globalNames[name.Lexeme] = name
+	}
+}
+
+// checkShadowing warns if the local name about to be declared hides a
+// variable of an enclosing scope or a global declared before it.
+func (r *Resolver) checkShadowing(name *Token) {
+	for i := r.scopes.Length() - 2; i >= 0; i-- {
+		if v, ok := r.scopes.Get(i)[name.Lexeme]; ok && v.name != nil {
+			r.warning(name, ShadowedVariable, fmt.Sprintf("'%s' shadows the %s declared on line %d.", name.Lexeme, v.kind, v.name.Line))
+			return
+		}
+	}
+
+	if global, ok := r.globalNames[name.Lexeme]; ok {
+		r.warning(name, ShadowedVariable, fmt.Sprintf("'%s' shadows the global declared on line %d.", name.Lexeme, global.Line))
+	}
+}
+
+// checkUnused warns about the variables of a scope that are never read, in
+// the order they are declared.
+func (r *Resolver) checkUnused(scope map[string]*variable) {
+	unused := []*variable{}
+	for _, v := range scope {
+		if v.name != nil && !v.read && !strings.HasPrefix(v.name.Lexeme, "_") {
+			unused = append(unused, v)
+		}
+	}
+	sort.Slice(unused, func(i, j int) bool {
+		return unused[i].slot < unused[j].slot
+	})
+
+	for _, v := range unused {
+		if v.kind == SymbolParameter {
+			r.warning(v.name, UnusedParameter, fmt.Sprintf("Parameter '%s' is never used.", v.name.Lexeme))
+		} else {
+			r.warning(v.name, UnusedVariable, fmt.Sprintf("Local %s '%s' is never used.", v.kind, v.name.Lexeme))
+		}
+	}
+}
+
+// checkUnreachable warns about the first statement that follows a return,
+// break or throw in the list.
+func (r *Resolver) checkUnreachable(statements []Stmt) {
+	for idx := 0; idx+1 < len(statements); idx++ {
+		switch statements[idx].(type) {
+		case *Return, *Break, *Throw:
+		default:
+			continue
+		}
+
+		if token := firstToken(statements[idx+1]); token != nil {
+			r.warning(token, UnreachableCode, "Unreachable code.")
+		}
+		return
+	}
+}
+
+// firstToken returns a token at the start of a statement, looking into
+// blocks, which have none of their own.
+func firstToken(statement Stmt) *Token {
+	if block, ok := statement.(*Block); ok {
+		for _, inner := range block.Statements {
+			if token := firstToken(inner); token != nil {
+				return token
+			}
+		}
+		return nil
+	}
+
+	return stmtToken(statement)
+}
+
+// checkGlobalAssignments warns about the assignments to globals that are
+// neither declared in the source nor defined when it runs.
+func (r *Resolver) checkGlobalAssignments() {
+	for _, name := range r.globalAssignments {
+		if _, ok := r.globalNames[name.Lexeme]; ok || r.knownGlobals[name.Lexeme] {
+			continue
+		}
+		if r.interpreter != nil {
+			if _, ok := r.interpreter.globals.Lookup(name.Lexeme); ok {
+				continue
+			}
+		}
+
+		r.warning(name, UndeclaredGlobal, fmt.Sprintf("Assignment to undeclared global '%s'.", name.Lexeme))
+	}
+
+	r.globalAssignments = nil
+}
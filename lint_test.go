@@ -0,0 +1,110 @@
+package glox
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"testing"
+)
+
+func TestLint(t *testing.T) {
+	tests := []struct {
+		name     string
+		source   string
+		disabled []WarningCode
+		want     []string
+	}{
+		{
+			name:   "unused variable",
+			source: "fun f() {\n  var used = 1;\n  var unused = 2;\n  var _ignored = 3;\n  return used;\n}\nf();\n",
+			want:   []string{"unused-variable:3"},
+		},
+		{
+			name:   "unused local function and class",
+			source: "{\n  fun helper() {}\n  class Box {}\n}\n",
+			want:   []string{"unused-variable:2", "unused-variable:3"},
+		},
+		{
+			name:   "unused parameter",
+			source: "fun f(a, b, _c) {\n  return a;\n}\nf(1, 2, 3);\n",
+			want:   []string{"unused-parameter:1"},
+		},
+		{
+			name:   "unreachable after return",
+			source: "fun f() {\n  return 1;\n  print 2;\n}\nf();\n",
+			want:   []string{"unreachable-code:3"},
+		},
+		{
+			name:   "unreachable after break",
+			source: "while (true) {\n  break;\n  print 1;\n}\n",
+			want:   []string{"unreachable-code:3"},
+		},
+		{
+			name:   "shadowed variable",
+			source: "fun f(x) {\n  {\n    var x = 2;\n    print x;\n  }\n  return x;\n}\nf(1);\n",
+			want:   []string{"shadowed-variable:3"},
+		},
+		{
+			name:   "globals are not unused",
+			source: "var unused = 1;\nfun never() {}\n",
+		},
+		{
+			name:     "disabled",
+			source:   "fun f(a) {\n  var b = 1;\n  return;\n  print 1;\n}\nf(1);\n",
+			disabled: []WarningCode{UnusedVariable, UnreachableCode},
+			want:     []string{"unused-parameter:1"},
+		},
+		{
+			name:     "all disabled",
+			source:   "fun f(a) {\n  var b = 1;\n}\nf(1);\nnope = 1;\n",
+			disabled: WarningCodes(),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			warnings, err := Lint(test.source, nil, test.disabled...)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got []string
+			for _, warning := range warnings {
+				got = append(got, fmt.Sprintf("%s:%d", warning.Code, warning.Line))
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestLintUndeclaredGlobal(t *testing.T) {
+	source := "clock = 2;\nmath = 1;\nconfig = 3;\nnope = 4;\n"
+
+	warnings, err := Lint(source, &Options{Globals: map[string]Value{"config": nil}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(warnings) != 1 || warnings[0].Code != UndeclaredGlobal || warnings[0].Line != 4 {
+		t.Errorf("got %v, want one undeclared-global warning on line 4", warnings)
+	}
+}
+
+func TestWarningsAreNotErrors(t *testing.T) {
+	errorPrinter := NewErrorPrinter(io.Discard)
+	tokens := NewScanner("fun f(a) {\n  var b = 1;\n}\n", errorPrinter).ScanTokens()
+	stmts := NewParser(tokens, errorPrinter).Parse()
+
+	resolver := NewResolver(nil, errorPrinter)
+	resolver.EnableWarnings()
+	resolver.Resolve(stmts)
+
+	if len(errorPrinter.Warnings()) != 2 {
+		t.Errorf("got warnings %v, want 2", errorPrinter.Warnings())
+	}
+	if errorPrinter.hadError || len(errorPrinter.Errors()) != 0 {
+		t.Errorf("warnings set hadError: %v", errorPrinter.Errors())
+	}
+}
@@ -25,7 +25,9 @@ func (lc *LoxClass) Call(interpreter *Interpreter, arguments []interface{}) (int
 
 	initializer := lc.findMethod("init")
 	if initializer != nil {
-		initializer.Bind(instance).Call(interpreter, arguments)
+		if _, err := initializer.Bind(instance).Call(interpreter, arguments); err != nil {
+			return nil, err
+		}
 	}
 
 	return instance, nil
@@ -0,0 +1,25 @@
+package glox
+
+// LoxError is the value a catch clause receives for the runtime errors
+// raised by the interpreter itself, such as a bad operand or an undefined
+// variable. Values thrown by throw statements are caught as they are.
+type LoxError struct {
+	Message string
+	Line    uint32
+}
+
+// Get returns the properties of the error: its message and its line.
+func (le *LoxError) Get(name *Token) (interface{}, error) {
+	switch name.Lexeme {
+	case "message":
+		return le.Message, nil
+	case "line":
+		return int64(le.Line), nil
+	}
+
+	return nil, NewRuntimeError(name, "Undefined property '" + name.Lexeme + "'.")
+}
+
+func (le *LoxError) String() string {
+	return le.Message
+}
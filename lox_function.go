@@ -20,28 +20,45 @@ func (lf *LoxFunction) Call(interpreter *Interpreter, arguments []interface{}) (
 	// created dynamically as the function call. If there are multiple calls
 	// to the same function in play at the same time, each needs its own
 	// environment, even though they are all calls to the same function.
+	if interpreter.callDepth == interpreter.limiter.maxCallDepth {
+		return nil, &LimitError{Limit: CallDepthLimit}
+	}
+	interpreter.callDepth++
+	defer func() {
+		interpreter.callDepth--
+	}()
+
 	environment := NewEnvironment(lf.Closure)
 
 	for i, param := range lf.Declaration.Paramters {
 		environment.Define(param.Lexeme, arguments[i])
 	}
 
+	if interpreter.debugger != nil {
+		interpreter.debugger.enterFrame(lf.Name, environment)
+		defer interpreter.debugger.exitFrame()
+	}
+
 	err := interpreter.executeBlock(lf.Declaration.Body, environment)
 	if err != nil {
 		// catch the returnError and return the value.
 		if returnValue, isReturnError := err.(*returnError); isReturnError {
 			if lf.isInitializer {
-				return lf.Closure.GetAt(0, "this"), nil
+				return lf.Closure.GetAt(0, 0), nil
 			}
 			
 			return returnValue.value, nil
 		}
 
+		if runtimeErr, isRuntimeError := err.(*RuntimeError); isRuntimeError {
+			runtimeErr.exitFrame(lf.Name)
+		}
+
 		return nil, err
 	}
 
 	if lf.isInitializer {
-		return lf.Closure.GetAt(0, "this"), nil
+		return lf.Closure.GetAt(0, 0), nil
 	}
 
 	return nil, nil
@@ -63,5 +80,5 @@ func (lf *LoxFunction) String() string {
 func (lf *LoxFunction) Bind(instance *LoxInstance) *LoxFunction {
 	env := NewEnvironment(lf.Closure)
 	env.Define("this", instance)
-	return &LoxFunction{Declaration: lf.Declaration, Closure: env, isInitializer: lf.isInitializer}
+	return &LoxFunction{Name: lf.Name, Declaration: lf.Declaration, Closure: env, isInitializer: lf.isInitializer}
 }
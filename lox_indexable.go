@@ -0,0 +1,12 @@
+package glox
+
+// LoxIndexable should be implemented by any Lox object that supports
+// the subscript operator, such as lists and maps.
+type LoxIndexable interface {
+	// GetIndex returns the element stored at index. bracket is used to
+	// report an error if the index is not valid.
+	GetIndex(bracket *Token, index interface{}) (interface{}, error)
+
+	// SetIndex stores val at index.
+	SetIndex(bracket *Token, index interface{}, val interface{}) error
+}
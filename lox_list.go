@@ -0,0 +1,256 @@
+package glox
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// LoxList is the runtime representation of lists. Like instances, lists are
+// mutable and shared by reference.
+type LoxList struct {
+	Elements []interface{}
+}
+
+func NewLoxList(elements []interface{}) *LoxList {
+	return &LoxList{Elements: elements}
+}
+
+// listMethod describes a native method of lists.
+type listMethod struct {
+	arity int
+	fn    func(interpreter *Interpreter, list *LoxList, arguments []interface{}) (interface{}, error)
+}
+
+var listMethods = map[string]listMethod{
+	"push":   {1, listPush},
+	"pop":    {0, listPop},
+	"len":    {0, listLen},
+	"slice":  {2, listSlice},
+	"map":    {1, listMap},
+	"filter": {1, listFilter},
+	"sort":   {0, listSort},
+}
+
+// Get returns the method name bound to the list.
+func (ll *LoxList) Get(name *Token) (interface{}, error) {
+	method, ok := listMethods[name.Lexeme]
+	if !ok {
+		return nil, NewRuntimeError(name, "Undefined property '" + name.Lexeme + "'.")
+	}
+
+	return &NativeFunction{
+		Name:  name.Lexeme,
+		arity: uint32(method.arity),
+		fn: func(interpreter *Interpreter, arguments []interface{}) (interface{}, error) {
+			return method.fn(interpreter, ll, arguments)
+		},
+	}, nil
+}
+
+// GetIndex returns the element at index. bracket locates the error if the
+// index is not valid.
+func (ll *LoxList) GetIndex(bracket *Token, index interface{}) (interface{}, error) {
+	i, err := ll.index(bracket, index)
+	if err != nil {
+		return nil, err
+	}
+
+	return ll.Elements[i], nil
+}
+
+// SetIndex replaces the element at index.
+func (ll *LoxList) SetIndex(bracket *Token, index interface{}, val interface{}) error {
+	i, err := ll.index(bracket, index)
+	if err != nil {
+		return err
+	}
+
+	ll.Elements[i] = val
+	return nil
+}
+
+func (ll *LoxList) index(bracket *Token, index interface{}) (int, error) {
+	i, err := integral(index)
+	if err != nil {
+		return 0, NewRuntimeError(bracket, "List index must be an integer.")
+	}
+
+	if i < 0 || i >= int64(len(ll.Elements)) {
+		return 0, NewRuntimeError(bracket, fmt.Sprintf("List index %v out of range for length %d.", i, len(ll.Elements)))
+	}
+
+	return int(i), nil
+}
+
+func (ll *LoxList) String() string {
+	return ll.format(printing{})
+}
+
+func (ll *LoxList) format(p printing) string {
+	if p[ll] || p.tooDeep() {
+		return "[...]"
+	}
+	p[ll] = true
+	defer delete(p, ll)
+
+	elements := make([]string, len(ll.Elements))
+	for idx, element := range ll.Elements {
+		elements[idx] = p.repr(element)
+	}
+
+	return "[" + strings.Join(elements, ", ") + "]"
+}
+
+// printing holds the collections being printed, so that a list or a map
+// that contains itself prints as [...] or {...} instead of recursing
+// without end.
+type printing map[interface{}]bool
+
+// maxPrintDepth is how deep collections nested in each other are printed.
+// Deeper ones are printed as [...] or {...}, like cycles, instead of
+// overflowing the Go stack.
+const maxPrintDepth = 1000
+
+// tooDeep reports whether the collections being printed are nested as deep
+// as maxPrintDepth, since each one of them is in p until it is printed.
+func (p printing) tooDeep() bool {
+	return len(p) >= maxPrintDepth
+}
+
+// repr formats a value inside a collection. Unlike stringify it quotes
+// strings, so that ["1"] and [1] print differently.
+func repr(v interface{}) string {
+	return printing{}.repr(v)
+}
+
+func (p printing) repr(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return strconv.Quote(v)
+	case *LoxList:
+		return v.format(p)
+	case *LoxMap:
+		return v.format(p)
+	}
+
+	return stringify(v)
+}
+
+func listPush(interpreter *Interpreter, list *LoxList, arguments []interface{}) (interface{}, error) {
+	list.Elements = append(list.Elements, arguments[0])
+	return nil, nil
+}
+
+func listPop(interpreter *Interpreter, list *LoxList, arguments []interface{}) (interface{}, error) {
+	if len(list.Elements) == 0 {
+		return nil, errors.New("Can't pop from an empty list.")
+	}
+
+	last := list.Elements[len(list.Elements)-1]
+	list.Elements = list.Elements[:len(list.Elements)-1]
+	return last, nil
+}
+
+func listLen(interpreter *Interpreter, list *LoxList, arguments []interface{}) (interface{}, error) {
+	return int64(len(list.Elements)), nil
+}
+
+// listSlice returns a new list with the elements from start up to, but not
+// including, end.
+func listSlice(interpreter *Interpreter, list *LoxList, arguments []interface{}) (interface{}, error) {
+	start, err := integral(arguments[0])
+	if err != nil {
+		return nil, fmt.Errorf("Slice start %s.", err.Error())
+	}
+
+	end, err := integral(arguments[1])
+	if err != nil {
+		return nil, fmt.Errorf("Slice end %s.", err.Error())
+	}
+
+	if start < 0 || end > int64(len(list.Elements)) || start > end {
+		return nil, fmt.Errorf("Slice bounds [%v, %v] out of range for length %d.", start, end, len(list.Elements))
+	}
+
+	elements := make([]interface{}, int(end)-int(start))
+	copy(elements, list.Elements[int(start):int(end)])
+	return NewLoxList(elements), nil
+}
+
+// listMap returns a new list with the results of calling the function on
+// every element.
+func listMap(interpreter *Interpreter, list *LoxList, arguments []interface{}) (interface{}, error) {
+	elements := make([]interface{}, 0, len(list.Elements))
+	for _, element := range list.Elements {
+		val, err := callCallback(interpreter, arguments[0], element)
+		if err != nil {
+			return nil, err
+		}
+
+		elements = append(elements, val)
+	}
+
+	return NewLoxList(elements), nil
+}
+
+// listFilter returns a new list with the elements for which the function
+// returns a truthy value.
+func listFilter(interpreter *Interpreter, list *LoxList, arguments []interface{}) (interface{}, error) {
+	elements := []interface{}{}
+	for _, element := range list.Elements {
+		val, err := callCallback(interpreter, arguments[0], element)
+		if err != nil {
+			return nil, err
+		}
+
+		if isTruthy(val) {
+			elements = append(elements, element)
+		}
+	}
+
+	return NewLoxList(elements), nil
+}
+
+// listSort sorts a list of numbers or a list of strings in place and
+// returns it.
+func listSort(interpreter *Interpreter, list *LoxList, arguments []interface{}) (interface{}, error) {
+	allNumbers, allStrings := true, true
+	for _, element := range list.Elements {
+		allNumbers = allNumbers && isNumber(element)
+		allStrings = allStrings && isString(element)
+	}
+
+	switch {
+	case allNumbers:
+		sort.SliceStable(list.Elements, func(a, b int) bool {
+			cmp, _ := compareNumbers(list.Elements[a], list.Elements[b])
+			return cmp < 0
+		})
+	case allStrings:
+		sort.SliceStable(list.Elements, func(a, b int) bool {
+			return list.Elements[a].(string) < list.Elements[b].(string)
+		})
+	default:
+		return nil, errors.New("Can only sort lists of numbers or lists of strings.")
+	}
+
+	return list, nil
+}
+
+// callCallback calls a Lox function passed to a native, such as the
+// function given to map.
+func callCallback(interpreter *Interpreter, callee interface{}, arguments ...interface{}) (interface{}, error) {
+	function, isLoxCallable := callee.(LoxCallable)
+	if !isLoxCallable {
+		return nil, errors.New("Can only call functions and classes.")
+	}
+
+	if uint32(len(arguments)) != function.Arity() {
+		return nil, fmt.Errorf("Expected %d arguments but got %d.", function.Arity(), len(arguments))
+	}
+
+	return function.Call(interpreter, arguments)
+}
@@ -0,0 +1,190 @@
+package glox
+
+import (
+	"fmt"
+	"strings"
+)
+
+// LoxMap is the runtime representation of maps. Keys are compared like
+// the == operator compares values, and the entries are kept in insertion
+// order so that iterating and printing a map is deterministic.
+type LoxMap struct {
+	entries map[interface{}]interface{}
+	keys    []interface{}
+}
+
+func NewLoxMap() *LoxMap {
+	return &LoxMap{entries: map[interface{}]interface{}{}}
+}
+
+// mapMethod describes a native method of maps.
+type mapMethod struct {
+	arity int
+	fn    func(interpreter *Interpreter, m *LoxMap, arguments []interface{}) (interface{}, error)
+}
+
+var mapMethods = map[string]mapMethod{
+	"keys":    {0, mapKeys},
+	"values":  {0, mapValues},
+	"has":     {1, mapHas},
+	"remove":  {1, mapRemove},
+	"len":     {0, mapLen},
+	"forEach": {1, mapForEach},
+}
+
+// Get returns the method name bound to the map. Entries are only reachable
+// by indexing, so m.len always refers to the method even if the map has a
+// "len" key.
+func (lm *LoxMap) Get(name *Token) (interface{}, error) {
+	method, ok := mapMethods[name.Lexeme]
+	if !ok {
+		return nil, NewRuntimeError(name, "Undefined property '" + name.Lexeme + "'.")
+	}
+
+	return &NativeFunction{
+		Name:  name.Lexeme,
+		arity: uint32(method.arity),
+		fn: func(interpreter *Interpreter, arguments []interface{}) (interface{}, error) {
+			return method.fn(interpreter, lm, arguments)
+		},
+	}, nil
+}
+
+// GetIndex returns the value stored under key. Looking up a missing key is
+// an error; use has() to test for it.
+func (lm *LoxMap) GetIndex(bracket *Token, key interface{}) (interface{}, error) {
+	k, ok := mapKey(key)
+	if !ok {
+		return nil, NewRuntimeError(bracket, invalidKey(key))
+	}
+
+	val, ok := lm.entries[k]
+	if !ok {
+		return nil, NewRuntimeError(bracket, fmt.Sprintf("Undefined key %s.", repr(key)))
+	}
+
+	return val, nil
+}
+
+// SetIndex stores val under key, adding the key if it is new.
+func (lm *LoxMap) SetIndex(bracket *Token, key interface{}, val interface{}) error {
+	if !lm.Set(key, val) {
+		return NewRuntimeError(bracket, invalidKey(key))
+	}
+
+	return nil
+}
+
+// Set stores val under key. A new key is appended to the iteration order,
+// an existing key keeps its position. It reports false, and stores
+// nothing, if key can't be a map key.
+func (lm *LoxMap) Set(key interface{}, val interface{}) bool {
+	key, ok := mapKey(key)
+	if !ok {
+		return false
+	}
+
+	if _, ok := lm.entries[key]; !ok {
+		lm.keys = append(lm.keys, key)
+	}
+
+	lm.entries[key] = val
+	return true
+}
+
+// invalidKey is the message of the error for a key that Go can't compare.
+func invalidKey(key interface{}) string {
+	return fmt.Sprintf("A %T can't be a map key.", key)
+}
+
+func (lm *LoxMap) String() string {
+	return lm.format(printing{})
+}
+
+func (lm *LoxMap) format(p printing) string {
+	if p[lm] || p.tooDeep() {
+		return "{...}"
+	}
+	p[lm] = true
+	defer delete(p, lm)
+
+	entries := make([]string, len(lm.keys))
+	for idx, key := range lm.keys {
+		entries[idx] = p.repr(key) + ": " + p.repr(lm.entries[key])
+	}
+
+	return "{" + strings.Join(entries, ", ") + "}"
+}
+
+func mapKeys(interpreter *Interpreter, m *LoxMap, arguments []interface{}) (interface{}, error) {
+	keys := make([]interface{}, len(m.keys))
+	copy(keys, m.keys)
+	return NewLoxList(keys), nil
+}
+
+func mapValues(interpreter *Interpreter, m *LoxMap, arguments []interface{}) (interface{}, error) {
+	values := make([]interface{}, len(m.keys))
+	for idx, key := range m.keys {
+		values[idx] = m.entries[key]
+	}
+
+	return NewLoxList(values), nil
+}
+
+func mapHas(interpreter *Interpreter, m *LoxMap, arguments []interface{}) (interface{}, error) {
+	key, ok := mapKey(arguments[0])
+	if !ok {
+		return false, nil
+	}
+
+	_, ok = m.entries[key]
+	return ok, nil
+}
+
+// mapRemove deletes a key and returns its value, or nil if the key was not
+// in the map.
+func mapRemove(interpreter *Interpreter, m *LoxMap, arguments []interface{}) (interface{}, error) {
+	key, ok := mapKey(arguments[0])
+	if !ok {
+		return nil, nil
+	}
+
+	val, ok := m.entries[key]
+	if !ok {
+		return nil, nil
+	}
+
+	delete(m.entries, key)
+	for idx, k := range m.keys {
+		if k == key {
+			m.keys = append(m.keys[:idx], m.keys[idx+1:]...)
+			break
+		}
+	}
+
+	return val, nil
+}
+
+func mapLen(interpreter *Interpreter, m *LoxMap, arguments []interface{}) (interface{}, error) {
+	return int64(len(m.keys)), nil
+}
+
+// mapForEach calls the function with every key and value, in insertion
+// order. The keys are copied first, so the function may modify the map.
+func mapForEach(interpreter *Interpreter, m *LoxMap, arguments []interface{}) (interface{}, error) {
+	keys := make([]interface{}, len(m.keys))
+	copy(keys, m.keys)
+
+	for _, key := range keys {
+		val, ok := m.entries[key]
+		if !ok {
+			continue
+		}
+
+		if _, err := callCallback(interpreter, arguments[0], key, val); err != nil {
+			return nil, err
+		}
+	}
+
+	return nil, nil
+}
@@ -0,0 +1,205 @@
+package glox
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"unicode/utf8"
+)
+
+// Strings are plain Go strings, so their methods and indexing are looked
+// up here rather than on a Lox type. Lengths, indices and characters count
+// Unicode code points, not bytes.
+
+// stringMethod describes a native method of strings.
+type stringMethod struct {
+	arity int
+	fn    func(s string, arguments []interface{}) (interface{}, error)
+}
+
+var stringMethods = map[string]stringMethod{
+	"len":        {0, stringLen},
+	"upper":      {0, stringUpper},
+	"lower":      {0, stringLower},
+	"trim":       {0, stringTrim},
+	"split":      {1, stringSplit},
+	"contains":   {1, stringContains},
+	"startsWith": {1, stringStartsWith},
+	"endsWith":   {1, stringEndsWith},
+	"indexOf":    {1, stringIndexOf},
+	"replace":    {2, stringReplace},
+	"substring":  {2, stringSubstring},
+	"repeat":     {1, stringRepeat},
+}
+
+// stringGet returns the method name bound to s.
+func stringGet(s string, name *Token) (interface{}, error) {
+	method, ok := stringMethods[name.Lexeme]
+	if !ok {
+		return nil, NewRuntimeError(name, "Undefined property '"+name.Lexeme+"'.")
+	}
+
+	return &NativeFunction{
+		Name:  name.Lexeme,
+		arity: uint32(method.arity),
+		fn: func(interpreter *Interpreter, arguments []interface{}) (interface{}, error) {
+			return method.fn(s, arguments)
+		},
+	}, nil
+}
+
+// stringGetIndex returns the character at index as a string of its own.
+func stringGetIndex(bracket *Token, s string, index interface{}) (interface{}, error) {
+	i, err := integral(index)
+	if err != nil {
+		return nil, NewRuntimeError(bracket, "String index must be an integer.")
+	}
+
+	length := utf8.RuneCountInString(s)
+	if i < 0 || i >= int64(length) {
+		return nil, NewRuntimeError(bracket, fmt.Sprintf("String index %v out of range for length %d.", i, length))
+	}
+
+	return string([]rune(s)[i]), nil
+}
+
+// stringArg returns the argument of method as a string.
+func stringArg(method string, arg interface{}) (string, error) {
+	s, ok := arg.(string)
+	if !ok {
+		return "", fmt.Errorf("Argument to %s must be a string.", method)
+	}
+
+	return s, nil
+}
+
+func stringLen(s string, arguments []interface{}) (interface{}, error) {
+	return int64(utf8.RuneCountInString(s)), nil
+}
+
+func stringUpper(s string, arguments []interface{}) (interface{}, error) {
+	return strings.ToUpper(s), nil
+}
+
+func stringLower(s string, arguments []interface{}) (interface{}, error) {
+	return strings.ToLower(s), nil
+}
+
+// stringTrim removes the white space at both ends.
+func stringTrim(s string, arguments []interface{}) (interface{}, error) {
+	return strings.TrimSpace(s), nil
+}
+
+// stringSplit returns the list of the parts of s between the separators.
+// An empty separator splits s into its characters.
+func stringSplit(s string, arguments []interface{}) (interface{}, error) {
+	sep, err := stringArg("split", arguments[0])
+	if err != nil {
+		return nil, err
+	}
+
+	parts := strings.Split(s, sep)
+	elements := make([]interface{}, len(parts))
+	for idx, part := range parts {
+		elements[idx] = part
+	}
+
+	return NewLoxList(elements), nil
+}
+
+func stringContains(s string, arguments []interface{}) (interface{}, error) {
+	substr, err := stringArg("contains", arguments[0])
+	if err != nil {
+		return nil, err
+	}
+
+	return strings.Contains(s, substr), nil
+}
+
+func stringStartsWith(s string, arguments []interface{}) (interface{}, error) {
+	prefix, err := stringArg("startsWith", arguments[0])
+	if err != nil {
+		return nil, err
+	}
+
+	return strings.HasPrefix(s, prefix), nil
+}
+
+func stringEndsWith(s string, arguments []interface{}) (interface{}, error) {
+	suffix, err := stringArg("endsWith", arguments[0])
+	if err != nil {
+		return nil, err
+	}
+
+	return strings.HasSuffix(s, suffix), nil
+}
+
+// stringIndexOf returns the index of the first occurrence of the argument
+// in s, or -1 if there is none.
+func stringIndexOf(s string, arguments []interface{}) (interface{}, error) {
+	substr, err := stringArg("indexOf", arguments[0])
+	if err != nil {
+		return nil, err
+	}
+
+	i := strings.Index(s, substr)
+	if i < 0 {
+		return int64(-1), nil
+	}
+
+	return int64(utf8.RuneCountInString(s[:i])), nil
+}
+
+// stringReplace replaces every occurrence of the first argument by the
+// second.
+func stringReplace(s string, arguments []interface{}) (interface{}, error) {
+	old, err := stringArg("replace", arguments[0])
+	if err != nil {
+		return nil, err
+	}
+
+	replacement, err := stringArg("replace", arguments[1])
+	if err != nil {
+		return nil, err
+	}
+
+	return strings.ReplaceAll(s, old, replacement), nil
+}
+
+// stringSubstring returns the characters from start up to, but not
+// including, end.
+func stringSubstring(s string, arguments []interface{}) (interface{}, error) {
+	start, err := integral(arguments[0])
+	if err != nil {
+		return nil, fmt.Errorf("Substring start %s.", err.Error())
+	}
+
+	end, err := integral(arguments[1])
+	if err != nil {
+		return nil, fmt.Errorf("Substring end %s.", err.Error())
+	}
+
+	runes := []rune(s)
+	if start < 0 || end > int64(len(runes)) || start > end {
+		return nil, fmt.Errorf("Substring bounds [%v, %v] out of range for length %d.", start, end, len(runes))
+	}
+
+	return string(runes[start:end]), nil
+}
+
+// stringRepeat returns s repeated count times.
+func stringRepeat(s string, arguments []interface{}) (interface{}, error) {
+	count, err := integral(arguments[0])
+	if err != nil {
+		return nil, fmt.Errorf("Repeat count %s.", err.Error())
+	}
+
+	if count < 0 {
+		return nil, fmt.Errorf("Repeat count %v must not be negative.", count)
+	}
+	if count > 0 && int64(len(s)) > math.MaxInt32/count {
+		return nil, fmt.Errorf("Repeat count %v is too large.", count)
+	}
+
+	return strings.Repeat(s, int(count)), nil
+}
@@ -0,0 +1,107 @@
+package lsp
+
+import (
+	"glox"
+	"unicode/utf8"
+)
+
+// document is an open text document and the analysis of its current text.
+type document struct {
+	uri      string
+	text     string
+	analysis *glox.Analysis
+
+	// lines holds the byte offset of the start of every line.
+	lines []int
+}
+
+func newDocument(uri string, text string) *document {
+	doc := &document{
+		uri:      uri,
+		text:     text,
+		analysis: glox.Analyze(text),
+		lines:    []int{0},
+	}
+
+	for idx := 0; idx < len(text); idx++ {
+		if text[idx] == '\n' {
+			doc.lines = append(doc.lines, idx+1)
+		}
+	}
+
+	return doc
+}
+
+// position converts a byte offset to a position. LSP counts characters in
+// UTF-16 code units.
+func (doc *document) position(offset int) Position {
+	if offset > len(doc.text) {
+		offset = len(doc.text)
+	}
+
+	line := 0
+	for line+1 < len(doc.lines) && doc.lines[line+1] <= offset {
+		line++
+	}
+
+	return Position{Line: line, Character: utf16Len(doc.text[doc.lines[line]:offset])}
+}
+
+// offset converts a position to a byte offset, clamped to the line.
+func (doc *document) offset(pos Position) int {
+	if pos.Line < 0 {
+		return 0
+	}
+	if pos.Line >= len(doc.lines) {
+		return len(doc.text)
+	}
+
+	offset := doc.lines[pos.Line]
+	for units := 0; units < pos.Character && offset < len(doc.text) && doc.text[offset] != '\n'; {
+		r, size := utf8.DecodeRuneInString(doc.text[offset:])
+		offset += size
+		units++
+		if r >= 0x10000 {
+			units++
+		}
+	}
+
+	return offset
+}
+
+// tokenRange returns the range the token covers.
+func (doc *document) tokenRange(token *glox.Token) Range {
+	start := int(token.Offset)
+	return Range{Start: doc.position(start), End: doc.position(start + int(token.Length))}
+}
+
+// lineRange returns the range of the line, counted from 1, without its
+// line break.
+func (doc *document) lineRange(line int) Range {
+	if line < 1 {
+		line = 1
+	}
+	if line > len(doc.lines) {
+		line = len(doc.lines)
+	}
+
+	start := doc.lines[line-1]
+	end := len(doc.text)
+	if line < len(doc.lines) {
+		end = doc.lines[line] - 1
+	}
+
+	return Range{Start: doc.position(start), End: doc.position(end)}
+}
+
+func utf16Len(s string) int {
+	units := 0
+	for _, r := range s {
+		units++
+		if r >= 0x10000 {
+			units++
+		}
+	}
+
+	return units
+}
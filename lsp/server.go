@@ -0,0 +1,375 @@
+// Package lsp implements a Language Server Protocol server for Lox. It
+// reads JSON-RPC messages from a stream and answers on another one, so it
+// runs over stdio and can be driven by a scripted client.
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"glox"
+	"glox/internal/framing"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Server answers the requests of one client.
+type Server struct {
+	in  *bufio.Reader
+	out io.Writer
+
+	docs map[string]*document
+
+	// shutdown is set by the shutdown request. The exit notification ends
+	// Run.
+	shutdown bool
+}
+
+// NewServer returns a Server that reads requests from in and writes
+// responses and notifications to out.
+func NewServer(in io.Reader, out io.Writer) *Server {
+	return &Server{
+		in:   bufio.NewReader(in),
+		out:  out,
+		docs: map[string]*document{},
+	}
+}
+
+// Run serves requests until the client sends exit or closes the input. It
+// returns an error if the input is not a valid message stream, or if exit
+// came without a shutdown request first.
+func (s *Server) Run() error {
+	for {
+		body, err := framing.ReadMessage(s.in)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		var req request
+		if err := json.Unmarshal(body, &req); err != nil {
+			s.reply(nil, nil, &responseError{Code: codeParseError, Message: err.Error()})
+			continue
+		}
+
+		if req.Method == "exit" {
+			if !s.shutdown {
+				return errors.New("exit before shutdown")
+			}
+			return nil
+		}
+
+		result, rpcErr := s.handle(&req)
+
+		// notifications get no response.
+		if req.ID != nil {
+			s.reply(req.ID, result, rpcErr)
+		}
+	}
+}
+
+func (s *Server) write(message interface{}) {
+	body, err := json.Marshal(message)
+	if err != nil {
+		panic(err)
+	}
+
+	framing.WriteMessage(s.out, body)
+}
+
+func (s *Server) reply(id *json.RawMessage, result interface{}, rpcErr *responseError) {
+	if rpcErr != nil {
+		result = nil
+	}
+
+	s.write(&response{JSONRPC: "2.0", ID: id, Result: result, Error: rpcErr})
+}
+
+func (s *Server) notify(method string, params interface{}) {
+	s.write(&notification{JSONRPC: "2.0", Method: method, Params: params})
+}
+
+// handle dispatches a request or notification by its method.
+func (s *Server) handle(req *request) (interface{}, *responseError) {
+	if s.shutdown && req.Method != "exit" {
+		return nil, &responseError{Code: codeInvalidRequest, Message: "server is shut down"}
+	}
+
+	switch req.Method {
+	case "initialize":
+		return s.initialize(), nil
+	case "initialized":
+		return nil, nil
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+
+	case "textDocument/didOpen":
+		var params didOpenParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		}
+		s.update(params.TextDocument.URI, params.TextDocument.Text)
+		return nil, nil
+	case "textDocument/didChange":
+		var params didChangeParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		}
+		// the server asks for full text sync, so the last change holds
+		// the whole document.
+		if n := len(params.ContentChanges); n > 0 {
+			s.update(params.TextDocument.URI, params.ContentChanges[n-1].Text)
+		}
+		return nil, nil
+	case "textDocument/didClose":
+		var params didCloseParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		}
+		delete(s.docs, params.TextDocument.URI)
+		s.notify("textDocument/publishDiagnostics", &publishDiagnosticsParams{
+			URI:         params.TextDocument.URI,
+			Diagnostics: []Diagnostic{},
+		})
+		return nil, nil
+
+	case "textDocument/definition":
+		return s.withPosition(req, s.definition)
+	case "textDocument/references":
+		return s.withPosition(req, s.references)
+	case "textDocument/hover":
+		return s.withPosition(req, s.hover)
+	case "textDocument/completion":
+		return s.withPosition(req, s.completion)
+	case "textDocument/documentSymbol":
+		var params documentSymbolParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		}
+		doc, ok := s.docs[params.TextDocument.URI]
+		if !ok {
+			return []DocumentSymbol{}, nil
+		}
+		return documentSymbols(doc, doc.analysis.Symbols), nil
+	}
+
+	if req.ID == nil || strings.HasPrefix(req.Method, "$/") {
+		return nil, nil
+	}
+
+	return nil, &responseError{Code: codeMethodNotFound, Message: "method not found: " + req.Method}
+}
+
+func invalidParams(err error) *responseError {
+	return &responseError{Code: codeInvalidParams, Message: err.Error()}
+}
+
+// withPosition decodes the document and position of a request and passes
+// them to fn. A request for a document that is not open gets a null
+// result.
+func (s *Server) withPosition(req *request, fn func(doc *document, offset int, params *textDocumentPositionParams) interface{}) (interface{}, *responseError) {
+	var params textDocumentPositionParams
+	if err := json.Unmarshal(req.Params, &params); err != nil {
+		return nil, invalidParams(err)
+	}
+
+	doc, ok := s.docs[params.TextDocument.URI]
+	if !ok {
+		return nil, nil
+	}
+
+	return fn(doc, doc.offset(params.Position), &params), nil
+}
+
+func (s *Server) initialize() *initializeResult {
+	result := &initializeResult{
+		Capabilities: serverCapabilities{
+			TextDocumentSync:       1,
+			DefinitionProvider:     true,
+			ReferencesProvider:     true,
+			HoverProvider:          true,
+			DocumentSymbolProvider: true,
+			CompletionProvider:     map[string]interface{}{},
+		},
+	}
+	result.ServerInfo.Name = "glox-lsp"
+
+	return result
+}
+
+// update analyzes the new text of a document and publishes its
+// diagnostics.
+func (s *Server) update(uri string, text string) {
+	doc := newDocument(uri, text)
+	s.docs[uri] = doc
+
+	s.notify("textDocument/publishDiagnostics", &publishDiagnosticsParams{
+		URI:         uri,
+		Diagnostics: diagnostics(doc),
+	})
+}
+
+func diagnostics(doc *document) []Diagnostic {
+	diags := []Diagnostic{}
+	for _, err := range doc.analysis.Errors {
+		var rng Range
+		if err.Column == 0 {
+			rng = doc.lineRange(int(err.Line))
+		} else {
+			start := int(err.Offset)
+			rng = Range{Start: doc.position(start), End: doc.position(start + int(err.Length))}
+		}
+
+		diags = append(diags, Diagnostic{
+			Range:    rng,
+			Severity: severityError,
+			Source:   "glox",
+			Message:  err.Message,
+		})
+	}
+
+	for _, warning := range doc.analysis.Warnings {
+		start := int(warning.Offset)
+		diags = append(diags, Diagnostic{
+			Range:    Range{Start: doc.position(start), End: doc.position(start + int(warning.Length))},
+			Severity: severityWarning,
+			Code:     string(warning.Code),
+			Source:   "glox",
+			Message:  warning.Message,
+		})
+	}
+
+	return diags
+}
+
+func (s *Server) definition(doc *document, offset int, params *textDocumentPositionParams) interface{} {
+	symbol := doc.analysis.SymbolAt(uint32(offset))
+	if symbol == nil {
+		return nil
+	}
+
+	return &Location{URI: doc.uri, Range: doc.tokenRange(symbol.Decl)}
+}
+
+func (s *Server) references(doc *document, offset int, params *textDocumentPositionParams) interface{} {
+	locations := []Location{}
+
+	symbol := doc.analysis.SymbolAt(uint32(offset))
+	if symbol == nil {
+		return locations
+	}
+
+	if params.Context.IncludeDeclaration {
+		locations = append(locations, Location{URI: doc.uri, Range: doc.tokenRange(symbol.Decl)})
+	}
+	for _, ref := range symbol.References {
+		locations = append(locations, Location{URI: doc.uri, Range: doc.tokenRange(ref)})
+	}
+
+	return locations
+}
+
+func (s *Server) hover(doc *document, offset int, params *textDocumentPositionParams) interface{} {
+	symbol := doc.analysis.SymbolAt(uint32(offset))
+	if symbol == nil {
+		return nil
+	}
+
+	return &hover{
+		Contents: markupContent{
+			Kind:  "markdown",
+			Value: "```lox\n" + symbol.Detail + "\n```\n\n" + symbol.Kind.String() + " declared on line " + strconv.Itoa(int(symbol.Decl.Line)),
+		},
+		Range: doc.tokenRange(symbol.Decl),
+	}
+}
+
+// completion offers the keywords, the native functions and the names
+// declared in the document. The client filters them by what was typed.
+func (s *Server) completion(doc *document, offset int, params *textDocumentPositionParams) interface{} {
+	items := []CompletionItem{}
+	seen := map[string]bool{}
+
+	add := func(label string, kind int, detail string) {
+		if seen[label] {
+			return
+		}
+		seen[label] = true
+		items = append(items, CompletionItem{Label: label, Kind: kind, Detail: detail})
+	}
+
+	var addSymbols func(symbols []*glox.Symbol)
+	addSymbols = func(symbols []*glox.Symbol) {
+		for _, symbol := range symbols {
+			add(symbol.Name, completionKind(symbol.Kind), symbol.Detail)
+			addSymbols(symbol.Children)
+		}
+	}
+	addSymbols(doc.analysis.Symbols)
+
+	for _, name := range glox.Builtins() {
+		add(name, completionKindFunction, "native function")
+	}
+	for _, word := range glox.Keywords() {
+		add(word, completionKindKeyword, "")
+	}
+
+	return items
+}
+
+func completionKind(kind glox.SymbolKind) int {
+	switch kind {
+	case glox.SymbolFunction:
+		return completionKindFunction
+	case glox.SymbolClass:
+		return completionKindClass
+	case glox.SymbolMethod:
+		return completionKindMethod
+	case glox.SymbolModule:
+		return completionKindModule
+	default:
+		return completionKindVariable
+	}
+}
+
+// documentSymbols lists the top-level declarations, with the methods of
+// classes as their children.
+func documentSymbols(doc *document, symbols []*glox.Symbol) []DocumentSymbol {
+	result := []DocumentSymbol{}
+	for _, symbol := range symbols {
+		if !symbol.Global && symbol.Kind != glox.SymbolMethod {
+			continue
+		}
+
+		rng := doc.tokenRange(symbol.Decl)
+		result = append(result, DocumentSymbol{
+			Name:           symbol.Name,
+			Detail:         symbol.Detail,
+			Kind:           symbolKind(symbol.Kind),
+			Range:          rng,
+			SelectionRange: rng,
+			Children:       documentSymbols(doc, symbol.Children),
+		})
+	}
+
+	return result
+}
+
+func symbolKind(kind glox.SymbolKind) int {
+	switch kind {
+	case glox.SymbolFunction:
+		return symbolKindFunction
+	case glox.SymbolClass:
+		return symbolKindClass
+	case glox.SymbolMethod:
+		return symbolKindMethod
+	case glox.SymbolModule:
+		return symbolKindModule
+	default:
+		return symbolKindVariable
+	}
+}
@@ -0,0 +1,230 @@
+package lsp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"glox/internal/framing"
+	"io"
+	"strings"
+	"testing"
+)
+
+const testURI = "file:///test.lox"
+
+const testSource = `var greeting = "Hello, ";
+fun greet(name) {
+  return greeting + name;
+}
+class Box {
+  init(value) { this.value = value; }
+  get() { return this.value; }
+}
+print greet("Lox");
+`
+
+// message is a message the server sent: a response or a notification.
+type message struct {
+	ID     *int            `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+	Result json.RawMessage `json:"result"`
+	Error  *responseError  `json:"error"`
+}
+
+// session is a scripted client. It queues the messages of a whole session
+// up front, then runs the server on them and collects what it sent.
+type session struct {
+	t      *testing.T
+	input  bytes.Buffer
+	nextID int
+}
+
+func (s *session) send(id *int, method string, params interface{}) {
+	msg := map[string]interface{}{"jsonrpc": "2.0", "method": method}
+	if id != nil {
+		msg["id"] = *id
+	}
+	if params != nil {
+		msg["params"] = params
+	}
+
+	body, err := json.Marshal(msg)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	framing.WriteMessage(&s.input, body)
+}
+
+// request queues a request and returns its id.
+func (s *session) request(method string, params interface{}) int {
+	s.nextID++
+	id := s.nextID
+	s.send(&id, method, params)
+	return id
+}
+
+func (s *session) notify(method string, params interface{}) {
+	s.send(nil, method, params)
+}
+
+// run serves the queued messages and returns the responses by id and the
+// notifications in order.
+func (s *session) run() (map[int]*message, []*message) {
+	var output bytes.Buffer
+	if err := NewServer(&s.input, &output).Run(); err != nil {
+		s.t.Fatalf("Run: %v", err)
+	}
+
+	responses := map[int]*message{}
+	var notifications []*message
+
+	out := bufio.NewReader(&output)
+	for {
+		body, err := framing.ReadMessage(out)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			s.t.Fatal(err)
+		}
+
+		msg := &message{}
+		if err := json.Unmarshal(body, msg); err != nil {
+			s.t.Fatal(err)
+		}
+
+		if msg.ID != nil {
+			responses[*msg.ID] = msg
+		} else {
+			notifications = append(notifications, msg)
+		}
+	}
+
+	return responses, notifications
+}
+
+func position(line int, character int) map[string]interface{} {
+	return map[string]interface{}{
+		"textDocument": map[string]interface{}{"uri": testURI},
+		"position":     Position{Line: line, Character: character},
+	}
+}
+
+// result decodes the result of the response to the request id.
+func result(t *testing.T, responses map[int]*message, id int, v interface{}) {
+	t.Helper()
+
+	resp, ok := responses[id]
+	if !ok {
+		t.Fatalf("no response to request %d", id)
+	}
+	if resp.Error != nil {
+		t.Fatalf("request %d failed: %s", id, resp.Error.Message)
+	}
+	if err := json.Unmarshal(resp.Result, v); err != nil {
+		t.Fatalf("request %d: %v", id, err)
+	}
+}
+
+func TestSession(t *testing.T) {
+	s := &session{t: t}
+
+	initialize := s.request("initialize", map[string]interface{}{"capabilities": map[string]interface{}{}})
+	s.notify("initialized", map[string]interface{}{})
+	s.notify("textDocument/didOpen", map[string]interface{}{
+		"textDocument": map[string]interface{}{"uri": testURI, "languageId": "lox", "version": 1, "text": testSource},
+	})
+
+	// the use of greeting in greet.
+	definition := s.request("textDocument/definition", position(2, 10))
+
+	references := position(2, 10)
+	references["context"] = map[string]interface{}{"includeDeclaration": true}
+	referencesID := s.request("textDocument/references", references)
+
+	// the call of greet.
+	hoverID := s.request("textDocument/hover", position(8, 7))
+	symbols := s.request("textDocument/documentSymbol", map[string]interface{}{
+		"textDocument": map[string]interface{}{"uri": testURI},
+	})
+	completion := s.request("textDocument/completion", position(8, 0))
+	shutdown := s.request("shutdown", nil)
+	s.notify("exit", nil)
+
+	responses, notifications := s.run()
+
+	var init initializeResult
+	result(t, responses, initialize, &init)
+	if init.ServerInfo.Name != "glox-lsp" || !init.Capabilities.DefinitionProvider {
+		t.Errorf("initialize: got %+v", init)
+	}
+
+	if len(notifications) != 1 || notifications[0].Method != "textDocument/publishDiagnostics" {
+		t.Fatalf("notifications: got %+v, want one publishDiagnostics", notifications)
+	}
+	var diags publishDiagnosticsParams
+	if err := json.Unmarshal(notifications[0].Params, &diags); err != nil {
+		t.Fatal(err)
+	}
+	if diags.URI != testURI || len(diags.Diagnostics) != 0 {
+		t.Errorf("diagnostics: got %+v, want none for %s", diags, testURI)
+	}
+
+	var location Location
+	result(t, responses, definition, &location)
+	want := Range{Start: Position{0, 4}, End: Position{0, 12}}
+	if location.URI != testURI || location.Range != want {
+		t.Errorf("definition: got %+v, want %v", location, want)
+	}
+
+	var locations []Location
+	result(t, responses, referencesID, &locations)
+	if len(locations) != 2 || locations[0].Range != want || locations[1].Range.Start != (Position{2, 9}) {
+		t.Errorf("references: got %+v", locations)
+	}
+
+	var hoverResult hover
+	result(t, responses, hoverID, &hoverResult)
+	if !strings.Contains(hoverResult.Contents.Value, "greet(name)") || hoverResult.Range.Start != (Position{1, 4}) {
+		t.Errorf("hover: got %+v", hoverResult)
+	}
+
+	var docSymbols []DocumentSymbol
+	result(t, responses, symbols, &docSymbols)
+	var names []string
+	for _, symbol := range docSymbols {
+		names = append(names, symbol.Name)
+		for _, child := range symbol.Children {
+			names = append(names, symbol.Name+"."+child.Name)
+		}
+	}
+	if got := strings.Join(names, " "); got != "greeting greet Box Box.init Box.get" {
+		t.Errorf("documentSymbol: got %s", got)
+	}
+
+	var items []CompletionItem
+	result(t, responses, completion, &items)
+	labels := map[string]int{}
+	for _, item := range items {
+		labels[item.Label] = item.Kind
+	}
+	for label, kind := range map[string]int{"greet": completionKindFunction, "Box": completionKindClass, "clock": completionKindFunction, "while": completionKindKeyword} {
+		if labels[label] != kind {
+			t.Errorf("completion: %s has kind %d, want %d", label, labels[label], kind)
+		}
+	}
+
+	if resp, ok := responses[shutdown]; !ok || resp.Error != nil {
+		t.Errorf("shutdown: got %+v", resp)
+	}
+}
+
+func TestExitWithoutShutdown(t *testing.T) {
+	s := &session{t: t}
+	s.notify("exit", nil)
+
+	if err := NewServer(&s.input, io.Discard).Run(); err == nil {
+		t.Error("exit before shutdown: got no error")
+	}
+}
@@ -0,0 +1,770 @@
+package glox
+
+import (
+	"fmt"
+	"io"
+)
+
+
+// closure is the runtime representation of a function on the Machine: the
+// compiled function together with the variables it captured.
+type closure struct {
+	proto    *funcProto
+	upvalues []*upvalue
+
+	// globals is the global environment of the module the function was
+	// declared in.
+	globals *Environment
+
+	// machine is the Machine that created the closure, so that natives can
+	// call back into it through the LoxCallable interface.
+	machine *Machine
+}
+
+// Call runs the closure on its Machine.
+func (c *closure) Call(interpreter *Interpreter, arguments []interface{}) (interface{}, error) {
+	return c.machine.callFromNative(c, arguments)
+}
+
+func (c *closure) Arity() uint32 {
+	return c.proto.arity
+}
+
+func (c *closure) String() string {
+	if c.proto.name == "" {
+		return "<anonymous function>"
+	}
+
+	return "<function: " + c.proto.name + ">"
+}
+
+// upvalue is a variable captured by a closure. While the variable is still
+// on the stack, the upvalue refers to its slot. When the variable goes out
+// of scope, the value is moved into the upvalue itself.
+type upvalue struct {
+	slot   int
+	closed interface{}
+	isOpen bool
+
+	// next links the open upvalues, sorted by slot from the top of the stack.
+	next *upvalue
+}
+
+type machineClass struct {
+	name    string
+	methods map[string]*closure
+}
+
+func (mc *machineClass) String() string {
+	return mc.name
+}
+
+type machineInstance struct {
+	class  *machineClass
+	fields map[string]interface{}
+}
+
+func (mi *machineInstance) String() string {
+	return mi.class.name + " instance"
+}
+
+// boundMethod is a method that remembers the instance it was accessed from.
+type boundMethod struct {
+	receiver interface{}
+	method   *closure
+}
+
+func (bm *boundMethod) Call(interpreter *Interpreter, arguments []interface{}) (interface{}, error) {
+	return bm.method.machine.callFromNative(bm, arguments)
+}
+
+func (bm *boundMethod) Arity() uint32 {
+	return bm.method.proto.arity
+}
+
+func (bm *boundMethod) String() string {
+	return bm.method.String()
+}
+
+// callFrame is a function call in progress on the Machine.
+type callFrame struct {
+	closure *closure
+	ip      int
+
+	// slots is the index of the frame's first stack slot, which holds the
+	// function being called or the receiver of a method.
+	slots int
+}
+
+// handler is a try statement in progress. When an error is raised, the
+// Machine unwinds to the innermost handler and continues at ip.
+type handler struct {
+	// frames and stack are the frame count and the stack height when the
+	// handler was installed.
+	frames int
+	stack  int
+	ip     int
+
+	// finally marks the handlers installed by OP_TRY_FINALLY. They receive
+	// the RuntimeError itself so it can be thrown again unchanged, instead
+	// of the value a catch clause sees.
+	finally bool
+}
+
+// Machine is the stack-based virtual machine that runs the bytecode
+// produced by the Compiler. It is an alternative backend to the
+// Interpreter and produces the same results for the same programs.
+type Machine struct {
+	frames []*callFrame
+	stack  []interface{}
+
+	// globals is the global environment of the main script. It is shared
+	// with the Interpreter, so both backends see the natives and the
+	// globals defined by the host, which live in the enclosing environment.
+	globals *Environment
+
+	// importer loads the modules for OP_IMPORT.
+	importer moduleImporter
+
+	openUpvalues *upvalue
+	handlers     []handler
+
+	// stdout receives the output of print statements.
+	stdout io.Writer
+
+	// limiter counts the instructions against the limits of the VM and
+	// bounds the number of frames.
+	limiter *limiter
+}
+
+func NewMachine(globals *Environment, stdout io.Writer) *Machine {
+	return &Machine{
+		globals: globals,
+		stdout:  stdout,
+		limiter: newLimiter(&Options{}),
+	}
+}
+
+// Interpret runs the compiled script and returns the value it returns.
+func (m *Machine) Interpret(script *funcProto) (interface{}, error) {
+	fn := &closure{proto: script, globals: m.globals, machine: m}
+
+	m.push(fn)
+	val, err := m.callAndRun(fn, 0)
+	if err != nil {
+		m.stack = m.stack[:0]
+		m.frames = m.frames[:0]
+		m.openUpvalues = nil
+		m.handlers = m.handlers[:0]
+		return nil, err
+	}
+
+	return val, nil
+}
+
+// runModule runs the compiled top level of a module with globals as its
+// global environment.
+func (m *Machine) runModule(script *funcProto, globals *Environment) (interface{}, error) {
+	fn := &closure{proto: script, globals: globals, machine: m}
+
+	m.push(fn)
+	return m.callAndRun(fn, 0)
+}
+
+// callFromNative calls a closure or a bound method from Go code, typically a
+// native function that takes a callback.
+func (m *Machine) callFromNative(callee interface{}, arguments []interface{}) (interface{}, error) {
+	if uint32(len(arguments)) != callee.(LoxCallable).Arity() {
+		return nil, fmt.Errorf("Expected %d arguments but got %d.", callee.(LoxCallable).Arity(), len(arguments))
+	}
+
+	m.push(callee)
+	for _, arg := range arguments {
+		m.push(arg)
+	}
+
+	return m.callAndRun(callee, len(arguments))
+}
+
+// callAndRun calls the callee, which is already on the stack followed by its
+// arguments, and runs until it returns.
+func (m *Machine) callAndRun(callee interface{}, argCount int) (interface{}, error) {
+	base := len(m.frames)
+	if err := m.callValue(callee, argCount); err != nil {
+		return nil, err
+	}
+
+	// callValue completes native calls and classes without initializers
+	// immediately, leaving the result on the stack.
+	if len(m.frames) == base {
+		return m.pop(), nil
+	}
+
+	return m.run(base)
+}
+
+// run executes instructions until the frame count drops back to base, and
+// returns the value of the last returning frame. Errors are caught by the
+// handlers installed in the frames above base.
+func (m *Machine) run(base int) (interface{}, error) {
+	for {
+		val, err := m.execute(base)
+		if err == nil {
+			return val, nil
+		}
+
+		if !m.catch(base, err) {
+			if runtimeErr, isRuntimeError := err.(*RuntimeError); isRuntimeError && runtimeErr.Trace == nil {
+				runtimeErr.Trace = m.stackTrace()
+			}
+
+			return nil, err
+		}
+	}
+}
+
+// stackTrace returns the frames in progress, innermost first. A callback
+// called by a native runs on the same stack of frames as its caller, so
+// the trace covers every frame down to the script.
+func (m *Machine) stackTrace() []StackFrame {
+	trace := make([]StackFrame, 0, len(m.frames))
+	for idx := len(m.frames) - 1; idx >= 0; idx-- {
+		frame := m.frames[idx]
+		proto := frame.closure.proto
+		trace = append(trace, StackFrame{
+			Function: frameName(proto.name),
+			File:     proto.file,
+			Line:     proto.chunk.Lines[frame.ip-1],
+		})
+	}
+
+	return trace
+}
+
+// catch unwinds the frames and the stack to the innermost handler and
+// pushes the error value for it. It returns false if the handler belongs to
+// a frame below base, or if there is none.
+func (m *Machine) catch(base int, err error) bool {
+	runtimeErr, isRuntimeError := err.(*RuntimeError)
+	if !isRuntimeError || len(m.handlers) == 0 {
+		return false
+	}
+
+	h := m.handlers[len(m.handlers)-1]
+	if h.frames <= base {
+		return false
+	}
+	m.handlers = m.handlers[:len(m.handlers)-1]
+
+	m.closeUpvalues(h.stack)
+	m.frames = m.frames[:h.frames]
+	m.stack = m.stack[:h.stack]
+	m.frames[len(m.frames)-1].ip = h.ip
+
+	if h.finally {
+		m.push(runtimeErr)
+	} else {
+		m.push(runtimeErr.errorValue())
+	}
+
+	return true
+}
+
+// execute is the interpreter loop of run. It starts at the innermost frame.
+func (m *Machine) execute(base int) (interface{}, error) {
+	frame := m.frames[len(m.frames)-1]
+	code := frame.closure.proto.chunk.Code
+
+	readByte := func() byte {
+		frame.ip++
+		return code[frame.ip-1]
+	}
+	readShort := func() int {
+		frame.ip += 2
+		return int(code[frame.ip-2])<<8 | int(code[frame.ip-1])
+	}
+	readConstant := func() interface{} {
+		return frame.closure.proto.chunk.Constants[readShort()]
+	}
+	readString := func() string {
+		return readConstant().(string)
+	}
+
+	for {
+		op := OpCode(readByte())
+		if m.limiter.step() {
+			return nil, m.limiter.err(m.token(""))
+		}
+
+		switch op {
+		case OP_CONSTANT:
+			m.push(readConstant())
+		case OP_NIL:
+			m.push(nil)
+		case OP_TRUE:
+			m.push(true)
+		case OP_FALSE:
+			m.push(false)
+		case OP_POP:
+			m.pop()
+		case OP_GET_LOCAL:
+			m.push(m.stack[frame.slots+int(readByte())])
+		case OP_SET_LOCAL:
+			m.stack[frame.slots+int(readByte())] = m.peek(0)
+		case OP_GET_GLOBAL:
+			name := readString()
+			val, ok := frame.closure.globals.lookup(name)
+			if !ok {
+				return nil, m.runtimeError("Undefined variable '" + name + "'.")
+			}
+			m.push(val)
+		case OP_DEFINE_GLOBAL:
+			frame.closure.globals.Define(readString(), m.pop())
+		case OP_SET_GLOBAL:
+			name := readString()
+			if !frame.closure.globals.assign(name, m.peek(0)) {
+				return nil, m.runtimeError("Undefined variable '" + name + "'.")
+			}
+		case OP_GET_UPVALUE:
+			m.push(m.getUpvalue(frame.closure.upvalues[readByte()]))
+		case OP_SET_UPVALUE:
+			m.setUpvalue(frame.closure.upvalues[readByte()], m.peek(0))
+		case OP_GET_PROPERTY:
+			name := readString()
+			if value, ok, err := m.builtinProperty(m.peek(0), name); ok {
+				if err != nil {
+					return nil, err
+				}
+				m.pop()
+				m.push(value)
+				break
+			}
+
+			instance, isInstance := m.peek(0).(*machineInstance)
+			if !isInstance {
+				return nil, m.runtimeError("Only instances have properties.")
+			}
+
+			if val, ok := instance.fields[name]; ok {
+				m.pop()
+				m.push(val)
+				break
+			}
+
+			method, ok := instance.class.methods[name]
+			if !ok {
+				return nil, m.runtimeError("Undefined property '" + name + "'.")
+			}
+			m.pop()
+			m.push(&boundMethod{receiver: instance, method: method})
+		case OP_SET_PROPERTY:
+			name := readString()
+			instance, isInstance := m.peek(1).(*machineInstance)
+			if !isInstance {
+				return nil, m.runtimeError("Only instances have fields.")
+			}
+
+			val := m.pop()
+			m.pop()
+			instance.fields[name] = val
+			m.push(val)
+		case OP_GET_SUPER:
+			name := readString()
+			superclass := m.pop().(*machineClass)
+			method, ok := superclass.methods[name]
+			if !ok {
+				return nil, m.runtimeError("Undefined property '" + name + "'.")
+			}
+			m.push(&boundMethod{receiver: m.pop(), method: method})
+		case OP_EQUAL:
+			right, left := m.pop(), m.pop()
+			m.push(valuesEqual(left, right))
+		case OP_GREATER, OP_GREATER_EQUAL, OP_LESS, OP_LESS_EQUAL, OP_SUBTRACT, OP_MULTIPLY, OP_DIVIDE, OP_FLOOR_DIVIDE:
+			if !isNumber(m.peek(0)) || !isNumber(m.peek(1)) {
+				return nil, m.runtimeError("Operands must be numbers.")
+			}
+
+			right, left := m.pop(), m.pop()
+			switch op {
+			case OP_GREATER:
+				cmp, ok := compareNumbers(left, right)
+				m.push(ok && cmp > 0)
+			case OP_GREATER_EQUAL:
+				cmp, ok := compareNumbers(left, right)
+				m.push(ok && cmp >= 0)
+			case OP_LESS:
+				cmp, ok := compareNumbers(left, right)
+				m.push(ok && cmp < 0)
+			case OP_LESS_EQUAL:
+				cmp, ok := compareNumbers(left, right)
+				m.push(ok && cmp <= 0)
+			case OP_SUBTRACT:
+				m.push(subtractNumbers(left, right))
+			case OP_MULTIPLY:
+				m.push(multiplyNumbers(left, right))
+			case OP_DIVIDE, OP_FLOOR_DIVIDE:
+				if toFloat(right) == 0 {
+					return nil, m.runtimeError("divisor can not be 0.")
+				}
+				if op == OP_DIVIDE {
+					m.push(toFloat(left) / toFloat(right))
+				} else {
+					m.push(floorDivideNumbers(left, right))
+				}
+			}
+		case OP_ADD:
+			right, left := m.peek(0), m.peek(1)
+			var sum interface{}
+			switch {
+			case isNumber(left) && isNumber(right):
+				sum = addNumbers(left, right)
+			case isString(left) && isString(right):
+				sum = left.(string) + right.(string)
+			case isString(left) && isNumber(right):
+				sum = left.(string) + formatNumber(right)
+			case isNumber(left) && isString(right):
+				sum = formatNumber(left) + right.(string)
+			default:
+				return nil, m.runtimeError("both operands must be numbers or strings.")
+			}
+			m.pop()
+			m.pop()
+			m.push(sum)
+		case OP_NOT:
+			m.push(!isTruthy(m.pop()))
+		case OP_NEGATE:
+			if !isNumber(m.peek(0)) {
+				return nil, m.runtimeError("Operand must be a number.")
+			}
+			m.push(negateNumber(m.pop()))
+		case OP_STRINGIFY:
+			m.push(stringify(m.pop()))
+		case OP_PRINT:
+			fmt.Fprintln(m.stdout, stringify(m.pop()))
+		case OP_JUMP:
+			offset := readShort()
+			frame.ip += offset
+		case OP_JUMP_IF_FALSE:
+			offset := readShort()
+			if !isTruthy(m.peek(0)) {
+				frame.ip += offset
+			}
+		case OP_LOOP:
+			offset := readShort()
+			frame.ip -= offset
+		case OP_CALL:
+			argCount := int(readByte())
+			if err := m.callValue(m.peek(argCount), argCount); err != nil {
+				return nil, err
+			}
+			frame = m.frames[len(m.frames)-1]
+			code = frame.closure.proto.chunk.Code
+		case OP_CLOSURE:
+			proto := readConstant().(*funcProto)
+			fn := &closure{
+				proto:    proto,
+				upvalues: make([]*upvalue, proto.upvalueCount),
+				globals:  frame.closure.globals,
+				machine:  m,
+			}
+			for idx := range fn.upvalues {
+				isLocal, index := readByte(), int(readByte())
+				if isLocal == 1 {
+					fn.upvalues[idx] = m.captureUpvalue(frame.slots + index)
+				} else {
+					fn.upvalues[idx] = frame.closure.upvalues[index]
+				}
+			}
+			m.push(fn)
+		case OP_CLOSE_UPVALUE:
+			m.closeUpvalues(len(m.stack) - 1)
+			m.pop()
+		case OP_RETURN:
+			result := m.pop()
+			m.closeUpvalues(frame.slots)
+
+			m.frames = m.frames[:len(m.frames)-1]
+			m.stack = m.stack[:frame.slots]
+			if len(m.frames) == base {
+				return result, nil
+			}
+
+			m.push(result)
+			frame = m.frames[len(m.frames)-1]
+			code = frame.closure.proto.chunk.Code
+		case OP_CLASS:
+			m.push(&machineClass{name: readString(), methods: map[string]*closure{}})
+		case OP_INHERIT:
+			superclass, isClass := m.peek(1).(*machineClass)
+			if !isClass {
+				return nil, m.runtimeError("Superclass must be a class.")
+			}
+
+			// copy-down inheritance: the methods are copied into the subclass
+			// before its own methods override them.
+			subclass := m.peek(0).(*machineClass)
+			for name, method := range superclass.methods {
+				subclass.methods[name] = method
+			}
+			m.pop()
+		case OP_METHOD:
+			method := m.pop().(*closure)
+			m.peek(0).(*machineClass).methods[readString()] = method
+		case OP_BUILD_LIST:
+			count := int(readByte())
+			elements := make([]interface{}, count)
+			copy(elements, m.stack[len(m.stack)-count:])
+			m.stack = m.stack[:len(m.stack)-count]
+			m.push(NewLoxList(elements))
+		case OP_APPEND_LIST:
+			count := int(readByte())
+			list := m.peek(count).(*LoxList)
+			list.Elements = append(list.Elements, m.stack[len(m.stack)-count:]...)
+			m.stack = m.stack[:len(m.stack)-count]
+		case OP_BUILD_MAP:
+			count := int(readByte())
+			lm := NewLoxMap()
+			if err := m.setEntries(lm, count); err != nil {
+				return nil, err
+			}
+			m.push(lm)
+		case OP_APPEND_MAP:
+			count := int(readByte())
+			if err := m.setEntries(m.peek(2*count).(*LoxMap), count); err != nil {
+				return nil, err
+			}
+		case OP_GET_INDEX:
+			var val interface{}
+			var err error
+			if s, isString := m.peek(1).(string); isString {
+				val, err = stringGetIndex(m.token("["), s, m.peek(0))
+			} else if indexable, isIndexable := m.peek(1).(LoxIndexable); isIndexable {
+				val, err = indexable.GetIndex(m.token("["), m.peek(0))
+			} else {
+				return nil, m.runtimeError("Only lists, maps and strings can be indexed.")
+			}
+			if err != nil {
+				return nil, err
+			}
+			m.pop()
+			m.pop()
+			m.push(val)
+		case OP_SET_INDEX:
+			if isString(m.peek(2)) {
+				return nil, m.runtimeError("Strings can't be modified.")
+			}
+
+			indexable, isIndexable := m.peek(2).(LoxIndexable)
+			if !isIndexable {
+				return nil, m.runtimeError("Only lists and maps can be indexed.")
+			}
+
+			val := m.pop()
+			if err := indexable.SetIndex(m.token("["), m.pop(), val); err != nil {
+				return nil, err
+			}
+			m.pop()
+			m.push(val)
+		case OP_TRY, OP_TRY_FINALLY:
+			offset := readShort()
+			m.handlers = append(m.handlers, handler{
+				frames:  len(m.frames),
+				stack:   len(m.stack),
+				ip:      frame.ip + offset,
+				finally: op == OP_TRY_FINALLY,
+			})
+		case OP_END_TRY:
+			m.handlers = m.handlers[:len(m.handlers)-1]
+		case OP_IMPORT:
+			path := readString()
+			if m.importer == nil {
+				return nil, m.runtimeError("Can't import modules here.")
+			}
+
+			module, err := m.importer(path, m.token("import"))
+			if err != nil {
+				return nil, err
+			}
+			m.push(module)
+		case OP_THROW:
+			value := m.pop()
+
+			// a finally handler throws the pending error again.
+			if runtimeErr, isRuntimeError := value.(*RuntimeError); isRuntimeError {
+				return nil, runtimeErr
+			}
+
+			return nil, NewThrowError(m.token("throw"), value)
+		}
+	}
+}
+
+// builtinProperty looks up a property of the built-in objects, such as the
+// methods of lists and maps. ok is false if object is not one of them.
+func (m *Machine) builtinProperty(object interface{}, name string) (value interface{}, ok bool, err error) {
+	switch object := object.(type) {
+	case *LoxList:
+		value, err = object.Get(m.token(name))
+	case *LoxMap:
+		value, err = object.Get(m.token(name))
+	case *LoxError:
+		value, err = object.Get(m.token(name))
+	case *LoxModule:
+		value, err = object.Get(m.token(name))
+	case string:
+		value, err = stringGet(object, m.token(name))
+	default:
+		return nil, false, nil
+	}
+
+	return value, true, err
+}
+
+// callValue calls the callee that sits on the stack below its arguments.
+// Calls to closures push a new frame; everything else completes at once and
+// replaces the callee and arguments by the result.
+func (m *Machine) callValue(callee interface{}, argCount int) error {
+	switch callee := callee.(type) {
+	case *closure:
+		return m.call(callee, argCount)
+	case *boundMethod:
+		m.stack[len(m.stack)-argCount-1] = callee.receiver
+		return m.call(callee.method, argCount)
+	case *machineClass:
+		m.stack[len(m.stack)-argCount-1] = &machineInstance{class: callee, fields: map[string]interface{}{}}
+		if initializer, ok := callee.methods["init"]; ok {
+			return m.call(initializer, argCount)
+		}
+
+		if argCount != 0 {
+			return m.runtimeError(fmt.Sprintf("Expected 0 arguments but got %d.", argCount))
+		}
+		return nil
+	case LoxCallable:
+		if uint32(argCount) != callee.Arity() {
+			return m.runtimeError(fmt.Sprintf("Expected %d arguments but got %d.", callee.Arity(), argCount))
+		}
+
+		arguments := make([]interface{}, argCount)
+		copy(arguments, m.stack[len(m.stack)-argCount:])
+
+		result, err := callee.Call(nil, arguments)
+		if err != nil {
+			if _, isRuntimeError := err.(*RuntimeError); isRuntimeError || isFatal(err) {
+				return err
+			}
+			return m.runtimeError(err.Error())
+		}
+
+		m.stack = m.stack[:len(m.stack)-argCount-1]
+		m.push(result)
+		return nil
+	}
+
+	return m.runtimeError("Can only call functions and classes.")
+}
+
+func (m *Machine) call(fn *closure, argCount int) error {
+	if uint32(argCount) != fn.proto.arity {
+		return m.runtimeError(fmt.Sprintf("Expected %d arguments but got %d.", fn.proto.arity, argCount))
+	}
+
+	// the frame of the script does not count as a call.
+	if len(m.frames) > m.limiter.maxCallDepth {
+		return &LimitError{Limit: CallDepthLimit, Token: m.token("")}
+	}
+
+	m.frames = append(m.frames, &callFrame{closure: fn, slots: len(m.stack) - argCount - 1})
+	return nil
+}
+
+// captureUpvalue returns the open upvalue for the slot, creating it if no
+// closure has captured the slot yet, so that closures share variables.
+func (m *Machine) captureUpvalue(slot int) *upvalue {
+	var prev *upvalue
+	uv := m.openUpvalues
+	for uv != nil && uv.slot > slot {
+		prev = uv
+		uv = uv.next
+	}
+
+	if uv != nil && uv.slot == slot {
+		return uv
+	}
+
+	created := &upvalue{slot: slot, isOpen: true, next: uv}
+	if prev == nil {
+		m.openUpvalues = created
+	} else {
+		prev.next = created
+	}
+
+	return created
+}
+
+// closeUpvalues closes every open upvalue at or above the slot.
+func (m *Machine) closeUpvalues(last int) {
+	for m.openUpvalues != nil && m.openUpvalues.slot >= last {
+		uv := m.openUpvalues
+		uv.closed = m.stack[uv.slot]
+		uv.isOpen = false
+		m.openUpvalues = uv.next
+	}
+}
+
+func (m *Machine) getUpvalue(uv *upvalue) interface{} {
+	if uv.isOpen {
+		return m.stack[uv.slot]
+	}
+
+	return uv.closed
+}
+
+func (m *Machine) setUpvalue(uv *upvalue, val interface{}) {
+	if uv.isOpen {
+		m.stack[uv.slot] = val
+	} else {
+		uv.closed = val
+	}
+}
+
+func (m *Machine) push(val interface{}) {
+	m.stack = append(m.stack, val)
+}
+
+func (m *Machine) pop() interface{} {
+	val := m.stack[len(m.stack)-1]
+	m.stack = m.stack[:len(m.stack)-1]
+	return val
+}
+
+func (m *Machine) peek(distance int) interface{} {
+	return m.stack[len(m.stack)-1-distance]
+}
+
+// runtimeError returns a RuntimeError at the line of the instruction being
+// executed in the innermost frame.
+// setEntries pops count key, value pairs off the stack into lm.
+func (m *Machine) setEntries(lm *LoxMap, count int) error {
+	entries := m.stack[len(m.stack)-2*count:]
+	for idx := 0; idx < count; idx++ {
+		if !lm.Set(entries[2*idx], entries[2*idx+1]) {
+			return m.runtimeError(invalidKey(entries[2*idx]))
+		}
+	}
+
+	m.stack = m.stack[:len(m.stack)-2*count]
+	return nil
+}
+
+func (m *Machine) runtimeError(message string) error {
+	return NewRuntimeError(m.token(""), message)
+}
+
+// token makes up a token at the line of the instruction being executed, for
+// the runtime objects that report errors at a token.
+func (m *Machine) token(lexeme string) *Token {
+	frame := m.frames[len(m.frames)-1]
+	proto := frame.closure.proto
+	return &Token{Lexeme: lexeme, Line: proto.chunk.Lines[frame.ip-1], File: proto.file}
+}
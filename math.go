@@ -0,0 +1,179 @@
+package glox
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// newMathModule returns the math namespace, a module without a file whose
+// bindings are natives and constants. random draws from source.
+func newMathModule(source *rand.Rand) *LoxModule {
+	globals := NewGlobalEnvironment(nil)
+	define := func(name string, arity int, fn NativeFunc) {
+		globals.Define(name, NewNativeFunction(name, arity, fn))
+	}
+
+	define("sqrt", 1, floatFunc("sqrt", math.Sqrt))
+	define("sin", 1, floatFunc("sin", math.Sin))
+	define("cos", 1, floatFunc("cos", math.Cos))
+	define("tan", 1, floatFunc("tan", math.Tan))
+	define("log", 1, floatFunc("log", math.Log))
+	define("exp", 1, floatFunc("exp", math.Exp))
+	define("floor", 1, roundFunc("floor", math.Floor))
+	define("ceil", 1, roundFunc("ceil", math.Ceil))
+	define("round", 1, roundFunc("round", math.Round))
+	define("abs", 1, mathAbs)
+	define("pow", 2, mathPow)
+	define("min", 2, mathMin)
+	define("max", 2, mathMax)
+	define("isNaN", 1, mathIsNaN)
+	define("random", 0, func(arguments []Value) (Value, error) {
+		return source.Float64(), nil
+	})
+
+	globals.Define("pi", math.Pi)
+	globals.Define("e", math.E)
+	globals.Define("inf", math.Inf(1))
+	globals.Define("nan", math.NaN())
+
+	return &LoxModule{Path: "math", globals: globals, loaded: true}
+}
+
+// newRandomSource returns the generator behind math.random, seeded from
+// the current time.
+func newRandomSource() *rand.Rand {
+	return rand.New(rand.NewSource(time.Now().UnixNano()))
+}
+
+// numberArg returns the argument of the math function name, which must be
+// a number.
+func numberArg(name string, arg Value) (Value, error) {
+	if !isNumber(arg) {
+		return nil, fmt.Errorf("Argument to %s must be a number.", name)
+	}
+
+	return arg, nil
+}
+
+// floatFunc makes a native of a Go function of one float64.
+func floatFunc(name string, fn func(float64) float64) NativeFunc {
+	return func(arguments []Value) (Value, error) {
+		x, err := numberArg(name, arguments[0])
+		if err != nil {
+			return nil, err
+		}
+
+		return fn(toFloat(x)), nil
+	}
+}
+
+// roundFunc makes a native of a Go rounding function. Integers are
+// returned as they are, and results that fit in an integer are integers.
+func roundFunc(name string, fn func(float64) float64) NativeFunc {
+	return func(arguments []Value) (Value, error) {
+		x, err := numberArg(name, arguments[0])
+		if err != nil {
+			return nil, err
+		}
+
+		if isInt64(x) {
+			return x, nil
+		}
+
+		result, _ := mapKey(fn(x.(float64)))
+		return result, nil
+	}
+}
+
+func mathAbs(arguments []Value) (Value, error) {
+	x, err := numberArg("abs", arguments[0])
+	if err != nil {
+		return nil, err
+	}
+
+	if i, ok := x.(int64); ok {
+		if i < 0 {
+			return negateNumber(i), nil
+		}
+		return i, nil
+	}
+
+	return math.Abs(x.(float64)), nil
+}
+
+// mathPow raises the first argument to the power of the second. An integer
+// raised to a non-negative integer power is an integer, unless it
+// overflows.
+func mathPow(arguments []Value) (Value, error) {
+	x, err := numberArg("pow", arguments[0])
+	if err != nil {
+		return nil, err
+	}
+
+	y, err := numberArg("pow", arguments[1])
+	if err != nil {
+		return nil, err
+	}
+
+	exponent, isInt := y.(int64)
+	if !isInt64(x) || !isInt || exponent < 0 {
+		return math.Pow(toFloat(x), toFloat(y)), nil
+	}
+
+	// exponentiation by squaring, which falls back to floats on overflow
+	// like multiplication does.
+	var result Value = int64(1)
+	for base := x; exponent > 0; exponent >>= 1 {
+		if exponent&1 == 1 {
+			result = multiplyNumbers(result, base)
+		}
+		if exponent > 1 {
+			base = multiplyNumbers(base, base)
+		}
+	}
+
+	return result, nil
+}
+
+func mathMin(arguments []Value) (Value, error) {
+	return minMax("min", arguments, -1)
+}
+
+func mathMax(arguments []Value) (Value, error) {
+	return minMax("max", arguments, 1)
+}
+
+// minMax returns the argument that compares to the other one as sign says.
+// If either is NaN, the result is NaN.
+func minMax(name string, arguments []Value, sign int) (Value, error) {
+	x, err := numberArg(name, arguments[0])
+	if err != nil {
+		return nil, err
+	}
+
+	y, err := numberArg(name, arguments[1])
+	if err != nil {
+		return nil, err
+	}
+
+	cmp, ok := compareNumbers(y, x)
+	if !ok {
+		return math.NaN(), nil
+	}
+	if cmp == sign {
+		return y, nil
+	}
+
+	return x, nil
+}
+
+func mathIsNaN(arguments []Value) (Value, error) {
+	x, err := numberArg("isNaN", arguments[0])
+	if err != nil {
+		return nil, err
+	}
+
+	return !isInt64(x) && math.IsNaN(x.(float64)), nil
+}
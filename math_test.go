@@ -0,0 +1,32 @@
+package glox
+
+import "testing"
+
+// randoms returns the first values of math.random in a new VM.
+func randoms(t *testing.T, opts *Options) string {
+	val, err := NewVM(opts).Eval("[math.random(), math.random(), math.random()]")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return stringify(val)
+}
+
+func TestSeed(t *testing.T) {
+	for _, backend := range backends {
+		first := randoms(t, &Options{Backend: backend.backend, Seed: 42})
+		if second := randoms(t, &Options{Backend: backend.backend, Seed: 42}); second != first {
+			t.Errorf("%s: seed 42 gave %s, then %s", backend.name, first, second)
+		}
+
+		if other := randoms(t, &Options{Backend: backend.backend, Seed: 43}); other == first {
+			t.Errorf("%s: seeds 42 and 43 both gave %s", backend.name, first)
+		}
+
+		vm := NewVM(&Options{Backend: backend.backend})
+		vm.Seed(42)
+		if val, _ := vm.Eval("[math.random(), math.random(), math.random()]"); stringify(val) != first {
+			t.Errorf("%s: VM.Seed(42) gave %s, want %s", backend.name, stringify(val), first)
+		}
+	}
+}
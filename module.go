@@ -0,0 +1,193 @@
+package glox
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// LoxModule is the namespace object of an imported file. Its properties
+// are the top-level bindings of the file, read from the module's global
+// environment, so they reflect later assignments made by the module.
+type LoxModule struct {
+	// Path is the path of the file, relative to the main script's
+	// directory unless the import used an absolute path.
+	Path string
+
+	globals *Environment
+
+	// exports holds the names declared with export. If the module exports
+	// nothing explicitly, it is nil and every top-level binding is visible.
+	exports map[string]bool
+
+	// loaded is false while the module's code is running, so that an
+	// import of a module that is still loading is reported as a cycle.
+	loaded bool
+}
+
+// Get returns the exported binding name of the module.
+func (lm *LoxModule) Get(name *Token) (interface{}, error) {
+	val, defined := lm.globals.values[name.Lexeme]
+	if !defined || (lm.exports != nil && !lm.exports[name.Lexeme]) {
+		return nil, NewRuntimeError(name, "Module '" + lm.Path + "' has no export '" + name.Lexeme + "'.")
+	}
+
+	return val, nil
+}
+
+// names returns the names of the exported bindings of the module.
+func (lm *LoxModule) names() []string {
+	names := []string{}
+	for name := range lm.globals.values {
+		if lm.exports == nil || lm.exports[name] {
+			names = append(names, name)
+		}
+	}
+
+	return names
+}
+
+func (lm *LoxModule) String() string {
+	return "<module: " + lm.Path + ">"
+}
+
+// moduleImporter loads the module at path for an import statement at
+// keyword.
+type moduleImporter func(path string, keyword *Token) (*LoxModule, error)
+
+// importPath returns the path of the module an import statement refers
+// to. Relative paths are relative to the directory of the importing file.
+func importPath(stmt *Import) string {
+	path := stmt.Path.Literal.(string)
+	if filepath.IsAbs(path) {
+		return filepath.Clean(path)
+	}
+
+	return filepath.Join(stmt.Dir, path)
+}
+
+// exportedNames returns the names declared with export at the top level
+// of a module, or nil if there are none.
+func exportedNames(stmts []Stmt) map[string]bool {
+	var exports map[string]bool
+	for _, stmt := range stmts {
+		export, isExport := stmt.(*Export)
+		if !isExport {
+			continue
+		}
+
+		if exports == nil {
+			exports = map[string]bool{}
+		}
+
+		switch decl := export.Declaration.(type) {
+		case *Var:
+			exports[decl.Name.Lexeme] = true
+		case *Function:
+			exports[decl.Name.Lexeme] = true
+		case *Class:
+			exports[decl.Name.Lexeme] = true
+		}
+	}
+
+	return exports
+}
+
+// importModule runs the module at path the first time it is imported and
+// returns it from the cache afterwards. A module that imports itself,
+// directly or through other modules, is an error.
+func (vm *VM) importModule(path string, keyword *Token) (*LoxModule, error) {
+	// the same file may be imported by different relative paths.
+	key, err := filepath.Abs(path)
+	if err != nil {
+		return nil, NewRuntimeError(keyword, "Can't read module '" + path + "'.")
+	}
+
+	if module, ok := vm.modules[key]; ok {
+		if module.loaded {
+			return module, nil
+		}
+
+		cycle := []string{}
+		for idx := len(vm.loading) - 1; idx >= 0; idx-- {
+			cycle = append([]string{vm.modules[vm.loading[idx]].Path}, cycle...)
+			if vm.loading[idx] == key {
+				break
+			}
+		}
+		cycle = append(cycle, path)
+
+		return nil, NewRuntimeError(keyword, "Import cycle: " + strings.Join(cycle, " -> ") + ".")
+	}
+
+	source, err := os.ReadFile(path)
+	if err != nil {
+		return nil, NewRuntimeError(keyword, "Can't read module '" + path + "'.")
+	}
+
+	// static errors are reported with the name of the module's file.
+	errorPrinter := NewErrorPrinter(vm.errorPrinter.writer)
+	errorPrinter.file = path
+	errorPrinter.SetSource(string(source))
+
+	scanner := NewScanner(string(source), errorPrinter)
+	scanner.file = path
+	tokens := scanner.ScanTokens()
+
+	parser := NewParser(tokens, errorPrinter)
+	parser.dir = filepath.Dir(path)
+	stmts := parser.Parse()
+
+	if !errorPrinter.hadError {
+		NewResolver(vm.interpreter, errorPrinter).Resolve(stmts)
+	}
+
+	if errorPrinter.hadError {
+		return nil, &CompileError{Errors: errorPrinter.Errors()}
+	}
+
+	module := &LoxModule{
+		Path: path,
+		globals: NewGlobalEnvironment(vm.interpreter.builtins),
+		exports: exportedNames(stmts),
+	}
+
+	vm.modules[key] = module
+	vm.loading = append(vm.loading, key)
+	defer func() {
+		vm.loading = vm.loading[:len(vm.loading)-1]
+	}()
+
+	if vm.machine != nil {
+		compiler := NewCompiler(errorPrinter)
+		compiler.file = path
+		script := compiler.Compile(stmts)
+		if errorPrinter.hadError {
+			delete(vm.modules, key)
+			return nil, &CompileError{Errors: errorPrinter.Errors()}
+		}
+
+		_, err = vm.machine.runModule(script, module.globals)
+	} else {
+		if debugger := vm.interpreter.debugger; debugger != nil {
+			debugger.enterFrame("<script>", module.globals)
+		}
+		err = vm.interpreter.executeBlock(stmts, module.globals)
+		if debugger := vm.interpreter.debugger; debugger != nil {
+			debugger.exitFrame()
+		}
+		if runtimeErr, isRuntimeError := err.(*RuntimeError); isRuntimeError {
+			runtimeErr.exitFrame("<script>")
+			runtimeErr.setCallSite(keyword)
+		}
+	}
+
+	// a module that failed to load can be imported again.
+	if err != nil {
+		delete(vm.modules, key)
+		return nil, err
+	}
+
+	module.loaded = true
+	return module, nil
+}
@@ -4,21 +4,53 @@ import (
 	"time"
 )
 
-// Clock is a implemention of the LoxCallable.
-// It provides user with a native function "clock()" to get the current time.
-type Clock struct{}
+// NativeFunc is the signature of the Go functions that can be exposed to
+// Lox scripts through Interpreter.DefineNative.
+type NativeFunc func(arguments []Value) (Value, error)
 
-// Call calls the corresponding Go function for time and converts it to
-// a float64 value in seconds.
-func (c *Clock) Call(interpreter *Interpreter, arguments []interface{}) (interface{}, error) {
-	return float64(time.Now().Unix()), nil
+// NativeFunction is a implemention of the LoxCallable that is backed by a
+// Go function. It is how the host program exposes its own operations to
+// scripts.
+type NativeFunction struct {
+	Name string
+
+	arity uint32
+
+	// fn is the Go implementation. It receives the interpreter so that the
+	// built-in natives can call back into Lox code.
+	fn func(interpreter *Interpreter, arguments []interface{}) (interface{}, error)
+}
+
+// NewNativeFunction returns a NativeFunction named name that takes arity
+// arguments and is implemented by fn.
+func NewNativeFunction(name string, arity int, fn NativeFunc) *NativeFunction {
+	return &NativeFunction{
+		Name:  name,
+		arity: uint32(arity),
+		fn: func(interpreter *Interpreter, arguments []interface{}) (interface{}, error) {
+			return fn(arguments)
+		},
+	}
 }
 
-// Arity returns 0 because the function "clock()" takes no arguments.
-func (c *Clock) Arity() uint32 {
-	return 0
+// Call calls the Go function. The arity has already been checked by the
+// interpreter. An error returned by the Go function is reported as a
+// runtime error at the call site.
+func (nf *NativeFunction) Call(interpreter *Interpreter, arguments []interface{}) (interface{}, error) {
+	return nf.fn(interpreter, arguments)
 }
 
-func (c *Clock) String() string {
-	return "<native function: clock>"
+func (nf *NativeFunction) Arity() uint32 {
+	return nf.arity
+}
+
+func (nf *NativeFunction) String() string {
+	return "<native function: " + nf.Name + ">"
+}
+
+// clock provides user with a native function "clock()" to get the current
+// time. It calls the corresponding Go function for time and converts it to
+// a float64 value in seconds.
+func clock(arguments []Value) (Value, error) {
+	return float64(time.Now().Unix()), nil
 }
@@ -0,0 +1,238 @@
+package glox
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+)
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// WrapFunc adapts an arbitrary Go function, such as
+// func(string, float64) (string, error), to a NativeFunction.
+//
+// Lox values are converted to the parameter types when the function is
+// called: numbers to any integer or float type (integers must be integral
+// and in range), strings to string, booleans to bool, and any other value
+// to a parameter whose type it is assignable to, e.g. interface{}. A value
+// of the wrong type is reported as a runtime error naming the argument.
+//
+// The function may return nothing, a value, an error, or a value and an
+// error. Integer and float results are converted back to Lox numbers,
+// slices and arrays to lists and maps to Lox maps.
+func WrapFunc(name string, fn interface{}) (*NativeFunction, error) {
+	val := reflect.ValueOf(fn)
+	if !val.IsValid() {
+		return nil, fmt.Errorf("glox: %s is nil, not a function", name)
+	}
+
+	t := val.Type()
+	if t.Kind() != reflect.Func {
+		return nil, fmt.Errorf("glox: %s is a %v, not a function", name, t)
+	}
+	if val.IsNil() {
+		return nil, fmt.Errorf("glox: %s is a nil %v", name, t)
+	}
+
+	if t.IsVariadic() {
+		return nil, fmt.Errorf("glox: variadic function %s is not supported", name)
+	}
+
+	switch {
+	case t.NumOut() == 2 && t.Out(1) != errorType:
+		return nil, fmt.Errorf("glox: the second result of %s must be an error", name)
+	case t.NumOut() > 2:
+		return nil, fmt.Errorf("glox: %s returns more than two results", name)
+	}
+
+	native := &NativeFunction{Name: name, arity: uint32(t.NumIn())}
+	native.fn = func(interpreter *Interpreter, arguments []interface{}) (interface{}, error) {
+		in := make([]reflect.Value, len(arguments))
+		for idx, arg := range arguments {
+			converted, err := fromLox(arg, t.In(idx))
+			if err != nil {
+				return nil, fmt.Errorf("Argument %d of '%s' %s.", idx+1, name, err.Error())
+			}
+
+			in[idx] = converted
+		}
+
+		return toLoxResults(val.Call(in))
+	}
+
+	return native, nil
+}
+
+// fromLox converts a Lox value to a Go value of type t.
+func fromLox(arg interface{}, t reflect.Type) (reflect.Value, error) {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := integral(arg)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+
+		v := reflect.New(t).Elem()
+		if v.OverflowInt(i) {
+			return reflect.Value{}, errors.New("is out of range")
+		}
+
+		v.SetInt(i)
+		return v, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		i, err := integral(arg)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+
+		v := reflect.New(t).Elem()
+		if i < 0 || v.OverflowUint(uint64(i)) {
+			return reflect.Value{}, errors.New("is out of range")
+		}
+
+		v.SetUint(uint64(i))
+		return v, nil
+	case reflect.Float32, reflect.Float64:
+		if !isNumber(arg) {
+			return reflect.Value{}, errors.New("must be a number")
+		}
+
+		return reflect.ValueOf(toFloat(arg)).Convert(t), nil
+	case reflect.String:
+		if !isString(arg) {
+			return reflect.Value{}, errors.New("must be a string")
+		}
+
+		return reflect.ValueOf(arg).Convert(t), nil
+	case reflect.Bool:
+		if !isBool(arg) {
+			return reflect.Value{}, errors.New("must be a boolean")
+		}
+
+		return reflect.ValueOf(arg).Convert(t), nil
+	}
+
+	if arg == nil {
+		switch t.Kind() {
+		case reflect.Interface, reflect.Pointer, reflect.Map, reflect.Slice, reflect.Func:
+			return reflect.Zero(t), nil
+		}
+	} else if reflect.TypeOf(arg).AssignableTo(t) {
+		return reflect.ValueOf(arg), nil
+	}
+
+	return reflect.Value{}, fmt.Errorf("must be %v", t)
+}
+
+// toLoxResults converts the results of a wrapped function call to the
+// value and error returned by NativeFunction.Call.
+func toLoxResults(out []reflect.Value) (interface{}, error) {
+	switch len(out) {
+	case 0:
+		return nil, nil
+	case 1:
+		if out[0].Type() == errorType {
+			return nil, toError(out[0])
+		}
+
+		return toLox(out[0]), nil
+	}
+
+	if err := toError(out[1]); err != nil {
+		return nil, err
+	}
+
+	return toLox(out[0]), nil
+}
+
+func toError(v reflect.Value) error {
+	if v.IsNil() {
+		return nil
+	}
+
+	return v.Interface().(error)
+}
+
+// toLox converts a Go value to a Lox value. Slices, arrays and maps are
+// converted element by element, since Lox can't index or compare them.
+func toLox(v reflect.Value) interface{} {
+	switch v.Kind() {
+	case reflect.Invalid:
+		return nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if v.Uint() > math.MaxInt64 {
+			return float64(v.Uint())
+		}
+		return int64(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		return v.Float()
+	case reflect.String:
+		return v.String()
+	case reflect.Bool:
+		return v.Bool()
+	case reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+
+		return toLox(v.Elem())
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return nil
+		}
+
+		elements := make([]interface{}, v.Len())
+		for idx := range elements {
+			elements[idx] = toLox(v.Index(idx))
+		}
+		return NewLoxList(elements)
+	case reflect.Map:
+		if v.IsNil() {
+			return nil
+		}
+
+		return toLoxMap(v)
+	case reflect.Pointer, reflect.Func:
+		if v.IsNil() {
+			return nil
+		}
+	}
+
+	return v.Interface()
+}
+
+// toLoxMap converts a Go map to a LoxMap. Go maps have no order, so the
+// keys are sorted: numbers by value, before anything else by its printed
+// form.
+func toLoxMap(v reflect.Value) *LoxMap {
+	keys := make([]interface{}, 0, v.Len())
+	values := map[interface{}]interface{}{}
+	for iter := v.MapRange(); iter.Next(); {
+		key := toLox(iter.Key())
+		keys = append(keys, key)
+		values[key] = toLox(iter.Value())
+	}
+
+	sort.Slice(keys, func(a, b int) bool {
+		left, right := keys[a], keys[b]
+		switch {
+		case isNumber(left) && isNumber(right):
+			cmp, _ := compareNumbers(left, right)
+			return cmp < 0
+		case isNumber(left) || isNumber(right):
+			return isNumber(left)
+		}
+
+		return stringify(left) < stringify(right)
+	})
+
+	m := NewLoxMap()
+	for _, key := range keys {
+		m.Set(key, values[key])
+	}
+	return m
+}
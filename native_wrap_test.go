@@ -0,0 +1,109 @@
+package glox
+
+import (
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestWrapFuncRejectsNonFunctions(t *testing.T) {
+	var nilFunc func(int) int
+	for name, fn := range map[string]interface{}{
+		"nil":        nil,
+		"nil func":   nilFunc,
+		"number":     1,
+		"variadic":   func(...int) {},
+		"bad result": func() (int, int) { return 0, 0 },
+	} {
+		if _, err := WrapFunc(name, fn); err == nil {
+			t.Errorf("%s: got no error", name)
+		}
+	}
+}
+
+func TestDefineFunc(t *testing.T) {
+	vm := NewVM(nil)
+	if err := vm.DefineFunc("add", func(a int, b float64) float64 { return float64(a) + b }); err != nil {
+		t.Fatal(err)
+	}
+
+	val, err := vm.Eval("add(2, 0.5)")
+	if err != nil || val != 2.5 {
+		t.Errorf("got %v, %v, want 2.5", val, err)
+	}
+
+	if err := vm.DefineFunc("n", nil); err == nil {
+		t.Error("DefineFunc with nil: got no error")
+	}
+}
+
+func TestWrapFuncConvertsCollections(t *testing.T) {
+	vm := NewVM(nil)
+	vm.DefineFunc("squares", func(n int) []int {
+		squares := make([]int, n)
+		for i := range squares {
+			squares[i] = i * i
+		}
+		return squares
+	})
+	vm.DefineFunc("counts", func() map[string][]int { return map[string][]int{"b": {2}, "a": {1, 1}} })
+
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{"squares(3)", "[0, 1, 4]"},
+		{"squares(3)[2] + 1", "5"},
+		{"squares(2) == squares(2)", "false"},
+		{"counts()", `{"a": [1, 1], "b": [2]}`},
+		{`counts()["b"].len()`, "1"},
+	}
+
+	for _, test := range tests {
+		val, err := vm.Eval(test.source)
+		if err != nil || stringify(val) != test.want {
+			t.Errorf("%s: got %v, %v, want %s", test.source, val, err, test.want)
+		}
+	}
+}
+
+type point struct {
+	coords []float64
+}
+
+func TestUncomparableGlobals(t *testing.T) {
+	for _, backend := range backends {
+		vm := NewVM(&Options{
+			Backend: backend.backend,
+			Stderr:  io.Discard,
+			Globals: map[string]Value{
+				"list":  []string{"x"},
+				"table": map[int]bool{2: true, 1: false},
+				"fn":    func() {},
+				"point": point{coords: []float64{1, 2}},
+			},
+		})
+
+		for source, want := range map[string]string{
+			"list":                  `["x"]`,
+			"table":                 "{1: false, 2: true}",
+			"fn == fn":              "false",
+			"point == point":        "false",
+			"point != nil":          "true",
+			"{1: 2}.has(point)":     "false",
+			`{"k": fn}["k"] == nil`: "false",
+		} {
+			val, err := vm.Eval(source)
+			if err != nil || stringify(val) != want {
+				t.Errorf("%s: %s: got %v, %v, want %s", backend.name, source, val, err, want)
+			}
+		}
+
+		for _, source := range []string{"var m = {fn: 1};", "var m = {}; m[point] = 1;", "({})[fn];"} {
+			var runtimeErr *RuntimeError
+			if _, err := vm.Eval(source); !errors.As(err, &runtimeErr) {
+				t.Errorf("%s: %s: got %v, want a runtime error", backend.name, source, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,227 @@
+package glox
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Lox has two kinds of numbers. Integer literals are int64 and so are the
+// results of +, -, *, ~/ and unary - on integers, as long as they fit; any
+// other number is a float64. An operation with a float64 operand gives a
+// float64, and / always does. Integers and floats with the same value are
+// equal.
+
+func isNumber(v interface{}) bool {
+	switch v.(type) {
+	case int64, float64:
+		return true
+	}
+
+	return false
+}
+
+func isInt64(v interface{}) bool {
+	_, ok := v.(int64)
+	return ok
+}
+
+// toFloat returns the number v as a float64.
+func toFloat(v interface{}) float64 {
+	if i, ok := v.(int64); ok {
+		return float64(i)
+	}
+
+	return v.(float64)
+}
+
+func addNumbers(left interface{}, right interface{}) interface{} {
+	if a, ok := left.(int64); ok {
+		if b, ok := right.(int64); ok {
+			if sum := a + b; (a >= 0) != (b >= 0) || (sum >= 0) == (a >= 0) {
+				return sum
+			}
+		}
+	}
+
+	return toFloat(left) + toFloat(right)
+}
+
+func subtractNumbers(left interface{}, right interface{}) interface{} {
+	if a, ok := left.(int64); ok {
+		if b, ok := right.(int64); ok {
+			if diff := a - b; (a >= 0) == (b >= 0) || (diff >= 0) == (a >= 0) {
+				return diff
+			}
+		}
+	}
+
+	return toFloat(left) - toFloat(right)
+}
+
+func multiplyNumbers(left interface{}, right interface{}) interface{} {
+	if a, ok := left.(int64); ok {
+		if b, ok := right.(int64); ok {
+			if a == 0 || b == 0 {
+				return int64(0)
+			}
+
+			product := a * b
+			if product/b == a && !(a == -1 && b == math.MinInt64) && !(b == -1 && a == math.MinInt64) {
+				return product
+			}
+		}
+	}
+
+	return toFloat(left) * toFloat(right)
+}
+
+// floorDivideNumbers implements ~/, the division rounded down to an
+// integral number: 7 ~/ 2 is 3 and -7 ~/ 2 is -4. Two integers give an
+// integer, anything else a float. The operator isn't spelled // as in Python
+// because // already starts a comment, and ~/ (from Dart) is a token no
+// valid program used before. The divisor must not be zero.
+func floorDivideNumbers(left interface{}, right interface{}) interface{} {
+	if a, ok := left.(int64); ok {
+		if b, ok := right.(int64); ok && !(a == math.MinInt64 && b == -1) {
+			quotient := a / b
+			if a%b != 0 && (a < 0) != (b < 0) {
+				quotient--
+			}
+			return quotient
+		}
+	}
+
+	return math.Floor(toFloat(left) / toFloat(right))
+}
+
+func negateNumber(v interface{}) interface{} {
+	if i, ok := v.(int64); ok && i != math.MinInt64 {
+		return -i
+	}
+
+	return -toFloat(v)
+}
+
+// compareNumbers returns -1, 0 or 1 as left is less than, equal to or
+// greater than right. ok is false if a NaN makes them unordered.
+func compareNumbers(left interface{}, right interface{}) (cmp int, ok bool) {
+	if a, isInt := left.(int64); isInt {
+		if b, isInt := right.(int64); isInt {
+			switch {
+			case a < b:
+				return -1, true
+			case a > b:
+				return 1, true
+			}
+			return 0, true
+		}
+	}
+
+	a, b := toFloat(left), toFloat(right)
+	switch {
+	case a < b:
+		return -1, true
+	case a > b:
+		return 1, true
+	case a == b:
+		return 0, true
+	}
+
+	return 0, false
+}
+
+// valuesEqual implements ==. Numbers are equal if they have the same
+// value, whatever their kind. A value that Go can't compare is not equal
+// to anything.
+func valuesEqual(left interface{}, right interface{}) bool {
+	if isNumber(left) && isNumber(right) {
+		cmp, ok := compareNumbers(left, right)
+		return ok && cmp == 0
+	}
+
+	if !isComparable(left) || !isComparable(right) {
+		return false
+	}
+
+	return left == right
+}
+
+// mapKey returns the key a value is stored under in a map, so that 1 and
+// 1.0 are the same key. It reports false for a value that can't be a key.
+func mapKey(v interface{}) (interface{}, bool) {
+	if f, ok := v.(float64); ok && f == math.Trunc(f) && f >= math.MinInt64 && f < math.MaxInt64 {
+		return int64(f), true
+	}
+
+	return v, isComparable(v)
+}
+
+// isComparable reports whether Go can compare v with ==. Lox values can
+// always be compared, but the Go values an embedder passes in, such as a
+// function or a struct holding a slice, may not.
+func isComparable(v interface{}) bool {
+	switch v.(type) {
+	case nil, bool, string, int64, float64:
+		return true
+	}
+
+	return reflect.ValueOf(v).Comparable()
+}
+
+// formatNumber formats a number so that it reads back as the same value.
+// Integral floats are printed without a decimal point, like integers, and
+// very large or small ones with an exponent.
+func formatNumber(v interface{}) string {
+	if i, ok := v.(int64); ok {
+		return strconv.FormatInt(i, 10)
+	}
+
+	f := v.(float64)
+	switch {
+	case math.IsNaN(f):
+		return "NaN"
+	case math.IsInf(f, 1):
+		return "Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	case f == math.Trunc(f) && math.Abs(f) < 1e21:
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+
+	text := strconv.FormatFloat(f, 'g', -1, 64)
+	mantissa, exponent, found := strings.Cut(text, "e")
+	if !found {
+		return text
+	}
+
+	// 1e+300 reads better as 1e300, and 1e-07 as 1e-7.
+	sign := ""
+	if exponent[0] == '-' {
+		sign = "-"
+	}
+	return mantissa + "e" + sign + strings.TrimLeft(exponent[1:], "0")
+}
+
+// integral returns arg as an int64 if it is a number without a fractional
+// part.
+func integral(arg interface{}) (int64, error) {
+	if i, ok := arg.(int64); ok {
+		return i, nil
+	}
+
+	f, ok := arg.(float64)
+	if !ok {
+		return 0, errors.New("must be a number")
+	}
+	if f != math.Trunc(f) {
+		return 0, errors.New("must be an integer")
+	}
+	if f < math.MinInt64 || f >= math.MaxInt64 {
+		return 0, errors.New("is out of range")
+	}
+
+	return int64(f), nil
+}
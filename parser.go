@@ -1,5 +1,11 @@
 package glox
 
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
 type Parser struct {
 	tokens  []Token
 	current uint32
@@ -15,6 +21,13 @@ type Parser struct {
 	// disableCommaExpr is used to avoid conflicts between comma expressions
 	// and parameter lists.
 	disableCommaExpr	bool
+
+	// dir is the directory of the file being parsed. Import statements
+	// record it to resolve relative paths.
+	dir string
+
+	// synchronized is set when the parser skips tokens after an error.
+	synchronized bool
 }
 
 func NewParser(tokens []Token, errorPrinter *ErrorPrinter) *Parser {
@@ -44,6 +57,28 @@ func (p *Parser) Parse() []Stmt {
 	return statements
 }
 
+// ParseAll is like Parse, but instead of giving up at the first error it
+// skips to the next statement and keeps going. It returns every statement
+// that parsed, for tools that analyze source with errors in it.
+func (p *Parser) ParseAll() []Stmt {
+	statements := []Stmt{}
+
+	for !p.isAtEnd() {
+		p.synchronized = false
+		statement, err := p.declaration()
+		if err != nil {
+			if !p.synchronized {
+				p.synchronize()
+			}
+			continue
+		}
+
+		statements = append(statements, statement)
+	}
+
+	return statements
+}
+
 // ParseREPL adds support for REPL to let users type in both statements and expressions.
 func  (p *Parser) ParseREPL() interface{} {
 	p.allowExpression = true
@@ -73,8 +108,43 @@ func  (p *Parser) ParseREPL() interface{} {
 // declaration -> classDecl
 //				| funDecl
 //				| varDecl
+//				| importDecl
+//				| exportDecl
 //				| statement
 func (p *Parser) declaration() (Stmt, error) {
+	if p.match(IMPORT) {
+		importDecl, err := p.importDeclaration()
+		if err != nil {
+			p.synchronize()
+			return nil, err
+		}
+
+		return importDecl, nil
+	}
+
+	// "from" is only a keyword in front of the path of an import.
+	if p.check(IDENTIFIER) && p.peek().Lexeme == "from" && p.checkNext(STRING) {
+		p.advance()
+
+		importDecl, err := p.fromImportDeclaration()
+		if err != nil {
+			p.synchronize()
+			return nil, err
+		}
+
+		return importDecl, nil
+	}
+
+	if p.match(EXPORT) {
+		exportDecl, err := p.exportDeclaration()
+		if err != nil {
+			p.synchronize()
+			return nil, err
+		}
+
+		return exportDecl, nil
+	}
+
 	if p.match(CLASS) {
 		classDecl, err := p.classDeclaration()
 		if err != nil {
@@ -109,6 +179,81 @@ func (p *Parser) declaration() (Stmt, error) {
 	return p.statement()
 }
 
+// importDecl -> "import" STRING "as" IDENTIFIER ";"
+func (p *Parser) importDeclaration() (Stmt, error) {
+	keyword := p.previous()
+
+	path, err := p.consume(STRING, "Expect module path after 'import'.")
+	if err != nil {
+		return nil, err
+	}
+
+	// like "from", "as" is not a reserved word.
+	if !p.check(IDENTIFIER) || p.peek().Lexeme != "as" {
+		return nil, p.error(p.peek(), "Expect 'as' after module path.")
+	}
+	p.advance()
+
+	alias, err := p.consume(IDENTIFIER, "Expect module name after 'as'.")
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := p.consume(SEMICOLON, "Expect ';' after import."); err != nil {
+		return nil, err
+	}
+
+	return &Import{Keyword: &keyword, Path: &path, Alias: &alias, Dir: p.dir}, nil
+}
+
+// fromImportDecl -> "from" STRING "import" IDENTIFIER ( "," IDENTIFIER )* ";"
+func (p *Parser) fromImportDeclaration() (Stmt, error) {
+	path, err := p.consume(STRING, "Expect module path after 'from'.")
+	if err != nil {
+		return nil, err
+	}
+
+	keyword, err := p.consume(IMPORT, "Expect 'import' after module path.")
+	if err != nil {
+		return nil, err
+	}
+
+	names := []*Token{}
+	for {
+		name, err := p.consume(IDENTIFIER, "Expect name to import.")
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, &name)
+
+		if !p.match(COMMA) {
+			break
+		}
+	}
+
+	if _, err := p.consume(SEMICOLON, "Expect ';' after import."); err != nil {
+		return nil, err
+	}
+
+	return &Import{Keyword: &keyword, Path: &path, Names: names, Dir: p.dir}, nil
+}
+
+// exportDecl -> "export" ( classDecl | funDecl | varDecl )
+func (p *Parser) exportDeclaration() (Stmt, error) {
+	keyword := p.previous()
+
+	if !p.check(CLASS) && !p.check(VAR) && !(p.check(FUN) && p.checkNext(IDENTIFIER)) {
+		return nil, p.error(p.peek(), "Expect class, function or variable declaration after 'export'.")
+	}
+
+	declaration, err := p.declaration()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Export{Keyword: &keyword, Declaration: declaration}, nil
+}
+
 // classDecl -> "class" IDENTIFIER ( "<" IDENTIFIER )?
 //				"{" function* "}"
 // Like most dynamically typed languages, fields are not explicitly listed
@@ -247,12 +392,20 @@ func (p *Parser) varDeclaration() (Stmt, error) {
 //			  | breakStmt
 //			  | returnStmt
 //			  | printStmt
+//			  | tryStmt
+//			  | throwStmt
 //			  | block
 func (p *Parser) statement() (Stmt, error) {
 	if p.match(PRINT) {
 		return p.printStatement()
 	}
 
+	// both blocks and map literals start with '{', an expression statement
+	// like {"a": 1}["a"]; is parsed as an expression.
+	if p.check(LEFT_BRACE) && p.isMapLiteral() {
+		return p.expressionStatement()
+	}
+
 	if p.match(LEFT_BRACE) {
 		stmts, err := p.block()
 		if err != nil {
@@ -281,6 +434,14 @@ func (p *Parser) statement() (Stmt, error) {
 	if p.match(RETURN) {
 		return p.returnStatement()
 	}
+
+	if p.match(TRY) {
+		return p.tryStatement()
+	}
+
+	if p.match(THROW) {
+		return p.throwStatement()
+	}
 	
 	return p.expressionStatement()
 }
@@ -305,23 +466,99 @@ func (p *Parser) returnStatement() (Stmt, error) {
 	return &Return{Keyword: &keyword, Value: value}, nil
 }
 
+// tryStmt -> "try" block ( "catch" "(" IDENTIFIER ")" block )?
+//			  ( "finally" block )?
+// At least one of the catch and finally clauses must be present.
+func (p *Parser) tryStatement() (Stmt, error) {
+	keyword := p.previous()
+
+	if _, err := p.consume(LEFT_BRACE, "Expect '{' after 'try'."); err != nil {
+		return nil, err
+	}
+
+	body, err := p.block()
+	if err != nil {
+		return nil, err
+	}
+
+	stmt := &Try{Keyword: &keyword, Body: body}
+
+	if p.match(CATCH) {
+		if _, err := p.consume(LEFT_PAREN, "Expect '(' after 'catch'."); err != nil {
+			return nil, err
+		}
+
+		name, err := p.consume(IDENTIFIER, "Expect error variable name.")
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err := p.consume(RIGHT_PAREN, "Expect ')' after error variable."); err != nil {
+			return nil, err
+		}
+
+		if _, err := p.consume(LEFT_BRACE, "Expect '{' before catch body."); err != nil {
+			return nil, err
+		}
+
+		stmt.CatchName = &name
+		if stmt.CatchBody, err = p.block(); err != nil {
+			return nil, err
+		}
+	}
+
+	if p.match(FINALLY) {
+		if _, err := p.consume(LEFT_BRACE, "Expect '{' after 'finally'."); err != nil {
+			return nil, err
+		}
+
+		if stmt.FinallyBody, err = p.block(); err != nil {
+			return nil, err
+		}
+	}
+
+	if stmt.CatchName == nil && stmt.FinallyBody == nil {
+		return nil, p.error(p.peek(), "Expect 'catch' or 'finally' after try block.")
+	}
+
+	return stmt, nil
+}
+
+// throwStmt -> "throw" expression ";"
+func (p *Parser) throwStatement() (Stmt, error) {
+	keyword := p.previous()
+
+	value, err := p.expression()
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := p.consume(SEMICOLON, "Expect ';' after thrown value."); err != nil {
+		return nil, err
+	}
+
+	return &Throw{Keyword: &keyword, Value: value}, nil
+}
+
 // breakStmt -> "break" ";"
 func (p *Parser) breakStatement() (Stmt, error) {
+	keyword := p.previous()
 	if p.loopDepth == 0 {
-		return nil, p.error(p.previous(), "Must be inside a loop to use 'break'.")
+		return nil, p.error(keyword, "Must be inside a loop to use 'break'.")
 	}
 
 	if _, err := p.consume(SEMICOLON, "Expect ';' after 'break'."); err != nil {
 		return nil, err
 	}
 
-	return &Break{}, nil
+	return &Break{Keyword: &keyword}, nil
 }
 
 // forStmt -> "for" "(" ( varDecl | exprStmt | ";" )
 //			  expression? ";"
 //			  expression? ")" statement
 func (p *Parser) forStatement() (Stmt, error) {
+	keyword := p.previous()
 	if _, err := p.consume(LEFT_PAREN, "Expect '(' after 'for'."); err != nil {
 		return nil, err
 	}
@@ -384,7 +621,7 @@ func (p *Parser) forStatement() (Stmt, error) {
 		condition = &Literal{Value: true}
 	}
 	// transform to while statement.
-	body = &While{Condition: condition, Body: body}
+	body = &While{Keyword: &keyword, Condition: condition, Body: body}
 
 	// if initializer is not empty, wrap it by a block statement and make sure it will be excuted earlier than loop-body.
 	if (initializer != nil) {
@@ -397,6 +634,7 @@ func (p *Parser) forStatement() (Stmt, error) {
 
 // whileStmt -> "while" "(" expression ")" statement
 func (p *Parser) whileStatement() (Stmt, error) {
+	keyword := p.previous()
 	if _, err := p.consume(LEFT_PAREN, "Expect '(' after 'while'."); err != nil {
 		return nil, err
 	}
@@ -418,12 +656,13 @@ func (p *Parser) whileStatement() (Stmt, error) {
 	}
 
 	p.loopDepth--
-	return &While{Condition: condition, Body: body}, nil
+	return &While{Keyword: &keyword, Condition: condition, Body: body}, nil
 }
 
 // ifStmt -> "if" "(" expression ")" statement
 //		   ( "else" statement )?
 func (p *Parser) ifStatement() (Stmt, error) {
+	keyword := p.previous()
 	if _, err := p.consume(LEFT_PAREN, "Expect '(' after 'if'."); err != nil {
 		return nil, err
 	}
@@ -450,7 +689,7 @@ func (p *Parser) ifStatement() (Stmt, error) {
 		}
 	}
 
-	return &If{Condition: condition, ThenBranch: thenBranch, ElseBranch: elseBranch}, nil
+	return &If{Keyword: &keyword, Condition: condition, ThenBranch: thenBranch, ElseBranch: elseBranch}, nil
 }
 
 // block -> "{" declaration* "}"
@@ -494,6 +733,7 @@ func (p *Parser) expressionStatement() (Stmt, error) {
 
 // printStmt -> "print" expression ";"
 func (p *Parser) printStatement() (Stmt, error) {
+	keyword := p.previous()
 	val, err := p.expression()
 	if err != nil {
 		return nil, err
@@ -504,7 +744,7 @@ func (p *Parser) printStatement() (Stmt, error) {
 		return nil, err
 	}
 
-	return &Print{Expression: val}, nil
+	return &Print{Keyword: &keyword, Expression: val}, nil
 }
 
 // expression -> assignment
@@ -513,6 +753,7 @@ func (p *Parser) expression() (Expr, error) {
 }
 
 // assignment -> ( call "." )? IDENTIFIER "=" assignment
+//			   | call "[" expression "]" "=" assignment
 //			   | comma
 func (p *Parser) assignment() (Expr, error) {
 	expr, err := p.comma()
@@ -539,6 +780,9 @@ func (p *Parser) assignment() (Expr, error) {
 			// add another clause to that transformation to handle turning
 			// an Get expression on the left into the corresponding Set.
 			return &Set{Object: get.Object, Name: get.Name, Value: val}, nil
+		} else if index, isIndex := expr.(*Index); isIndex {
+			// xs[i] = v is turned into a SetIndex the same way.
+			return &SetIndex{Object: index.Object, Bracket: index.Bracket, Index: index.Index, Value: val}, nil
 		} else {
 			return nil, p.error(equals, "Invalid assignment target.")
 		}
@@ -698,14 +942,14 @@ func (p *Parser) term() (Expr, error) {
 	return expr, nil
 }
 
-// factor -> unary ( ( "/" | "*" ) unary )*
+// factor -> unary ( ( "/" | "*" | "~/" ) unary )*
 func (p *Parser) factor() (Expr, error) {
 	expr, err := p.unary()
 	if err != nil {
 		return nil, err
 	}
 
-	for p.match(SLASH, STAR) {
+	for p.match(SLASH, STAR, TILDE_SLASH) {
 		operator := p.previous()
 		right, err := p.unary()
 		if err != nil {
@@ -734,7 +978,7 @@ func (p *Parser) unary() (Expr, error) {
 	return p.call()
 }
 
-// call -> primary ( "(" arguments? ")" | "." IDENTIFIER )*
+// call -> primary ( "(" arguments? ")" | "." IDENTIFIER | "[" expression "]" )*
 func (p *Parser) call() (Expr, error) {
 	expr, err := p.primary()
 	if err != nil {
@@ -763,6 +1007,18 @@ func (p *Parser) call() (Expr, error) {
 			}
 
 			expr = &Get{Object: expr, Name: &name}
+		} else if p.match(LEFT_BRACKET) {
+			bracket := p.previous()
+			index, err := p.expression()
+			if err != nil {
+				return nil, err
+			}
+
+			if _, err := p.consume(RIGHT_BRACKET, "Expect ']' after index."); err != nil {
+				return nil, err
+			}
+
+			expr = &Index{Object: expr, Bracket: &bracket, Index: index}
 		} else {
 			break
 		}
@@ -774,49 +1030,99 @@ func (p *Parser) call() (Expr, error) {
 // arguments -> expression ( "," expression )*
 // finishCall parses the argument list of the function call.
 func (p *Parser) finishCall(callee Expr) (Expr, error) {
-	arguments :=  []Expr{}
+	// check if the call has arguments or not.
+	// the next token is ')' in the zero-argument case.
+	arguments, err := p.expressionList(RIGHT_PAREN, 255)
+	if err != nil {
+		return nil, err
+	}
+
+	paren, err := p.consume(RIGHT_PAREN, "Expect ')' after arguments.")
+	if err != nil {
+		return nil, err
+	}
 
+	return &Call{Callee: callee, Paren: &paren, Arguments: arguments}, nil
+}
+
+// expressionList parses comma separated expressions up to, but not
+// including, the closing token. It is shared by argument lists and list
+// literals, where the commas separate the elements instead of forming
+// comma expressions. A positive limit caps the number of expressions, as
+// for the arguments of a call; list literals have no limit.
+func (p *Parser) expressionList(closing TokenType, limit int) ([]Expr, error) {
+	exprs := []Expr{}
+
+	// restore the previous setting when done, since lists nest: f(g(1), 2)
+	enclosingDisable := p.disableCommaExpr
 	p.disableCommaExpr = true
+	defer func() {
+		p.disableCommaExpr = enclosingDisable
+	}()
 
-	// check if the call has arguments or not.
-	// the next token is ')' in the zero-argument case.
-	if !p.check(RIGHT_PAREN) {
-		for {
-			// limit the number of arguments.
-			if len(arguments) >= 255 {
-				return nil, p.error(p.peek(), "Can't have more than 255 arguments.")
-			}
+	if p.check(closing) {
+		return exprs, nil
+	}
 
-			arg, err := p.expression()
-			if err != nil {
-				return nil, err
-			}
+	for {
+		if limit > 0 && len(exprs) >= limit {
+			return nil, p.error(p.peek(), fmt.Sprintf("Can't have more than %d arguments.", limit))
+		}
 
-			arguments = append(arguments, arg)
+		expr, err := p.expression()
+		if err != nil {
+			return nil, err
+		}
 
-			if !p.match(COMMA) {
-				break
-			}
+		exprs = append(exprs, expr)
+
+		if !p.match(COMMA) {
+			return exprs, nil
 		}
 	}
+}
 
-	paren, err := p.consume(RIGHT_PAREN, "Expect ')' after arguments.")
-	if err != nil {
-		return nil, err
+// isMapLiteral reports whether the '{' at the current token opens a map
+// literal rather than a block. It looks for a ':' before the first ';', '{'
+// or '}' outside of any parentheses and brackets. A '?' on the way means the
+// ':' belongs to a conditional expression inside a block. An empty '{}' is a
+// block.
+func (p *Parser) isMapLiteral() bool {
+	depth := 0
+	for idx := p.current + 1; idx < uint32(len(p.tokens)); idx++ {
+		switch p.tokens[idx].Type {
+		case LEFT_PAREN, LEFT_BRACKET:
+			depth++
+		case RIGHT_PAREN, RIGHT_BRACKET:
+			depth--
+		case COLON:
+			if depth == 0 {
+				return true
+			}
+		case QUESTION_MARK:
+			if depth == 0 {
+				return false
+			}
+		case SEMICOLON, LEFT_BRACE, RIGHT_BRACE, EOF:
+			return false
+		}
 	}
 
-	p.disableCommaExpr = false
-
-	return &Call{Callee: callee, Paren: &paren, Arguments: arguments}, nil
+	return false
 }
 
-// primary -> NUMBER | STRING | "true" | "false" | "nil"
+// primary -> NUMBER | STRING | interpolation | "true" | "false" | "nil"
 //			| "this"
 //			| "super" "." IDENTIFIER
 //			| IDENTIFIER
 // 			| "(" expression ")"
+//			| "[" ( expression ( "," expression )* )? "]"
+//			| "{" ( expression ":" expression ( "," expression ":" expression )* )? "}"
 func (p *Parser) primary() (Expr, error) {
 	switch {
+	case p.checkContinuation():
+		// the '}' of an interpolation, where an expression is missing.
+		return nil, p.error(p.peek(), "Expect expression.")
 	case p.match(FALSE):
 		return &Literal{Value: false}, nil
 	case p.match(TRUE):
@@ -825,6 +1131,8 @@ func (p *Parser) primary() (Expr, error) {
 		return &Literal{Value: nil}, nil
 	case p.match(NUMBER, STRING):
 		return &Literal{Value: p.previous().Literal}, nil
+	case p.match(INTERPOLATION):
+		return p.interpolation()
 	case p.match(IDENTIFIER):
 		ident := p.previous()
 		return &Variable{Name: &ident}, nil
@@ -839,6 +1147,20 @@ func (p *Parser) primary() (Expr, error) {
 		}
 
 		return &Grouping{Expression: expr}, nil
+	case p.match(LEFT_BRACKET):
+		bracket := p.previous()
+		elements, err := p.expressionList(RIGHT_BRACKET, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err := p.consume(RIGHT_BRACKET, "Expect ']' after list elements."); err != nil {
+			return nil, err
+		}
+
+		return &ListLiteral{Bracket: &bracket, Elements: elements}, nil
+	case p.match(LEFT_BRACE):
+		return p.mapLiteral()
 	case p.match(FUN):
 		fn, err := p.functionBody("function")
 		if err != nil {
@@ -867,6 +1189,113 @@ func (p *Parser) primary() (Expr, error) {
 	return nil, p.error(p.peek(), "Expect expression.")
 }
 
+// interpolation parses a string with interpolated expressions, after its
+// first part, and lowers it to the concatenation of its parts. Each
+// expression is turned into a string the way print shows it, and the first
+// part is kept even if it is empty, so that the result is always a string.
+//
+// interpolation -> INTERPOLATION expression ( INTERPOLATION expression )* STRING
+func (p *Parser) interpolation() (Expr, error) {
+	part := p.previous()
+	var expr Expr = &Literal{Value: part.Literal}
+	for {
+		operand, err := p.expression()
+		if err != nil {
+			return nil, err
+		}
+		expr = &Binary{Left: expr, Operator: interpolationOperator(part, true), Right: &Interpolated{Expression: operand}}
+
+		if !p.checkContinuation() {
+			return nil, p.error(p.peek(), "Expect '}' after interpolated expression.")
+		}
+
+		part = p.advance()
+		if part.Literal != "" {
+			expr = &Binary{Left: expr, Operator: interpolationOperator(part, false), Right: &Literal{Value: part.Literal}}
+		}
+
+		if part.Type == STRING {
+			return expr, nil
+		}
+	}
+}
+
+// checkContinuation reports whether the current token is the part of a
+// string after an interpolated expression, which starts with the '}' that
+// ends the expression.
+func (p *Parser) checkContinuation() bool {
+	return (p.check(INTERPOLATION) || p.check(STRING)) && strings.HasPrefix(p.peek().Lexeme, "}")
+}
+
+// interpolationOperator returns the + that concatenates the string part
+// with the expression after it, at the "${" that ends part, or with the
+// expression before it, at the '}' that starts part.
+func interpolationOperator(part Token, after bool) *Token {
+	operator := part
+	operator.Type, operator.Lexeme, operator.Literal = PLUS, "+", nil
+	if !after {
+		operator.Length = 1
+		return &operator
+	}
+
+	// locate the "${" at the end of the lexeme, which may span lines.
+	before := part.Lexeme[:len(part.Lexeme)-2]
+	operator.Offset += uint32(len(before))
+	operator.Length = 2
+	if newline := strings.LastIndexByte(before, '\n'); newline >= 0 {
+		operator.Line += uint32(strings.Count(before, "\n"))
+		operator.Column = uint32(utf8.RuneCountInString(before[newline+1:])) + 1
+	} else {
+		operator.Column += uint32(utf8.RuneCountInString(before))
+	}
+
+	return &operator
+}
+
+// mapLiteral parses the entries of a map literal after the opening '{'.
+func (p *Parser) mapLiteral() (Expr, error) {
+	brace := p.previous()
+	keys, values := []Expr{}, []Expr{}
+
+	// like in list literals, the commas separate the entries.
+	enclosingDisable := p.disableCommaExpr
+	p.disableCommaExpr = true
+	defer func() {
+		p.disableCommaExpr = enclosingDisable
+	}()
+
+	if !p.check(RIGHT_BRACE) {
+		for {
+			key, err := p.expression()
+			if err != nil {
+				return nil, err
+			}
+
+			if _, err := p.consume(COLON, "Expect ':' after map key."); err != nil {
+				return nil, err
+			}
+
+			value, err := p.expression()
+			if err != nil {
+				return nil, err
+			}
+
+			keys = append(keys, key)
+			values = append(values, value)
+
+			if !p.match(COMMA) {
+				break
+			}
+		}
+	}
+
+	if _, err := p.consume(RIGHT_BRACE, "Expect '}' after map entries."); err != nil {
+		return nil, err
+	}
+
+	return &MapLiteral{Brace: &brace, Keys: keys, Values: values}, nil
+}
+
 // match checks if the current token matches any of the given token types.
 // If a match is found, it advances the parser and returns true.
 func (p *Parser) match(types ...TokenType) bool {
@@ -947,6 +1376,7 @@ func (p *Parser) error(token Token, message string) error {
 // A statement usually ends with a semicolon, and the next statement immediately
 // after it begins with a key word like "for", "if", "var", "return" etc.
 func (p *Parser) synchronize() {
+	p.synchronized = true
 	p.advance()
 
 	for !p.isAtEnd() {
@@ -955,7 +1385,7 @@ func (p *Parser) synchronize() {
 		}
 
 		switch p.peek().Type {
-		case CLASS, FUN, VAR, FOR, IF, WHILE, PRINT, RETURN:
+		case CLASS, FUN, VAR, FOR, IF, WHILE, PRINT, RETURN, TRY, THROW, IMPORT, EXPORT:
 			return
 		}
 
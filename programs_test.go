@@ -0,0 +1,94 @@
+package glox
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the expected output of the test programs from the TreeWalk backend")
+
+var backends = []struct {
+	name    string
+	backend Backend
+}{
+	{"tree", TreeWalk},
+	{"bytecode", Bytecode},
+}
+
+// TestPrograms runs every program of testdata/programs on both backends.
+// The output of name.lox must match name.out and its diagnostics name.err;
+// a missing file stands for no output.
+func TestPrograms(t *testing.T) {
+	paths, err := filepath.Glob(filepath.Join("testdata", "programs", "*.lox"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) == 0 {
+		t.Fatal("no test programs found")
+	}
+
+	for _, path := range paths {
+		base := strings.TrimSuffix(path, ".lox")
+
+		for _, backend := range backends {
+			path, backend := path, backend
+			t.Run(filepath.Base(base)+"/"+backend.name, func(t *testing.T) {
+				var stdout, stderr bytes.Buffer
+				vm := NewVM(&Options{
+					Stdout:  &stdout,
+					Stderr:  &stderr,
+					Backend: backend.backend,
+					Seed:    1,
+				})
+				vm.RunFile(path)
+
+				if *update && backend.backend == TreeWalk {
+					writeExpected(t, base+".out", stdout.String())
+					writeExpected(t, base+".err", stderr.String())
+				}
+
+				if want := readExpected(t, base+".out"); stdout.String() != want {
+					t.Errorf("stdout:\n%s\nwant:\n%s", stdout.String(), want)
+				}
+				if want := readExpected(t, base+".err"); stderr.String() != want {
+					t.Errorf("stderr:\n%s\nwant:\n%s", stderr.String(), want)
+				}
+			})
+		}
+	}
+}
+
+func readExpected(t *testing.T, path string) string {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return ""
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(data)
+}
+
+// writeExpected writes the expected output to path, or removes the file if
+// there is none.
+func writeExpected(t *testing.T, path string, output string) {
+	var err error
+	if output == "" {
+		err = os.Remove(path)
+		if errors.Is(err, os.ErrNotExist) {
+			err = nil
+		}
+	} else {
+		err = os.WriteFile(path, []byte(output), 0644)
+	}
+
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,362 @@
+package glox
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	replPrompt         = "> "
+	continuationPrompt = "... "
+)
+
+const replHelp = `Commands:
+  :help               print this help
+  :env                list the globals and their values
+  :type EXPR          print the type of the value of EXPR
+  :ast CODE           print the syntax tree of CODE
+  :tokens CODE        print the tokens of CODE
+  :load FILE          run the script FILE in the session
+  :save FILE          write the inputs that ran without errors to FILE
+  :reset              start a new session with a fresh interpreter
+  :time CODE          run CODE and print how long it took
+Any other input is run as Lox code.`
+
+// replCommands are the names of the commands, for completion.
+var replCommands = []string{"help", "env", "type", "ast", "tokens", "load", "save", "reset", "time"}
+
+// replSession is the state the REPL keeps from one input to the next.
+type replSession struct {
+	glox *Glox
+	out  io.Writer
+
+	// resolver sees every input, as if they were a single script.
+	resolver *Resolver
+
+	// inputs holds the code that ran without errors, for :save.
+	inputs []string
+}
+
+// runPrompt reads lines from in and writes the prompt and the value of the
+// expressions to out. The output of print statements still goes to the
+// VM's Stdout. Input that is not complete, such as a function whose body
+// is not closed yet, is continued on the next lines. On a terminal the
+// lines can be edited, and they are kept in the history file.
+func (g *Glox) runPrompt(in io.Reader, out io.Writer) {
+	session := &replSession{glox: g, out: out}
+	session.reset()
+
+	var reader lineReader = &plainReader{in: bufio.NewScanner(in), out: out}
+	if file, ok := in.(*os.File); ok && isTerminal(file.Fd()) {
+		editor := newLineEditor(file, out, historyPath())
+		editor.complete = g.complete
+		reader = editor
+	}
+
+	pending := ""
+	for {
+		prompt := replPrompt
+		if pending != "" {
+			prompt = continuationPrompt
+		}
+
+		line, err := reader.readLine(prompt)
+		if err == errInterrupted {
+			pending = ""
+			continue
+		}
+		if err != nil {
+			// run what is left, so that its errors are reported.
+			if pending != "" {
+				session.input(pending)
+			}
+			break
+		}
+
+		source := pending + line
+		if isIncomplete(source) {
+			pending = source + "\n"
+			continue
+		}
+		pending = ""
+
+		session.input(source)
+	}
+}
+
+// input runs a command or some code entered at the prompt.
+func (rs *replSession) input(source string) {
+	trimmed := strings.TrimSpace(source)
+	if !strings.HasPrefix(trimmed, ":") {
+		rs.run(source)
+		return
+	}
+
+	name, arg, _ := strings.Cut(trimmed[1:], " ")
+	rs.command(name, strings.TrimSpace(arg))
+}
+
+// run runs code. If they enter a statement, execute it. And if they enter
+// an expression, evaluate it and display the result value.
+func (rs *replSession) run(source string) {
+	result, isExpression, err := rs.glox.vm.evalLine(source, rs.resolver)
+	if err != nil {
+		return
+	}
+
+	if isExpression {
+		fmt.Fprintln(rs.out, "=", stringify(result))
+
+		// the saved session has to be a valid script.
+		if !strings.HasSuffix(strings.TrimSpace(source), ";") {
+			source += ";"
+		}
+	}
+	rs.inputs = append(rs.inputs, source)
+}
+
+func (rs *replSession) command(name string, arg string) {
+	vm := rs.glox.vm
+
+	switch name {
+	case "help":
+		fmt.Fprintln(rs.out, replHelp)
+	case "env":
+		for _, binding := range vm.interpreter.globals.Bindings() {
+			fmt.Fprintf(rs.out, "%s = %s\n", binding.Name, FormatValue(binding.Value))
+		}
+	case "type":
+		if !isExpression(arg) {
+			fmt.Fprintln(rs.out, "Usage: :type EXPR")
+			break
+		}
+		if val, _, err := vm.evalLine(arg, rs.resolver); err == nil {
+			fmt.Fprintln(rs.out, typeName(val))
+		}
+	case "ast":
+		rs.printAst(arg)
+	case "tokens":
+		rs.printTokens(arg)
+	case "load":
+		if arg == "" {
+			fmt.Fprintln(rs.out, "Usage: :load FILE")
+			break
+		}
+		rs.load(arg)
+	case "save":
+		if arg == "" {
+			fmt.Fprintln(rs.out, "Usage: :save FILE")
+			break
+		}
+		if err := os.WriteFile(arg, []byte(strings.Join(rs.inputs, "\n")+"\n"), 0644); err != nil {
+			fmt.Fprintln(rs.out, err)
+			break
+		}
+		fmt.Fprintf(rs.out, "Saved %d inputs to %s.\n", len(rs.inputs), arg)
+	case "reset":
+		rs.glox.vm = NewVM(rs.glox.opts)
+		rs.reset()
+		fmt.Fprintln(rs.out, "Started a new session.")
+	case "time":
+		start := time.Now()
+		rs.run(arg)
+		fmt.Fprintf(rs.out, "Took %v.\n", time.Since(start))
+	default:
+		fmt.Fprintf(rs.out, "Unknown command ':%s'. Type :help for help.\n", name)
+	}
+}
+
+// reset forgets the inputs and resolves the next ones for the VM of glox.
+func (rs *replSession) reset() {
+	rs.resolver = NewResolver(rs.glox.vm.interpreter, rs.glox.vm.errorPrinter)
+	rs.inputs = nil
+}
+
+// load runs the script at path in the session. Its code is saved with the
+// inputs if it runs without errors.
+func (rs *replSession) load(path string) {
+	source, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintln(rs.out, err)
+		return
+	}
+
+	if err := rs.glox.vm.RunFile(path); err == nil {
+		rs.inputs = append(rs.inputs, strings.TrimRight(string(source), "\n"))
+	}
+}
+
+// printAst prints the syntax tree of an expression, or of every statement.
+func (rs *replSession) printAst(source string) {
+	errorPrinter := rs.glox.vm.errorPrinter
+	errorPrinter.Reset()
+	errorPrinter.SetSource(source)
+
+	tokens := NewScanner(source, errorPrinter).ScanTokens()
+	syntax := NewParser(tokens, errorPrinter).ParseREPL()
+	if errorPrinter.hadError {
+		return
+	}
+
+	printer := &AstPrinter{}
+	switch syntax := syntax.(type) {
+	case *Expression:
+		fmt.Fprintln(rs.out, printer.Print(syntax.Expression))
+	case []Stmt:
+		for _, stmt := range syntax {
+			fmt.Fprintln(rs.out, printer.PrintStmt(stmt))
+		}
+	}
+}
+
+// printTokens prints the tokens of source with their position and, for
+// literals, their value.
+func (rs *replSession) printTokens(source string) {
+	errorPrinter := rs.glox.vm.errorPrinter
+	errorPrinter.Reset()
+	errorPrinter.SetSource(source)
+
+	for _, token := range NewScanner(source, errorPrinter).ScanTokens() {
+		fmt.Fprintf(rs.out, "%d:%d %v %s", token.Line, token.Column, token.Type, token.Lexeme)
+		if token.Literal != nil {
+			fmt.Fprintf(rs.out, " (%s)", FormatValue(token.Literal))
+		}
+		fmt.Fprintln(rs.out)
+	}
+}
+
+// isExpression reports whether source is a single expression.
+func isExpression(source string) bool {
+	errorPrinter := NewErrorPrinter(io.Discard)
+	tokens := NewScanner(source, errorPrinter).ScanTokens()
+	_, ok := NewParser(tokens, errorPrinter).ParseREPL().(*Expression)
+
+	return ok && !errorPrinter.hadError
+}
+
+// typeName returns the name of the type of a value, as :type prints it.
+func typeName(value Value) string {
+	switch v := value.(type) {
+	case nil:
+		return "nil"
+	case bool:
+		return "boolean"
+	case int64:
+		return "integer"
+	case float64:
+		return "float"
+	case string:
+		return "string"
+	case *LoxList:
+		return "list"
+	case *LoxMap:
+		return "map"
+	case *LoxFunction, *closure, *boundMethod:
+		return "function"
+	case *NativeFunction:
+		return "native function"
+	case *LoxClass, *machineClass:
+		return "class"
+	case *LoxInstance:
+		return "instance of " + v.Class.Name
+	case *machineInstance:
+		return "instance of " + v.class.name
+	case *LoxError:
+		return "error"
+	case *LoxModule:
+		return "module"
+	}
+
+	return fmt.Sprintf("%T", value)
+}
+
+// historyPath returns the path of the file that keeps the history of the
+// REPL, or "" if there is no home directory.
+func historyPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+
+	return filepath.Join(home, ".glox_history")
+}
+
+// isIncomplete reports whether source needs more lines: it ends inside a
+// string, an interpolation or a comment, or a bracket is still open.
+func isIncomplete(source string) bool {
+	scanner := NewScanner(source, NewErrorPrinter(io.Discard))
+	tokens := scanner.ScanTokens()
+	if scanner.unterminated || len(scanner.interpolations) > 0 {
+		return true
+	}
+
+	depth := 0
+	for _, token := range tokens {
+		switch token.Type {
+		case LEFT_PAREN, LEFT_BRACKET, LEFT_BRACE:
+			depth++
+		case RIGHT_PAREN, RIGHT_BRACKET, RIGHT_BRACE:
+			depth--
+		}
+	}
+
+	return depth > 0
+}
+
+// complete returns the keywords and the global names that start with the
+// word before pos, and where the word starts. After "name.", where name is
+// a module, it returns the names the module exports instead, and after a
+// colon at the start of the line the names of the commands.
+func (g *Glox) complete(line []rune, pos int) (start int, candidates []string) {
+	start = pos
+	for start > 0 && isIdentifierRune(line[start-1]) {
+		start--
+	}
+	prefix := string(line[start:pos])
+
+	var names []string
+	if start == 1 && line[0] == ':' {
+		names = replCommands
+	} else if start > 0 && line[start-1] == '.' {
+		end := start - 1
+		begin := end
+		for begin > 0 && isIdentifierRune(line[begin-1]) {
+			begin--
+		}
+
+		module, ok := g.vm.interpreter.globals.Lookup(string(line[begin:end]))
+		if lm, isModule := module.(*LoxModule); ok && isModule {
+			names = lm.names()
+		}
+	} else {
+		for keyword := range keywords {
+			names = append(names, keyword)
+		}
+		for env := g.vm.interpreter.globals; env != nil; env = env.enclosing {
+			for name := range env.values {
+				names = append(names, name)
+			}
+		}
+	}
+
+	seen := map[string]bool{}
+	for _, name := range names {
+		if strings.HasPrefix(name, prefix) && !seen[name] {
+			seen[name] = true
+			candidates = append(candidates, name)
+		}
+	}
+	sort.Strings(candidates)
+
+	return start, candidates
+}
+
+func isIdentifierRune(r rune) bool {
+	return r < 0x80 && isAlphaNumeric(byte(r))
+}
@@ -0,0 +1,172 @@
+package glox
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// repl is a REPL session fed from a string instead of a terminal.
+type repl struct {
+	glox           *Glox
+	stdout, stderr bytes.Buffer
+}
+
+func newREPL() *repl {
+	r := &repl{}
+	r.glox = NewGlox(&Options{Stdout: &r.stdout, Stderr: &r.stderr})
+	return r
+}
+
+// run types the lines at the prompt and returns what the REPL wrote, without
+// the prompts.
+func (r *repl) run(lines ...string) string {
+	var out bytes.Buffer
+	r.glox.runPrompt(strings.NewReader(strings.Join(lines, "\n")+"\n"), &out)
+
+	return strings.NewReplacer(continuationPrompt, "", replPrompt, "").Replace(out.String())
+}
+
+func TestREPLKeepsResolverState(t *testing.T) {
+	r := newREPL()
+	out := r.run(
+		"var x = 1;",
+		"fun counter() {",
+		"  var n = 0;",
+		"  fun inc() { n = n + 1; return n; }",
+		"  return inc;",
+		"}",
+		"var c = counter();",
+		"c();",
+		"c()",
+		"fun addX(y) { return x + y; }",
+		"x = 10;",
+		"addX(c())",
+	)
+
+	if want := "= 2\n= 13\n"; out != want {
+		t.Errorf("got %q, want %q", out, want)
+	}
+	if r.stderr.Len() != 0 {
+		t.Errorf("errors: %s", r.stderr.String())
+	}
+}
+
+func TestREPLRejectsTopLevelReturn(t *testing.T) {
+	r := newREPL()
+	out := r.run("return 1;", "1 + 1")
+
+	if !strings.Contains(r.stderr.String(), "Can't return from top-level code.") {
+		t.Errorf("stderr: got %q, want the top-level return error", r.stderr.String())
+	}
+	if out != "= 2\n" {
+		t.Errorf("the next line: got %q, want %q", out, "= 2\n")
+	}
+}
+
+func TestREPLContinuation(t *testing.T) {
+	tests := []struct {
+		name   string
+		lines  []string
+		out    string
+		stdout string
+	}{
+		{"open brace", []string{"fun add(a, b) {", "  return a + b;", "}", "add(1, 2)"}, "= 3\n", ""},
+		{"open paren", []string{"print (1 +", "  2);"}, "", "3\n"},
+		{"unterminated string", []string{`print "first`, `second";`}, "", "first\nsecond\n"},
+		{"unterminated interpolation", []string{`"a${1 +`, `1}b"`}, "= a2b\n", ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := newREPL()
+			out := r.run(test.lines...)
+
+			if out != test.out || r.stdout.String() != test.stdout {
+				t.Errorf("got %q and output %q, want %q and %q", out, r.stdout.String(), test.out, test.stdout)
+			}
+			if r.stderr.Len() != 0 {
+				t.Errorf("errors: %s", r.stderr.String())
+			}
+		})
+	}
+}
+
+func TestREPLCommands(t *testing.T) {
+	tests := []struct {
+		command string
+		want    string
+	}{
+		{":type 1.5", "float\n"},
+		{":type [1]", "list\n"},
+		{":type clock", "native function\n"},
+		{":ast 1 + 2 * x", "(+ 1 (* 2 x))\n"},
+		{":ast var a = 1; print a;", "(var a 1)\n(print a)\n"},
+		{`:tokens print "a";`, "1:1 PRINT print\n1:7 STRING \"a\" (\"a\")\n1:10 SEMICOLON ;\n1:11 EOF \n"},
+		{":nope", "Unknown command ':nope'. Type :help for help.\n"},
+	}
+
+	for _, test := range tests {
+		if out := newREPL().run(test.command); out != test.want {
+			t.Errorf("%s: got %q, want %q", test.command, out, test.want)
+		}
+	}
+}
+
+func TestREPLSaveLoadReset(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.lox")
+
+	r := newREPL()
+	out := r.run("var x = 40;", "x + 2", "oops;", ":save "+path)
+	if want := "= 42\nSaved 2 inputs to " + path + ".\n"; out != want {
+		t.Errorf("save: got %q, want %q", out, want)
+	}
+
+	saved, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "var x = 40;\nx + 2;\n"; string(saved) != want {
+		t.Errorf("saved file: got %q, want %q", saved, want)
+	}
+
+	r.stderr.Reset()
+	out = r.run(":reset", "x")
+	if out != "Started a new session.\n" || !strings.Contains(r.stderr.String(), "Undefined variable 'x'.") {
+		t.Errorf("reset: got %q and errors %q, want x to be undefined", out, r.stderr.String())
+	}
+
+	r.stderr.Reset()
+	out = r.run(":load "+path, "x")
+	if out != "= 40\n" || r.stderr.Len() != 0 {
+		t.Errorf("load: got %q and errors %q, want x = 40", out, r.stderr.String())
+	}
+}
+
+func TestREPLComplete(t *testing.T) {
+	r := newREPL()
+	r.run("var greeting = 1;", `import "testdata/programs/modules/shapes.lox" as shapes;`)
+
+	tests := []struct {
+		line       string
+		start      int
+		candidates []string
+	}{
+		{"gr", 0, []string{"greeting"}},
+		{"print cl", 6, []string{"class", "clock"}},
+		{":lo", 1, []string{"load"}},
+		{"shapes.Sq", 7, []string{"Square"}},
+		{"zzz", 0, nil},
+	}
+
+	for _, test := range tests {
+		line := []rune(test.line)
+		start, candidates := r.glox.complete(line, len(line))
+		if start != test.start || !reflect.DeepEqual(candidates, test.candidates) {
+			t.Errorf("%q: got %d, %v, want %d, %v", test.line, start, candidates, test.start, test.candidates)
+		}
+	}
+}
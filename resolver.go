@@ -15,6 +15,39 @@ const (
 	FunctionType_INITIALIZER
 )
 
+// variable is what the Resolver knows about a local variable.
+type variable struct {
+	// defined marks whether or not we have finished resolving the variable's
+	// initializer.
+	defined bool
+
+	// slot is the index of the variable in the environment of its scope.
+	slot int
+
+	// name is the token that declared the variable. It is nil for the
+	// implicit "this" and "super".
+	name *Token
+
+	// kind tells what declared the variable, and read whether the variable
+	// is used anywhere other than on the left of an assignment.
+	kind SymbolKind
+	read bool
+}
+
+// resolverHooks is told about every declaration and variable use the
+// Resolver finds, including the global ones it does not resolve. Tools like
+// the language server use it to analyze source without running it.
+type resolverHooks interface {
+	// declared is called when name is declared. node is the statement that
+	// declares it, or nil for a parameter.
+	declared(name *Token, kind SymbolKind, node Stmt, global bool)
+
+	// referenced is called when a variable is used or assigned. decl is the
+	// name token of the local variable it resolves to, or nil if it is
+	// global.
+	referenced(name *Token, decl *Token)
+}
+
 // Resolver does a single walk over the tree to resolve all of the variables it contains.
 // It works after the parser produces the syntax tree, but before the
 // interpreter starts executing it. It walks the tree, visiting each node,
@@ -31,13 +64,14 @@ type Resolver struct {
 
 	// scopes keeps track of the stack of scopes currently in scope. Each
 	// element in the stack is a Map representing a single block scope. 
-	// Keys, as in Environment, are variable names. The values are Booleans,
-	// for marking if the variable is initialized. The scope stack is only
+	// Keys are variable names. The values record if the variable is
+	// initialized and which slot it takes in the environment of the scope.
+	// The scope stack is only
 	// used for local block scopes. Variables declared at the top level in the
 	// global scope are not tracked by the resolver since they are more dynamic
 	// in Lox. When resolving a variable, if we can’t find it in the stack of
 	// local scopes, we assume it must be global.
-	scopes       stack[map[string]bool]
+	scopes       stack[map[string]*variable]
 
 	// currentFunction marks whether or not the code we are currently visiting
 	// is inside a function declaration.
@@ -47,13 +81,30 @@ type Resolver struct {
 	// while traversing the syntax tree. It starts out NONE which means we
 	// aren’t in one.
 	currentClass	ClassType
+
+	// hooks, if set, is told about declarations and references.
+	hooks resolverHooks
+
+	// warn makes the Resolver report warnings as well as errors, except
+	// those whose code is in disabled. See EnableWarnings.
+	warn     bool
+	disabled map[WarningCode]bool
+
+	// globalNames holds the global declarations seen so far, and
+	// globalAssignments the assignments to globals. Assignments are checked
+	// once the whole source is resolved, as a function may assign a global
+	// declared after it. knownGlobals are the globals defined outside the
+	// source, such as the natives.
+	globalNames       map[string]*Token
+	globalAssignments []*Token
+	knownGlobals      map[string]bool
 }
 
 func NewResolver(interpreter *Interpreter, errorPrinter *ErrorPrinter) *Resolver {
 	return &Resolver{
 		interpreter: interpreter,
 		errorPrinter: errorPrinter,
-		scopes: Stack[map[string]bool](),
+		scopes: Stack[map[string]*variable](),
 		currentFunction: FunctionType_NONE,
 	}
 }
@@ -62,7 +113,7 @@ func (r *Resolver) VisitClassStmt(stmt *Class) error {
 	enclosingClass := r.currentClass
 	r.currentClass = ClassType_CLASS
 
-	r.declare(stmt.Name)
+	r.declare(stmt.Name, SymbolClass, stmt)
 	r.define(stmt.Name)
 
 	if stmt.Superclass != nil && stmt.Name.Lexeme == stmt.Superclass.Name.Lexeme {
@@ -74,17 +125,17 @@ func (r *Resolver) VisitClassStmt(stmt *Class) error {
 		r.resolveExpression(stmt.Superclass)
 	}
 
+	// "super" and "this" are the only variables in their scopes, so both
+	// take slot 0.
 	if stmt.Superclass != nil {
 		r.beginScope()
-		r.scopes.Peek()["super"] = true
+		r.scopes.Peek()["super"] = &variable{defined: true, slot: 0}
 	}
 
 	r.beginScope()
-	r.scopes.Peek()["this"] = true
+	r.scopes.Peek()["this"] = &variable{defined: true, slot: 0}
 
 	for _, method := range stmt.Methods {
-		r.declare(method.Name)
-		r.define(method.Name)
 		declaration := FunctionType_METHOD
 		if method.Name.Lexeme == "init" {
 			declaration = FunctionType_INITIALIZER
@@ -107,7 +158,7 @@ func (r *Resolver) VisitClassStmt(stmt *Class) error {
 // We define the name eagerly, before resolving the function’s body. This lets
 // a function recursively refer to itself inside its own body.
 func (r *Resolver) VisitFunctionStmt(stmt *Function) error {
-	r.declare(stmt.Name)
+	r.declare(stmt.Name, SymbolFunction, stmt)
 	r.define(stmt.Name)
 
 	r.resolveFunction(&stmt.Function, FunctionType_FUNCTION)
@@ -138,7 +189,7 @@ func (r *Resolver) VisitBlockStmt(stmt *Block) error {
 // interpreter fail either at compile time or runtime if an initializer
 // mentions the variable being initialized.
 func (r *Resolver) VisitVarStmt(stmt *Var) error {
-	r.declare(stmt.Name)
+	r.declare(stmt.Name, SymbolVariable, stmt)
 	if stmt.Initializer != nil {
 		if _, err := r.resolveExpression(stmt.Initializer); err != nil {
 			return err
@@ -149,6 +200,67 @@ func (r *Resolver) VisitVarStmt(stmt *Var) error {
 	return nil
 }
 
+func (r *Resolver) VisitThrowStmt(stmt *Throw) error {
+	r.resolveExpression(stmt.Value)
+	return nil
+}
+
+// VisitTryStmt resolves the clauses of a try statement. The error variable
+// of the catch clause lives in the same scope as the catch body.
+func (r *Resolver) VisitTryStmt(stmt *Try) error {
+	r.beginScope()
+	if err := r.resolveStatements(stmt.Body); err != nil {
+		return err
+	}
+	r.endScope()
+
+	if stmt.CatchName != nil {
+		r.beginScope()
+		r.declare(stmt.CatchName, SymbolVariable, stmt)
+		r.define(stmt.CatchName)
+		if err := r.resolveStatements(stmt.CatchBody); err != nil {
+			return err
+		}
+		r.endScope()
+	}
+
+	if stmt.FinallyBody != nil {
+		r.beginScope()
+		if err := r.resolveStatements(stmt.FinallyBody); err != nil {
+			return err
+		}
+		r.endScope()
+	}
+
+	return nil
+}
+
+// VisitImportStmt declares the module name, or the imported names, like
+// variables.
+func (r *Resolver) VisitImportStmt(stmt *Import) error {
+	if stmt.Alias != nil {
+		r.declare(stmt.Alias, SymbolModule, stmt)
+		r.define(stmt.Alias)
+		return nil
+	}
+
+	for _, name := range stmt.Names {
+		r.declare(name, SymbolVariable, stmt)
+		r.define(name)
+	}
+
+	return nil
+}
+
+func (r *Resolver) VisitExportStmt(stmt *Export) error {
+	if !r.scopes.IsEmpty() {
+		r.errorPrinter.TokenError(*stmt.Keyword, "Can only export top-level declarations.")
+		return nil
+	}
+
+	return r.resolveStatement(stmt.Declaration)
+}
+
 func (r *Resolver) VisitBreakStmt(stmt *Break) error {
 	return nil
 }
@@ -236,13 +348,52 @@ func (r *Resolver) VisitGetExpr(expr *Get) (interface{}, error) {
 	return nil, nil
 }
 
+func (r *Resolver) VisitListLiteralExpr(expr *ListLiteral) (interface{}, error) {
+	for _, element := range expr.Elements {
+		r.resolveExpression(element)
+	}
+
+	return nil, nil
+}
+
+func (r *Resolver) VisitMapLiteralExpr(expr *MapLiteral) (interface{}, error) {
+	for idx, key := range expr.Keys {
+		r.resolveExpression(key)
+		r.resolveExpression(expr.Values[idx])
+	}
+
+	return nil, nil
+}
+
+func (r *Resolver) VisitInterpolatedExpr(expr *Interpolated) (interface{}, error) {
+	r.resolveExpression(expr.Expression)
+	return nil, nil
+}
+
+func (r *Resolver) VisitIndexExpr(expr *Index) (interface{}, error) {
+	r.resolveExpression(expr.Object)
+	r.resolveExpression(expr.Index)
+
+	return nil, nil
+}
+
+func (r *Resolver) VisitSetIndexExpr(expr *SetIndex) (interface{}, error) {
+	r.resolveExpression(expr.Value)
+	r.resolveExpression(expr.Object)
+	r.resolveExpression(expr.Index)
+
+	return nil, nil
+}
+
 func (r *Resolver) VisitAssignExpr(expr *Assign) (interface{}, error) {
 	_, err := r.resolveExpression(expr.Value)
 	if err != nil {
 		return nil, err
 	}
 
-	r.resolveLocal(expr, expr.Name)
+	if v := r.resolveLocal(expr, expr.Name); v == nil && r.warn {
+		r.globalAssignments = append(r.globalAssignments, expr.Name)
+	}
 	return nil, nil
 }
 
@@ -252,12 +403,14 @@ func (r *Resolver) VisitAssignExpr(expr *Assign) (interface{}, error) {
 // means we have declared it but not yet defined it. We report that error.
 func (r *Resolver) VisitVariableExpr(expr *Variable) (interface{}, error) {
 	if !r.scopes.IsEmpty() {
-		if val, ok := r.scopes.Peek()[expr.Name.Lexeme]; ok && !val {
+		if v, ok := r.scopes.Peek()[expr.Name.Lexeme]; ok && !v.defined {
 			r.errorPrinter.TokenError(*expr.Name, "Can't read local variable in its own initializer.")
 		}
 	}
 
-	r.resolveLocal(expr, expr.Name)
+	if v := r.resolveLocal(expr, expr.Name); v != nil {
+		v.read = true
+	}
 	return nil, nil
 }
 
@@ -310,29 +463,43 @@ func (r *Resolver) VisitBinaryExpr(expr *Binary) (interface{}, error) {
 }
 
 func (r *Resolver) beginScope() {
-	r.scopes.Push(map[string]bool{})
+	r.scopes.Push(map[string]*variable{})
 }
 
 func (r *Resolver) endScope() {
+	if r.warn {
+		r.checkUnused(r.scopes.Peek())
+	}
 	r.scopes.Pop()
 }
 
 // declare adds the variable to the innermost scope so that it shadows any
 // outer one and so that we know the variable exists. We mark it as “not ready
-// yet” by binding its name to false in the scope map. The value associated
-// with a key in the scope map represents whether or not we have finished
-// resolving that variable’s initializer.
-func (r *Resolver) declare(name *Token) {
+// yet” by leaving it undefined. The variable takes the next free slot of the
+// scope, which is where the interpreter will store it at runtime.
+func (r *Resolver) declare(name *Token, kind SymbolKind, node Stmt) {
+	if r.hooks != nil {
+		r.hooks.declared(name, kind, node, r.scopes.IsEmpty())
+	}
+
 	if r.scopes.IsEmpty() {
+		if r.warn {
+			r.declareGlobal(name)
+		}
 		return
 	}
 
 	scope := r.scopes.Peek()
 	if _, ok := scope[name.Lexeme]; ok {
 		r.errorPrinter.TokenError(*name, "Already variable with this name in this scope.")
+		return
+	}
+
+	if r.warn {
+		r.checkShadowing(name)
 	}
 
-	scope[name.Lexeme] = false
+	scope[name.Lexeme] = &variable{defined: false, slot: len(scope), name: name, kind: kind}
 }
 
 // define set the variable’s value in the scope map to true to mark it as
@@ -342,27 +509,58 @@ func (r *Resolver) define(name *Token) {
 		return
 	}
 
-	r.scopes.Peek()[name.Lexeme] = true
+	if v, ok := r.scopes.Peek()[name.Lexeme]; ok {
+		v.defined = true
+	}
 }
 
 // resolveLocal starts at the innermost scope and work outwards, looking in each
 // map for a matching name. If we find the variable, we resolve it, passing in
 // the number of scopes between the current innermost scope and the scope where
-// the variable was found. So, if the variable was found in the current scope,
-// we pass in 0. If it’s in the immediately enclosing scope, 1. If we walk through
-// all of the block scopes and never find the variable, we leave it unresolved
-// and assume it’s global.
-func (r *Resolver) resolveLocal(expr Expr, name *Token) {
+// the variable was found, and the slot of the variable in that scope. So, if the
+// variable was found in the current scope, we pass in 0. If it’s in the
+// immediately enclosing scope, 1. If we walk through all of the block scopes and
+// never find the variable, we leave it unresolved and assume it’s global.
+// The interpreter may be nil when the Resolver only analyzes the source.
+// resolveLocal returns the variable, or nil if it is global.
+func (r *Resolver) resolveLocal(expr Expr, name *Token) *variable {
 	for i := r.scopes.Length() - 1; i >= 0; i-- {
 		scope := r.scopes.Get(i)
-		if _, ok := scope[name.Lexeme]; ok {
-			r.interpreter.resolve(expr, r.scopes.Length()-1-i)
-			return
+		if v, ok := scope[name.Lexeme]; ok {
+			if r.interpreter != nil {
+				r.interpreter.resolve(expr, r.scopes.Length()-1-i, v.slot)
+			}
+			if r.hooks != nil && v.name != nil {
+				r.hooks.referenced(name, v.name)
+			}
+			return v
 		}
 	}
+
+	if r.hooks != nil {
+		r.hooks.referenced(name, nil)
+	}
+	return nil
+}
+
+// Resolve resolves all the variables in the statements and reports the
+// errors it finds through the errorPrinter.
+func (r *Resolver) Resolve(statements []Stmt) error {
+	if err := r.resolveStatements(statements); err != nil {
+		return err
+	}
+
+	if r.warn {
+		r.checkGlobalAssignments()
+	}
+	return nil
 }
 
 func (r *Resolver) resolveStatements(statements []Stmt) error {
+	if r.warn {
+		r.checkUnreachable(statements)
+	}
+
 	for _, statement := range statements {
 		if err := r.resolveStatement(statement); err != nil {
 			return err
@@ -389,7 +587,7 @@ func (r *Resolver) resolveFunction(function *FunctionExpr, _type FunctionType) {
 	r.beginScope()
 
 	for _, param := range function.Paramters {
-		r.declare(param)
+		r.declare(param, SymbolParameter, nil)
 		r.define(param)
 	}
 
@@ -2,26 +2,34 @@ package glox
 
 import (
 	"strconv"
+	"strings"
+	"unicode/utf8"
 )
 
 var keywords = map[string]TokenType{
-	"and":    AND,
-	"break":  BREAK,
-	"class":  CLASS,
-	"else":   ELSE,
-	"false":  FALSE,
-	"for":    FOR,
-	"fun":    FUN,
-	"if":     IF,
-	"nil":    NIL,
-	"or":     OR,
-	"print":  PRINT,
-	"return": RETURN,
-	"super":  SUPER,
-	"this":   THIS,
-	"true":   TRUE,
-	"var":    VAR,
-	"while":  WHILE,
+	"and":     AND,
+	"break":   BREAK,
+	"catch":   CATCH,
+	"class":   CLASS,
+	"else":    ELSE,
+	"export":  EXPORT,
+	"false":   FALSE,
+	"finally": FINALLY,
+	"for":     FOR,
+	"fun":     FUN,
+	"if":      IF,
+	"import":  IMPORT,
+	"nil":     NIL,
+	"or":      OR,
+	"print":   PRINT,
+	"return":  RETURN,
+	"super":   SUPER,
+	"this":    THIS,
+	"throw":   THROW,
+	"true":    TRUE,
+	"try":     TRY,
+	"var":     VAR,
+	"while":   WHILE,
 }
 
 type Scanner struct {
@@ -32,6 +40,33 @@ type Scanner struct {
 	current uint32
 	line    uint32
 
+	// column is the column of the current character, counted in runes from
+	// 1. startLine and startColumn locate the start of the current token.
+	column      uint32
+	startLine   uint32
+	startColumn uint32
+
+	// next is the token NextToken returns, once scanned is set.
+	next    Token
+	scanned bool
+
+	// file is recorded in every token. It is set when scanning a module.
+	file    string
+
+	// keepComments makes the scanner emit COMMENT tokens, for the
+	// formatter. The parser does not accept them.
+	keepComments bool
+
+	// interpolations counts the braces opened inside each interpolated
+	// expression being scanned, the innermost last, so that the '}' that
+	// ends the expression can be told from the ones of map literals and
+	// blocks.
+	interpolations []int
+
+	// unterminated is set when the source ends inside a string or a
+	// comment, so that the REPL can ask for more lines.
+	unterminated bool
+
 	errorPrinter *ErrorPrinter
 }
 
@@ -43,19 +78,52 @@ func NewScanner(source string, errorPrinter *ErrorPrinter) *Scanner {
 		start:   0,
 		current: 0,
 		line:    1,
+		column:  1,
 		errorPrinter: errorPrinter,
 	}
 }
 
 // ScanTokens returns a slice of tokens representing the source text.
 func (sc *Scanner) ScanTokens() []Token {
-	for !sc.isAtEnd() {
-		sc.start = sc.current
+	for {
+		token := sc.NextToken()
+		sc.tokens = append(sc.tokens, token)
+		if token.Type == EOF {
+			return sc.tokens
+		}
+	}
+}
+
+// NextToken scans and returns the next token of the source, for tools that
+// tokenize without parsing, such as highlighters. Errors are reported as by
+// ScanTokens, and the characters in error are skipped. At the end of the
+// source, it returns an EOF token on every call.
+func (sc *Scanner) NextToken() Token {
+	sc.scanned = false
+	for !sc.scanned && !sc.isAtEnd() {
+		sc.startToken()
 		sc.scanToken()
 	}
 
-	sc.addToken(EOF)
-	return sc.tokens
+	if !sc.scanned {
+		sc.startToken()
+		sc.addToken(EOF)
+	}
+
+	return sc.next
+}
+
+// SetKeepComments makes the scanner emit COMMENT tokens, which the parser
+// does not accept.
+func (sc *Scanner) SetKeepComments(keep bool) {
+	sc.keepComments = keep
+}
+
+// startToken marks the current character as the start of the next token.
+func (sc *Scanner) startToken() {
+	sc.start = sc.current
+	sc.startLine = sc.line
+	sc.startColumn = sc.column
 }
 
 func (sc *Scanner) scanToken() {
@@ -66,9 +134,27 @@ func (sc *Scanner) scanToken() {
 	case ')':
 		sc.addToken(RIGHT_PAREN)
 	case '{':
+		if n := len(sc.interpolations); n > 0 {
+			sc.interpolations[n-1]++
+		}
 		sc.addToken(LEFT_BRACE)
 	case '}':
+		n := len(sc.interpolations)
+		if n > 0 && sc.interpolations[n-1] == 0 {
+			// the interpolated expression ends and the string goes on.
+			sc.interpolations = sc.interpolations[:n-1]
+			sc.string()
+			break
+		}
+
+		if n > 0 {
+			sc.interpolations[n-1]--
+		}
 		sc.addToken(RIGHT_BRACE)
+	case '[':
+		sc.addToken(LEFT_BRACKET)
+	case ']':
+		sc.addToken(RIGHT_BRACKET)
 	case ',':
 		sc.addToken(COMMA)
 	case '.':
@@ -87,11 +173,22 @@ func (sc *Scanner) scanToken() {
 			for sc.peek() != '\n' && !sc.isAtEnd() {
 				sc.advance()
 			}
+
+			if sc.keepComments {
+				sc.addToken(COMMENT)
+			}
 		} else if sc.match('*') {
 			sc.multilineComment()
 		} else {
 			sc.addToken(SLASH)
 		}
+	case '~':
+		// "//" starts a comment, so the floor division is "~/".
+		if sc.match('/') {
+			sc.addToken(TILDE_SLASH)
+		} else {
+			sc.errorPrinter.ErrorAt(sc.span(), "Unexpected character.")
+		}
 	case '?':
 		sc.addToken(QUESTION_MARK)
 	case ':':
@@ -141,7 +238,11 @@ func (sc *Scanner) scanToken() {
 			// Identifiers
 			sc.identifier()
 		} else {
-			sc.errorPrinter.Error(sc.line, "Unexpected character.")
+			// report a multi-byte character once, not once per byte.
+			for !sc.isAtEnd() && !utf8.RuneStart(sc.peek()) {
+				sc.advance()
+			}
+			sc.errorPrinter.ErrorAt(sc.span(), "Unexpected character.")
 		}
 	}
 }
@@ -151,8 +252,16 @@ func (sc *Scanner) isAtEnd() bool {
 }
 
 func (sc *Scanner) advance() byte {
+	c := sc.source[sc.current]
 	sc.current++
-	return sc.source[sc.current-1]
+
+	if c == '\n' {
+		sc.column = 1
+	} else if utf8.RuneStart(c) {
+		sc.column++
+	}
+
+	return c
 }
 
 func (sc *Scanner) addToken(_type TokenType) {
@@ -165,7 +274,21 @@ func (sc *Scanner) addTokenWithLiteral(_type TokenType, literal interface{}) {
 		text = sc.source[sc.start:sc.current]
 	}
 
-	sc.tokens = append(sc.tokens, Token{Type: _type, Lexeme: text, Literal: literal, Line: sc.line})
+	sc.next = sc.span()
+	sc.next.Type, sc.next.Lexeme, sc.next.Literal = _type, text, literal
+	sc.scanned = true
+}
+
+// span returns a token without type that covers the source from the start
+// of the current token to the current character.
+func (sc *Scanner) span() Token {
+	return Token{
+		Line:   sc.startLine,
+		File:   sc.file,
+		Offset: sc.start,
+		Column: sc.startColumn,
+		Length: sc.current - sc.start,
+	}
 }
 
 func (sc *Scanner) match(expected byte) bool {
@@ -193,25 +316,116 @@ func (sc *Scanner) peekNext() byte {
 	return sc.source[sc.current+1]
 }
 
+// string scans a string literal, or the rest of one after an interpolated
+// expression. The literal of the token is the text with its escape
+// sequences replaced. The text before a "${" becomes an INTERPOLATION token
+// and the scanner goes back to scanning code.
 func (sc *Scanner) string() {
+	var value strings.Builder
 	for sc.peek() != '"' && !sc.isAtEnd() {
-		if sc.peek() == '\n' {
+		switch sc.peek() {
+		case '\n':
 			sc.line++
+		case '\\':
+			escape := Token{Line: sc.line, File: sc.file, Offset: sc.current, Column: sc.column}
+			sc.advance()
+			if message := sc.escape(&value); message != "" {
+				escape.Length = sc.current - escape.Offset
+				sc.errorPrinter.ErrorAt(escape, message)
+			}
+			continue
+		case '$':
+			if sc.peekNext() == '{' {
+				sc.advance()
+				sc.advance()
+				sc.interpolations = append(sc.interpolations, 0)
+				sc.addTokenWithLiteral(INTERPOLATION, value.String())
+				return
+			}
 		}
-		sc.advance()
+
+		value.WriteByte(sc.advance())
 	}
 
 	if sc.isAtEnd() {
-		sc.errorPrinter.Error(sc.line, "Unterminated string.")
+		sc.unterminated = true
+
+		// point at the opening quote.
+		start := sc.span()
+		start.Length = 1
+		sc.errorPrinter.ErrorAt(start, "Unterminated string.")
 		return
 	}
 
 	// The closing quote (")
 	sc.advance()
 
-	// Trim the surrounding quotes.
-	value := sc.source[sc.start+1 : sc.current-1]
-	sc.addTokenWithLiteral(STRING, value)
+	sc.addTokenWithLiteral(STRING, value.String())
+}
+
+// escape writes the character of the escape sequence whose backslash has
+// just been consumed. It returns an error message if the sequence is not
+// valid.
+func (sc *Scanner) escape(value *strings.Builder) string {
+	if sc.isAtEnd() {
+		// the string is unterminated.
+		return ""
+	}
+
+	switch c := sc.peek(); c {
+	case 'n':
+		value.WriteByte('\n')
+	case 't':
+		value.WriteByte('\t')
+	case 'r':
+		value.WriteByte('\r')
+	case '0':
+		value.WriteByte(0)
+	case '"', '\\', '$':
+		value.WriteByte(c)
+	case 'u':
+		sc.advance()
+		return sc.unicodeEscape(value)
+	case '\n':
+		// leave the new line to string, which counts it.
+		return "Invalid escape sequence."
+	default:
+		// consume the whole character, so that the error covers it.
+		sc.advance()
+		for !sc.isAtEnd() && !utf8.RuneStart(sc.peek()) {
+			sc.advance()
+		}
+		return "Invalid escape sequence."
+	}
+
+	sc.advance()
+	return ""
+}
+
+// unicodeEscape writes the code point of a \u{...} escape sequence, whose
+// "\u" has just been consumed. It takes from one to six hex digits.
+func (sc *Scanner) unicodeEscape(value *strings.Builder) string {
+	if !sc.match('{') {
+		return "Expect '{' after '\\u'."
+	}
+
+	start := sc.current
+	for isHexDigit(sc.peek()) {
+		sc.advance()
+	}
+	digits := sc.source[start:sc.current]
+
+	if !sc.match('}') {
+		return "Expect '}' after unicode escape."
+	}
+
+	code, err := strconv.ParseUint(digits, 16, 32)
+	if err != nil || len(digits) > 6 || !utf8.ValidRune(rune(code)) {
+		return "Invalid unicode code point."
+	}
+
+	value.WriteRune(rune(code))
+	return ""
 }
 
 func (sc *Scanner) number() {
@@ -229,9 +443,37 @@ func (sc *Scanner) number() {
 		}
 	}
 
-	value, err := strconv.ParseFloat(sc.source[sc.start:sc.current], 64)
+	// Look for an exponent, so that every number printed reads back.
+	if sc.peek() == 'e' || sc.peek() == 'E' {
+		next := sc.peekNext()
+		if (next == '+' || next == '-') && sc.current+2 < uint32(len(sc.source)) {
+			next = sc.source[sc.current+2]
+		}
+
+		if isDigit(next) {
+			sc.advance()
+			if sc.peek() == '+' || sc.peek() == '-' {
+				sc.advance()
+			}
+
+			for isDigit(sc.peek()) {
+				sc.advance()
+			}
+		}
+	}
+
+	// a literal without a fractional part is an integer, unless it is too
+	// large for one.
+	text := sc.source[sc.start:sc.current]
+	if integer, err := strconv.ParseInt(text, 10, 64); err == nil {
+		sc.addTokenWithLiteral(NUMBER, integer)
+		return
+	}
+
+	value, err := strconv.ParseFloat(text, 64)
 	if err != nil {
-		panic(err)
+		// the syntax is checked above, so the literal is too large.
+		sc.errorPrinter.ErrorAt(sc.span(), "Number literal is out of range.")
 	}
 
 	sc.addTokenWithLiteral(NUMBER, value)
@@ -256,6 +498,10 @@ func (sc *Scanner) multilineComment() {
 		if sc.peek() == '*' && sc.peekNext() == '/' {
 			sc.advance()
 			sc.advance()
+
+			if sc.keepComments {
+				sc.addToken(COMMENT)
+			}
 			return
 		}
 
@@ -266,13 +512,20 @@ func (sc *Scanner) multilineComment() {
 		sc.advance()
 	}
 
-	sc.errorPrinter.Error(sc.line, "Multiline comment was not closed")
+	sc.unterminated = true
+	start := sc.span()
+	start.Length = 2
+	sc.errorPrinter.ErrorAt(start, "Multiline comment was not closed")
 }
 
 func isDigit(c byte) bool {
 	return c >= '0' && c <= '9'
 }
 
+func isHexDigit(c byte) bool {
+	return isDigit(c) || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
 func isAlpha(c byte) bool {
 	return (c >= 'a' && c <= 'z') ||
 		(c >= 'A' && c <= 'Z') ||
@@ -0,0 +1,70 @@
+package glox
+
+import (
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestNextToken(t *testing.T) {
+	source := "var a = 1; // comment\nprint \"${a}\";\n"
+	want := NewScanner(source, NewErrorPrinter(io.Discard)).ScanTokens()
+
+	scanner := NewScanner(source, NewErrorPrinter(io.Discard))
+	for idx, token := range want {
+		got := scanner.NextToken()
+		if got.Type != token.Type || got.Lexeme != token.Lexeme || got.Line != token.Line || got.Column != token.Column {
+			t.Errorf("token %d: got %v at %d:%d, want %v at %d:%d", idx, &got, got.Line, got.Column, &token, token.Line, token.Column)
+		}
+	}
+
+	// past the end, every call returns EOF again.
+	for idx := 0; idx < 3; idx++ {
+		if got := scanner.NextToken(); got.Type != EOF || got.Line != 3 {
+			t.Errorf("after the end: got %v on line %d, want EOF on line 3", &got, got.Line)
+		}
+	}
+}
+
+func TestTokenTypeString(t *testing.T) {
+	// every type has a name of its own.
+	seen := map[string]bool{}
+	for tokenType := LEFT_PAREN; tokenType <= EOF; tokenType++ {
+		name := tokenType.String()
+		if name == "" || strings.HasPrefix(name, "TokenType(") || seen[name] {
+			t.Errorf("TokenType(%d): got the name %q", uint32(tokenType), name)
+		}
+		seen[name] = true
+	}
+
+	for tokenType, want := range map[TokenType]string{
+		LEFT_PAREN:    "LEFT_PAREN",
+		TILDE_SLASH:   "TILDE_SLASH",
+		INTERPOLATION: "INTERPOLATION",
+		WHILE:         "WHILE",
+		EOF:           "EOF",
+		EOF + 1:       "TokenType(" + strconv.Itoa(int(EOF+1)) + ")",
+	} {
+		if got := tokenType.String(); got != want {
+			t.Errorf("got %s, want %s", got, want)
+		}
+	}
+}
+
+func TestUnexpectedTilde(t *testing.T) {
+	ep := NewErrorPrinter(io.Discard)
+	tokens := NewScanner("print 7 ~/ 2;\nprint 7 ~ 2;", ep).ScanTokens()
+
+	if tokens[2].Type != TILDE_SLASH {
+		t.Errorf("got %v, want ~/", &tokens[2])
+	}
+
+	errs := ep.Errors()
+	if len(errs) != 1 {
+		t.Fatalf("got %d errors, want 1", len(errs))
+	}
+	if err := errs[0]; err.Line != 2 || err.Column != 9 || err.Length != 1 || err.Message != "Unexpected character." {
+		t.Errorf("got %q at %d:%d, length %d, want it at 2:9, length 1", err.Message, err.Line, err.Column, err.Length)
+	}
+}
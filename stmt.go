@@ -9,8 +9,12 @@ type StmtVisitor interface {
     VisitWhileStmt(stmt *While) error
     VisitBreakStmt(stmt *Break) error
     VisitFunctionStmt(stmt *Function) error
+    VisitThrowStmt(stmt *Throw) error
+    VisitTryStmt(stmt *Try) error
     VisitReturnStmt(stmt *Return) error
     VisitClassStmt(stmt *Class) error
+    VisitImportStmt(stmt *Import) error
+    VisitExportStmt(stmt *Export) error
 }
 
 type Stmt interface {
@@ -26,6 +30,7 @@ func (e *Expression) Accept(visitor StmtVisitor) error {
 }
 
 type Print struct {
+    Keyword *Token
     Expression Expr
 }
 
@@ -51,6 +56,7 @@ func (b *Block) Accept(visitor StmtVisitor) error {
 }
 
 type If struct {
+    Keyword *Token
     Condition Expr
     ThenBranch Stmt
     ElseBranch Stmt
@@ -61,6 +67,7 @@ func (i *If) Accept(visitor StmtVisitor) error {
 }
 
 type While struct {
+    Keyword *Token
     Condition Expr
     Body Stmt
 }
@@ -70,6 +77,7 @@ func (w *While) Accept(visitor StmtVisitor) error {
 }
 
 type Break struct {
+    Keyword *Token
 }
 
 func (b *Break) Accept(visitor StmtVisitor) error {
@@ -85,6 +93,27 @@ func (f *Function) Accept(visitor StmtVisitor) error {
     return visitor.VisitFunctionStmt(f)
 }
 
+type Throw struct {
+    Keyword *Token
+    Value Expr
+}
+
+func (t *Throw) Accept(visitor StmtVisitor) error {
+    return visitor.VisitThrowStmt(t)
+}
+
+type Try struct {
+    Keyword *Token
+    Body []Stmt
+    CatchName *Token
+    CatchBody []Stmt
+    FinallyBody []Stmt
+}
+
+func (t *Try) Accept(visitor StmtVisitor) error {
+    return visitor.VisitTryStmt(t)
+}
+
 type Return struct {
     Keyword *Token
     Value Expr
@@ -104,3 +133,24 @@ func (c *Class) Accept(visitor StmtVisitor) error {
     return visitor.VisitClassStmt(c)
 }
 
+type Import struct {
+    Keyword *Token
+    Path *Token
+    Alias *Token
+    Names []*Token
+    Dir string
+}
+
+func (i *Import) Accept(visitor StmtVisitor) error {
+    return visitor.VisitImportStmt(i)
+}
+
+type Export struct {
+    Keyword *Token
+    Declaration Stmt
+}
+
+func (e *Export) Accept(visitor StmtVisitor) error {
+    return visitor.VisitExportStmt(e)
+}
+
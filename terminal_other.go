@@ -0,0 +1,16 @@
+//go:build !linux
+
+package glox
+
+import "errors"
+
+// Line editing needs raw mode, which is only implemented on Linux. Elsewhere
+// the REPL reads plain lines.
+
+func isTerminal(fd uintptr) bool {
+	return false
+}
+
+func makeRaw(fd uintptr) (restore func(), err error) {
+	return nil, errors.New("raw mode is not supported")
+}
@@ -10,6 +10,8 @@ const (
 	RIGHT_PAREN						// )
 	LEFT_BRACE						// {
 	RIGHT_BRACE						// }
+	LEFT_BRACKET					// [
+	RIGHT_BRACKET					// ]
 	COMMA							// ,
 	DOT								// .
 	MINUS							// -
@@ -19,6 +21,7 @@ const (
 	STAR							// *
 	QUESTION_MARK					// ?
 	COLON							// :
+	TILDE_SLASH						// ~/ floor division, as // starts a comment
 
 	// One or two character tokens
 	BANG							// !
@@ -35,33 +38,124 @@ const (
 	STRING
 	NUMBER
 
+	// INTERPOLATION is the part of a string literal that ends with "${",
+	// before an interpolated expression. The part after the last
+	// expression, which starts with "}", is a STRING.
+	INTERPOLATION
+
 	// Keywords
 	AND
 	BREAK
+	CATCH
 	CLASS
 	ELSE
+	EXPORT
 	FALSE
+	FINALLY
 	FUN
 	FOR
 	IF
+	IMPORT
 	NIL
 	OR
 	PRINT
 	RETURN
 	SUPER
 	THIS
+	THROW
 	TRUE
+	TRY
 	VAR
 	WHILE
 
+	// Comments are only kept when scanning for the formatter.
+	COMMENT
+
 	EOF
 )
 
+var tokenTypeNames = [...]string{
+	LEFT_PAREN:    "LEFT_PAREN",
+	RIGHT_PAREN:   "RIGHT_PAREN",
+	LEFT_BRACE:    "LEFT_BRACE",
+	RIGHT_BRACE:   "RIGHT_BRACE",
+	LEFT_BRACKET:  "LEFT_BRACKET",
+	RIGHT_BRACKET: "RIGHT_BRACKET",
+	COMMA:         "COMMA",
+	DOT:           "DOT",
+	MINUS:         "MINUS",
+	PLUS:          "PLUS",
+	SEMICOLON:     "SEMICOLON",
+	SLASH:         "SLASH",
+	STAR:          "STAR",
+	QUESTION_MARK: "QUESTION_MARK",
+	COLON:         "COLON",
+	TILDE_SLASH:   "TILDE_SLASH",
+	BANG:          "BANG",
+	BANG_EQUAL:    "BANG_EQUAL",
+	EQUAL:         "EQUAL",
+	EQUAL_EQUAL:   "EQUAL_EQUAL",
+	GREATER:       "GREATER",
+	GREATER_EQUAL: "GREATER_EQUAL",
+	LESS:          "LESS",
+	LESS_EQUAL:    "LESS_EQUAL",
+	IDENTIFIER:    "IDENTIFIER",
+	STRING:        "STRING",
+	NUMBER:        "NUMBER",
+	INTERPOLATION: "INTERPOLATION",
+	AND:           "AND",
+	BREAK:         "BREAK",
+	CATCH:         "CATCH",
+	CLASS:         "CLASS",
+	ELSE:          "ELSE",
+	EXPORT:        "EXPORT",
+	FALSE:         "FALSE",
+	FINALLY:       "FINALLY",
+	FUN:           "FUN",
+	FOR:           "FOR",
+	IF:            "IF",
+	IMPORT:        "IMPORT",
+	NIL:           "NIL",
+	OR:            "OR",
+	PRINT:         "PRINT",
+	RETURN:        "RETURN",
+	SUPER:         "SUPER",
+	THIS:          "THIS",
+	THROW:         "THROW",
+	TRUE:          "TRUE",
+	TRY:           "TRY",
+	VAR:           "VAR",
+	WHILE:         "WHILE",
+	COMMENT:       "COMMENT",
+	EOF:           "EOF",
+}
+
+// String returns the name of the constant of the type, such as LEFT_PAREN.
+func (t TokenType) String() string {
+	if int(t) < len(tokenTypeNames) {
+		return tokenTypeNames[t]
+	}
+
+	return fmt.Sprintf("TokenType(%d)", uint32(t))
+}
+
 type Token struct {
 	Type    TokenType
 	Lexeme  string
 	Literal interface{}
 	Line    uint32
+
+	// File is the path of the module the token was scanned from. It is
+	// empty for the main script.
+	File    string
+
+	// Offset is the byte offset of the token in the source and Length its
+	// length in bytes. Column is the column the token starts at, counted
+	// in runes from 1. Tokens made up by the compiler and the Machine have
+	// no position: their Column is 0.
+	Offset  uint32
+	Column  uint32
+	Length  uint32
 }
 
 func (t *Token) String() string {
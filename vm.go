@@ -0,0 +1,290 @@
+package glox
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+)
+
+// Value is any value a Lox program can produce: nil, bool, int64, float64,
+// string, or one of the runtime objects such as *LoxInstance and
+// *LoxFunction.
+type Value = interface{}
+
+// Backend selects how a VM runs the code.
+type Backend int
+
+const (
+	// TreeWalk evaluates the syntax tree directly with the Interpreter.
+	TreeWalk Backend = iota
+
+	// Bytecode compiles the syntax tree with the Compiler and runs the
+	// bytecode on the Machine.
+	Bytecode
+)
+
+// Options configures a VM. A nil *Options is the same as the zero value.
+type Options struct {
+	// Globals are defined before any code runs. Like the natives, they are
+	// visible in every module. Go numbers, slices and maps are converted to
+	// Lox values the way WrapFunc converts results.
+	Globals map[string]Value
+
+	// Stdout receives the output of print statements. It defaults to
+	// os.Stdout.
+	Stdout io.Writer
+
+	// Stderr receives the diagnostics: static and runtime errors. It
+	// defaults to os.Stderr. Use io.Discard to rely on the returned errors
+	// only.
+	Stderr io.Writer
+
+	// Backend selects the backend that runs the code. It defaults to
+	// TreeWalk.
+	Backend Backend
+
+	// Debugger, if set, is called before each statement runs. Debugging
+	// needs the TreeWalk backend; the Bytecode backend ignores it.
+	Debugger *Debugger
+
+	// MaxSteps, if positive, bounds the work of each Eval or RunFile. A step
+	// is a statement or an expression on the TreeWalk backend and an
+	// instruction on the Bytecode backend.
+	MaxSteps int64
+
+	// MaxCallDepth bounds the depth of calls. It defaults to
+	// DefaultMaxCallDepth and can't exceed MaxCallDepthCeiling.
+	MaxCallDepth int
+
+	// Context, if set, stops the code when it is canceled or its deadline
+	// passes.
+	Context context.Context
+
+	// Seed, if not zero, seeds math.random. Otherwise it is seeded from the
+	// current time.
+	Seed int64
+}
+
+// VM is the entry point for Go programs that embed the interpreter. It
+// keeps its global state between calls, so definitions made by one Eval
+// are visible to the next one.
+type VM struct {
+	interpreter *Interpreter
+
+	// machine runs the code when the Bytecode backend is selected. It is
+	// nil otherwise.
+	machine *Machine
+
+	// limiter enforces the limits of the Options on both backends.
+	limiter *limiter
+
+	// errorPrinter receives and reports errors that occur during
+	// scanning, parsing and interpreting.
+	errorPrinter *ErrorPrinter
+
+	// modules caches the imported modules by absolute path, and loading
+	// lists the absolute paths of the modules whose code is running,
+	// innermost last. The script run by RunFile is loading too.
+	modules map[string]*LoxModule
+	loading []string
+}
+
+// NewVM returns a VM configured by opts.
+func NewVM(opts *Options) *VM {
+	if opts == nil {
+		opts = &Options{}
+	}
+
+	stdout, stderr := opts.Stdout, opts.Stderr
+	if stdout == nil {
+		stdout = os.Stdout
+	}
+	if stderr == nil {
+		stderr = os.Stderr
+	}
+
+	ep := NewErrorPrinter(stderr)
+	interpreter := NewInterpreter(ep, stdout)
+	for name, val := range opts.Globals {
+		interpreter.builtins.Define(name, toLox(reflect.ValueOf(val)))
+	}
+
+	if opts.Backend == TreeWalk {
+		interpreter.debugger = opts.Debugger
+	}
+	if opts.Seed != 0 {
+		interpreter.Seed(opts.Seed)
+	}
+	interpreter.limiter = newLimiter(opts)
+
+	vm := &VM{
+		interpreter: interpreter,
+		limiter: interpreter.limiter,
+		errorPrinter: ep,
+		modules: map[string]*LoxModule{},
+	}
+	interpreter.importer = vm.importModule
+
+	if opts.Backend == Bytecode {
+		vm.machine = NewMachine(interpreter.globals, stdout)
+		vm.machine.importer = vm.importModule
+		vm.machine.limiter = vm.limiter
+	}
+
+	return vm
+}
+
+// Interpreter returns the interpreter the VM runs code with.
+func (vm *VM) Interpreter() *Interpreter {
+	return vm.interpreter
+}
+
+// Seed seeds the generator behind math.random, as Options.Seed does.
+func (vm *VM) Seed(seed int64) {
+	vm.interpreter.Seed(seed)
+}
+
+// DefineNative defines a global function name, taking arity arguments, that
+// is implemented by the Go function fn.
+func (vm *VM) DefineNative(name string, arity int, fn NativeFunc) {
+	vm.interpreter.DefineNative(name, arity, fn)
+}
+
+// DefineFunc defines a global function name that calls the Go function fn,
+// converting its arguments and results as described in WrapFunc.
+func (vm *VM) DefineFunc(name string, fn interface{}) error {
+	return vm.interpreter.DefineFunc(name, fn)
+}
+
+// Eval runs source and returns the value of its trailing expression, if any.
+// The semicolon after the trailing expression may be omitted, so a single
+// expression such as "1 + 2" is a valid source. Static errors are returned as a *CompileError and
+// runtime errors as a *RuntimeError. Imports are relative to the working
+// directory. Exceeding a limit of the Options returns a *LimitError.
+func (vm *VM) Eval(source string) (Value, error) {
+	vm.limiter.reset()
+	return vm.eval(source, "", true)
+}
+
+// RunFile reads the script at path and runs it. Imports are relative to the
+// directory of the script.
+func (vm *VM) RunFile(path string) error {
+	bytes, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	// the script loads like a module, so that importing it back from one
+	// of its modules is reported as a cycle instead of running it again.
+	if key, err := filepath.Abs(path); err == nil {
+		vm.modules[key] = &LoxModule{Path: filepath.Clean(path)}
+		vm.loading = append(vm.loading, key)
+		defer func() {
+			delete(vm.modules, key)
+			vm.loading = vm.loading[:len(vm.loading)-1]
+		}()
+	}
+
+	vm.limiter.reset()
+	_, err = vm.eval(string(bytes), filepath.Dir(path), false)
+	return err
+}
+
+// eval runs source through the scan→parse→resolve→interpret pipeline.
+// dir is the directory imports are relative to. allowExpression lets the
+// source be a single bare expression, as in Eval.
+func (vm *VM) eval(source string, dir string, allowExpression bool) (Value, error) {
+	vm.errorPrinter.Reset()
+	vm.errorPrinter.SetSource(source)
+
+	scanner := NewScanner(source, vm.errorPrinter)
+	tokens := scanner.ScanTokens()
+
+	parser := NewParser(tokens, vm.errorPrinter)
+	// let the last expression statement omit its semicolon.
+	parser.allowExpression = allowExpression
+	parser.dir = dir
+	stmts := parser.Parse()
+
+	if vm.errorPrinter.hadError {
+		return nil, &CompileError{Errors: vm.errorPrinter.Errors()}
+	}
+
+	resolver := NewResolver(vm.interpreter, vm.errorPrinter)
+	resolver.Resolve(stmts)
+
+	if vm.errorPrinter.hadError {
+		return nil, &CompileError{Errors: vm.errorPrinter.Errors()}
+	}
+
+	return vm.execute(stmts)
+}
+
+// evalLine runs a line typed at the REPL through the same pipeline as eval.
+// The line is either statements or a single expression, whose value is
+// returned with isExpression set. resolver is kept from one line to the
+// next, so that a line resolves against the declarations of the previous
+// ones.
+func (vm *VM) evalLine(line string, resolver *Resolver) (val Value, isExpression bool, err error) {
+	vm.limiter.reset()
+	vm.errorPrinter.Reset()
+	vm.errorPrinter.SetSource(line)
+
+	scanner := NewScanner(line, vm.errorPrinter)
+	tokens := scanner.ScanTokens()
+
+	parser := NewParser(tokens, vm.errorPrinter)
+	syntax := parser.ParseREPL()
+
+	if vm.errorPrinter.hadError {
+		return nil, false, &CompileError{Errors: vm.errorPrinter.Errors()}
+	}
+
+	var stmts []Stmt
+	switch syntax := syntax.(type) {
+	case []Stmt:
+		stmts = syntax
+	case *Expression:
+		stmts, isExpression = []Stmt{syntax}, true
+	}
+
+	resolver.Resolve(stmts)
+
+	if vm.errorPrinter.hadError {
+		return nil, false, &CompileError{Errors: vm.errorPrinter.Errors()}
+	}
+
+	val, err = vm.execute(stmts)
+	return val, isExpression, err
+}
+
+// execute runs statements that have been parsed and resolved on the
+// selected backend and returns the value of the trailing expression
+// statement, if any.
+func (vm *VM) execute(stmts []Stmt) (Value, error) {
+	var val Value
+	var err error
+
+	if vm.machine != nil {
+		script := NewCompiler(vm.errorPrinter).Compile(stmts)
+		if vm.errorPrinter.hadError {
+			return nil, &CompileError{Errors: vm.errorPrinter.Errors()}
+		}
+
+		val, err = vm.machine.Interpret(script)
+	} else {
+		val, err = vm.interpreter.interpret(stmts)
+	}
+
+	if err != nil {
+		// the debugger's user already knows the program was stopped.
+		if err != ErrTerminated {
+			vm.errorPrinter.RuntimeError(err)
+		}
+		return nil, err
+	}
+
+	return val, nil
+}
@@ -0,0 +1,84 @@
+package glox
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+)
+
+func TestEval(t *testing.T) {
+	tests := []struct {
+		source string
+		want   Value
+	}{
+		{"1 + 2", int64(3)},
+		{"1 / 2;", 0.5},
+		{`"a" + "b"`, "ab"},
+		{"var x = 2; x * x", int64(4)},
+		{"nil", nil},
+		{"print 1;", nil},
+		{"1 < 2", true},
+	}
+
+	for _, backend := range backends {
+		for _, test := range tests {
+			vm := NewVM(&Options{Backend: backend.backend, Stdout: io.Discard})
+			val, err := vm.Eval(test.source)
+			if err != nil || val != test.want {
+				t.Errorf("%s: %s: got %#v, %v, want %#v", backend.name, test.source, val, err, test.want)
+			}
+		}
+	}
+}
+
+func TestEvalErrors(t *testing.T) {
+	for _, backend := range backends {
+		vm := NewVM(&Options{Backend: backend.backend, Stderr: io.Discard})
+
+		var compileErr *CompileError
+		_, err := vm.Eval("var x = 1;\nprint ;")
+		if !errors.As(err, &compileErr) || len(compileErr.Errors) != 1 {
+			t.Errorf("%s: syntax error: got %v, want a *CompileError", backend.name, err)
+		} else if syntaxErr := compileErr.Errors[0]; syntaxErr.Line != 2 || syntaxErr.Message != "Expect expression." {
+			t.Errorf("%s: syntax error: got %+v", backend.name, syntaxErr)
+		}
+
+		var runtimeErr *RuntimeError
+		_, err = vm.Eval("var x = 1;\nx();")
+		if !errors.As(err, &runtimeErr) || runtimeErr.Line() != 2 || runtimeErr.Error() != "Can only call functions and classes." {
+			t.Errorf("%s: runtime error: got %v, want a *RuntimeError on line 2", backend.name, err)
+		}
+
+		// the VM is still usable after an error.
+		if val, err := vm.Eval("x + 1"); err != nil || val != int64(2) {
+			t.Errorf("%s: after the errors: got %v, %v, want 2", backend.name, val, err)
+		}
+	}
+}
+
+func TestTrace(t *testing.T) {
+	source := `fun inner(x) {
+  return x.field;
+}
+fun outer() {
+  return inner(
+    1
+  );
+}
+outer(
+);`
+	want := []StackFrame{{"inner", "", 2}, {"outer", "", 5}, {"<script>", "", 9}}
+
+	for _, backend := range backends {
+		vm := NewVM(&Options{Backend: backend.backend, Stderr: io.Discard})
+
+		var runtimeErr *RuntimeError
+		if _, err := vm.Eval(source); !errors.As(err, &runtimeErr) {
+			t.Fatalf("%s: got %v, want a *RuntimeError", backend.name, err)
+		}
+		if !reflect.DeepEqual(runtimeErr.Trace, want) {
+			t.Errorf("%s: got trace %v, want %v", backend.name, runtimeErr.Trace, want)
+		}
+	}
+}